@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -15,13 +17,24 @@ import (
 	"github.com/shamal-iroshan/notora/internal/config"
 	"github.com/shamal-iroshan/notora/internal/db"
 	"github.com/shamal-iroshan/notora/internal/middleware"
+	"github.com/shamal-iroshan/notora/internal/pkg/encryption"
+	"github.com/shamal-iroshan/notora/internal/pkg/mailer"
 
 	// Notes modules
+	attachmentapi "github.com/shamal-iroshan/notora/internal/api/attachments"
+	collaboratorapi "github.com/shamal-iroshan/notora/internal/api/collaborators"
 	encryptedapi "github.com/shamal-iroshan/notora/internal/api/encrypted"
+	exportapi "github.com/shamal-iroshan/notora/internal/api/export"
+	folderapi "github.com/shamal-iroshan/notora/internal/api/folders"
+	importapi "github.com/shamal-iroshan/notora/internal/api/importer"
+	keyapi "github.com/shamal-iroshan/notora/internal/api/keys"
 	noteapi "github.com/shamal-iroshan/notora/internal/api/notes"
 	shareapi "github.com/shamal-iroshan/notora/internal/api/share"
+	syncapi "github.com/shamal-iroshan/notora/internal/api/sync"
+	tagapi "github.com/shamal-iroshan/notora/internal/api/tags"
 	"github.com/shamal-iroshan/notora/internal/repository"
 	"github.com/shamal-iroshan/notora/internal/service"
+	"github.com/shamal-iroshan/notora/internal/worker"
 )
 
 func main() {
@@ -33,6 +46,16 @@ func main() {
 	// Load application configuration (port, DB path, secrets, cookies)
 	cfg := config.LoadFromEnv()
 
+	keyring, err := encryption.NewKeyring(cfg.EncryptionKeys, cfg.EncryptionKeyID, cfg.EncryptionKey)
+	if err != nil {
+		log.Fatal("invalid encryption keys:", err)
+	}
+	cfg.Keyring = keyring
+
+	if cfg.SearchIndexKey == "" && keyring.ActiveID() != "" {
+		log.Fatal("SEARCH_INDEX_KEY is required when ENCRYPTION_KEY is not set")
+	}
+
 	// -------------------------------------------------------------
 	// Ensure data directory exists (for SQLite DB)
 	// -------------------------------------------------------------
@@ -88,7 +111,20 @@ func main() {
 
 	// Create Note repository → service → handler
 	noteRepo := repository.NewNoteRepository(dbConn, cfg)
-	noteService := service.NewNoteService(noteRepo)
+
+	// Make notes written before the search index existed searchable, and
+	// rebuild the index if SEARCH_INDEX_KEY changed
+	if indexed, err := noteRepo.IndexMissing(); err != nil {
+		log.Println("search index backfill failed:", err)
+	} else if indexed > 0 {
+		log.Println("search index: indexed", indexed, "notes")
+	}
+
+	noteRevisionRepo := repository.NewNoteRevisionRepository(dbConn, cfg)
+	tagRepo := repository.NewTagRepository(dbConn)
+	folderRepo := repository.NewFolderRepository(dbConn)
+	attachmentRepo := repository.NewAttachmentRepository(dbConn, cfg)
+	noteService := service.NewNoteService(noteRepo, noteRevisionRepo, tagRepo, folderRepo, attachmentRepo)
 	noteHandler := noteapi.NewNoteHandler(noteService)
 
 	// Register protected notes routes
@@ -100,21 +136,73 @@ func main() {
 		pendingBlock,
 	)
 
+	// Permanently delete notes that stayed in the trash past the retention period
+	if cfg.TrashRetentionDays > 0 && cfg.TrashPurgeInterval > 0 {
+		purger := worker.NewTrashPurger(
+			noteService,
+			time.Duration(cfg.TrashRetentionDays)*24*time.Hour,
+			time.Duration(cfg.TrashPurgeInterval)*time.Second,
+		)
+		go purger.Run(context.Background())
+	}
+
+	// Re-encrypt notes and attachments still sealed with an old server key
+	if cfg.Keyring.ActiveID() != "" {
+		keyRotationService := service.NewKeyRotationService(noteRepo, noteRevisionRepo, attachmentRepo)
+		go worker.NewKeyRotator(keyRotationService, 100, 100*time.Millisecond).Run(context.Background())
+	}
+
+	// -------------------------------
+	// TAGS MODULE SETUP
+	// -------------------------------
+	tagService := service.NewTagService(tagRepo)
+	tagHandler := tagapi.NewTagHandler(tagService)
+
+	tagapi.RegisterTagRoutes(r.Group("/api", jwtBlock, pendingBlock), tagHandler)
+
+	// -------------------------------
+	// FOLDERS MODULE SETUP
+	// -------------------------------
+	folderService := service.NewFolderService(folderRepo, noteRepo)
+	folderHandler := folderapi.NewFolderHandler(folderService)
+
+	folderapi.RegisterFolderRoutes(r.Group("/api", jwtBlock, pendingBlock), folderHandler)
+
+	// -------------------------------
+	// ATTACHMENTS MODULE SETUP
+	// -------------------------------
+	attachmentService := service.NewAttachmentService(attachmentRepo, noteRepo, cfg)
+	attachmentHandler := attachmentapi.NewAttachmentHandler(attachmentService)
+
+	attachmentapi.RegisterAttachmentRoutes(r.Group("/api", jwtBlock, pendingBlock), attachmentHandler)
+
+	// -------------------------------
+	// COLLABORATORS MODULE SETUP
+	// -------------------------------
+	collaboratorRepo := repository.NewCollaboratorRepository(dbConn)
+	collaboratorService := service.NewCollaboratorService(collaboratorRepo, noteRepo, userRepo)
+	collaboratorHandler := collaboratorapi.NewCollaboratorHandler(collaboratorService)
+
+	collaboratorapi.RegisterCollaboratorRoutes(r.Group("/api", jwtBlock, pendingBlock), collaboratorHandler)
+
 	// -------------------------------
 	// SHARING MODULE SETUP
 	// -------------------------------
 	shareRepo := repository.NewShareRepository(dbConn)
-	shareService := service.NewShareService(noteRepo, shareRepo)
+	shareService := service.NewShareService(noteRepo, shareRepo, attachmentRepo, cfg)
 	shareHandler := shareapi.NewShareHandler(shareService, cfg)
 
 	// Public sharing: no auth
 	shareapi.RegisterPublicShareRoutes(r.Group("/api"), shareHandler)
+	shareapi.RegisterSharePageRoutes(r.Group(""), shareHandler)
 
 	// Protected sharing: must own the note
 	shareapi.RegisterProtectedShareRoutes(r.Group("/api", jwtBlock, pendingBlock), shareHandler)
 
-	encryptedRepo := repository.NewEncryptedNotesRepository(dbConn)
-	encryptedService := service.NewEncryptedNotesService(encryptedRepo)
+	encryptedRepo := repository.NewEncryptedNotesRepository(dbConn, cfg)
+	encryptedKeyRepo := repository.NewEncryptedNoteKeyRepository(dbConn)
+	encryptedRevisionRepo := repository.NewEncryptedNoteRevisionRepository(dbConn)
+	encryptedService := service.NewEncryptedNotesService(encryptedRepo, encryptedKeyRepo, encryptedRevisionRepo)
 	encryptedHandler := encryptedapi.NewEncryptedNotesHandler(encryptedService)
 
 	encryptedapi.RegisterEncryptedNotesRoutes(
@@ -122,7 +210,58 @@ func main() {
 		encryptedHandler,
 	)
 
+	encryptedAttachmentRepo := repository.NewEncryptedAttachmentRepository(dbConn, cfg)
+	encryptedAttachmentService := service.NewEncryptedAttachmentService(encryptedAttachmentRepo, cfg)
+	encryptedAttachmentHandler := encryptedapi.NewEncryptedAttachmentHandler(encryptedAttachmentService)
+
+	encryptedapi.RegisterEncryptedAttachmentRoutes(
+		r.Group("/api/encrypted-notes", jwtBlock, pendingBlock),
+		encryptedAttachmentHandler,
+	)
+
+	// -------------------------------
+	// KEYS MODULE SETUP
+	// -------------------------------
+	userKeyRepo := repository.NewUserKeyRepository(dbConn)
+	userKeyService := service.NewUserKeyService(userKeyRepo, userRepo)
+	recoveryRepo := repository.NewRecoveryRepository(dbConn)
+	var mail mailer.Mailer = mailer.LogMailer{}
+	if cfg.SMTPHost != "" {
+		mail = mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	}
+	recoveryService := service.NewRecoveryService(recoveryRepo, userRepo, mail)
+	go worker.NewRecoveryTokenPurger(recoveryService, time.Hour).Run(context.Background())
+	keyHandler := keyapi.NewKeyHandler(userKeyService, recoveryService)
+
+	keyapi.RegisterKeyRoutes(r.Group("/api", jwtBlock, pendingBlock), keyHandler)
+
+	// -------------------------------
+	// SYNC MODULE SETUP
+	// -------------------------------
+	syncRepo := repository.NewSyncRepository(dbConn)
+	syncService := service.NewSyncService(syncRepo, noteService, encryptedService)
+	syncHandler := syncapi.NewSyncHandler(syncService)
+
+	syncapi.RegisterSyncRoutes(r.Group("/api", jwtBlock, pendingBlock), syncHandler)
+
+	// -------------------------------
+	// EXPORT MODULE SETUP
+	// -------------------------------
+	exportService := service.NewExportService(noteRepo, encryptedRepo)
+	exportHandler := exportapi.NewExportHandler(exportService)
+
+	exportapi.RegisterExportRoutes(r.Group("/api", jwtBlock, pendingBlock), exportHandler)
+
+	// -------------------------------
+	// IMPORT MODULE SETUP
+	// -------------------------------
+	importService := service.NewImportService(noteService)
+	importHandler := importapi.NewImportHandler(importService)
+
+	importapi.RegisterImportRoutes(r.Group("/api", jwtBlock, pendingBlock), importHandler)
+
 	// Admin Area
+	adminHandler := admin.NewAdminHandler(userRepo)
 	adminGroup := r.Group("/api/admin")
 	adminGroup.Use(jwtBlock, middleware.RequireAdmin())
 	admin.RegisterAdminRoutes(adminGroup, adminHandler)
@@ -0,0 +1,183 @@
+package attachments
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// multipartOverhead is allowed on top of the file itself for the rest of
+// the multipart body.
+const multipartOverhead = 1 << 20
+
+// inlineTypes may be shown by the browser directly (e.g. as <img> in a
+// rendered note). Everything else is served as a download.
+var inlineTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/bmp":  true,
+}
+
+// AttachmentHandler handles note attachment endpoints.
+type AttachmentHandler struct {
+	Service *service.AttachmentService
+}
+
+func NewAttachmentHandler(service *service.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{Service: service}
+}
+
+// Helper to convert string → int64 safely
+func toInt64(s string) int64 {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return v
+}
+
+// -------------------------------------------------------------
+// POST /api/notes/:id/attachments
+// Uploads a file (multipart field "file") and attaches it to
+// the note. Returns the attachment with its download URL.
+// -------------------------------------------------------------
+func (h *AttachmentHandler) Upload(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.Service.MaxSize()+multipartOverhead)
+
+	fh, err := ctx.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ctx.JSON(413, gin.H{"error": service.ErrAttachmentTooLarge.Error()})
+			return
+		}
+		ctx.JSON(400, gin.H{"error": "file is required"})
+		return
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "could not read file"})
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "could not read file"})
+		return
+	}
+
+	attachment, err := h.Service.Upload(userID, noteID, fh.Filename, data)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(201, attachment)
+}
+
+// -------------------------------------------------------------
+// GET /api/notes/:id/attachments
+// Lists the attachments of a note
+// -------------------------------------------------------------
+func (h *AttachmentHandler) List(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	attachments, err := h.Service.List(userID, noteID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"attachments": attachments})
+}
+
+// -------------------------------------------------------------
+// GET /api/attachments/:id
+// Downloads an attachment
+// -------------------------------------------------------------
+func (h *AttachmentHandler) Download(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	attachmentID := toInt64(ctx.Param("id"))
+
+	attachment, data, err := h.Service.Download(userID, attachmentID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	Serve(ctx, attachment, data)
+}
+
+// -------------------------------------------------------------
+// DELETE /api/attachments/:id
+// Deletes an attachment
+// -------------------------------------------------------------
+func (h *AttachmentHandler) Delete(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	attachmentID := toInt64(ctx.Param("id"))
+
+	if err := h.Service.Delete(userID, attachmentID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "deleted"})
+}
+
+// -------------------------------------------------------------
+// GET /api/attachments/usage
+// Returns the attachment storage used and the quota (0 = none)
+// -------------------------------------------------------------
+func (h *AttachmentHandler) Usage(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	usage, err := h.Service.Usage(userID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to load usage"})
+		return
+	}
+
+	ctx.JSON(200, usage)
+}
+
+// Serve writes an attachment. The stored, sniffed MIME type is sent with
+// nosniff, and only plain image types are shown inline; anything else is
+// a download and can't run scripts on this origin.
+func Serve(ctx *gin.Context, attachment *model.Attachment, data []byte) {
+	disposition := "attachment"
+	if inlineTypes[attachment.MimeType] {
+		disposition = "inline"
+	}
+
+	ctx.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	ctx.Header("Cache-Control", "private, max-age=86400")
+	ctx.Data(200, attachment.MimeType, data)
+}
+
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrNoteNotFound), errors.Is(err, service.ErrAttachmentNotFound):
+		ctx.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAttachmentTooLarge):
+		ctx.JSON(413, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrQuotaExceeded):
+		ctx.JSON(507, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAttachmentEmpty):
+		ctx.JSON(400, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(500, gin.H{"error": "attachment request failed"})
+	}
+}
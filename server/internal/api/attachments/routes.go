@@ -0,0 +1,12 @@
+package attachments
+
+import "github.com/gin-gonic/gin"
+
+// Protected routes (requires JWT)
+func RegisterAttachmentRoutes(r *gin.RouterGroup, handler *AttachmentHandler) {
+	r.POST("/notes/:id/attachments", handler.Upload)
+	r.GET("/notes/:id/attachments", handler.List)
+	r.GET("/attachments/usage", handler.Usage)
+	r.GET("/attachments/:id", handler.Download)
+	r.DELETE("/attachments/:id", handler.Delete)
+}
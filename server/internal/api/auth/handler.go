@@ -157,7 +157,13 @@ func (h *AuthHandler) Me(ctx *gin.Context) {
 			"email":      user.Email,
 			"name":       user.Name,
 			"user_salt":  user.UserSalt,
+			"kdf":        user.KDF,
+			"key_check":  user.KeyCheck,
 			"created_at": user.CreatedAt,
+
+			// Parameters below the server minimum should be upgraded
+			// through POST /api/encrypted-notes/rekey
+			"kdf_upgrade_recommended": !service.KDFMeetsMinimum(h.AppConfig, user.KDF),
 		},
 	})
 }
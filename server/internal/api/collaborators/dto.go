@@ -0,0 +1,8 @@
+package collaborators
+
+// ----- REQUEST DTOs -----
+
+type AddCollaboratorRequest struct {
+	Email string `json:"email" binding:"required"`
+	Role  string `json:"role" binding:"required"`
+}
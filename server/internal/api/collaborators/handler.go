@@ -0,0 +1,127 @@
+package collaborators
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// CollaboratorHandler handles sharing notes with other users.
+type CollaboratorHandler struct {
+	Service *service.CollaboratorService
+}
+
+func NewCollaboratorHandler(service *service.CollaboratorService) *CollaboratorHandler {
+	return &CollaboratorHandler{Service: service}
+}
+
+func toInt64(s string) int64 {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return v
+}
+
+// writeError maps collaborator service errors to HTTP responses.
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrNoteNotFound), errors.Is(err, service.ErrCollaboratorNotFound):
+		ctx.JSON(404, gin.H{"error": "not found"})
+	case errors.Is(err, service.ErrUserNotFound):
+		ctx.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidRole), errors.Is(err, service.ErrShareWithSelf):
+		ctx.JSON(400, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(500, gin.H{"error": "failed"})
+	}
+}
+
+// -------------------------------------------------------------
+// GET /api/notes/:id/collaborators
+// Lists the users a note is shared with (owner only)
+// -------------------------------------------------------------
+func (h *CollaboratorHandler) List(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	collaborators, err := h.Service.List(userID, noteID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"collaborators": collaborators})
+}
+
+// -------------------------------------------------------------
+// POST /api/notes/:id/collaborators
+// Shares a note with a user by email as viewer or editor. Sharing
+// again with the same user changes their role.
+// -------------------------------------------------------------
+func (h *CollaboratorHandler) Add(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	var req AddCollaboratorRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	collaborator, err := h.Service.Add(userID, noteID, req.Email, req.Role)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, collaborator)
+}
+
+// -------------------------------------------------------------
+// DELETE /api/notes/:id/collaborators/:userId
+// Revokes a user's access. Collaborators may remove themselves.
+// -------------------------------------------------------------
+func (h *CollaboratorHandler) Remove(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	collaboratorID := toInt64(ctx.Param("userId"))
+
+	if err := h.Service.Remove(userID, noteID, collaboratorID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "removed"})
+}
+
+// -------------------------------------------------------------
+// GET /api/notes/shared-with-me
+// Lists the notes other users shared with the user
+// -------------------------------------------------------------
+func (h *CollaboratorHandler) SharedWithMe(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	notes, err := h.Service.SharedWithMe(userID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"notes": notes})
+}
+
+// -------------------------------------------------------------
+// GET /api/notes/shared-by-me
+// Lists the user's notes that are shared with other users
+// -------------------------------------------------------------
+func (h *CollaboratorHandler) SharedByMe(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	notes, err := h.Service.SharedByMe(userID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"notes": notes})
+}
@@ -0,0 +1,13 @@
+package collaborators
+
+import "github.com/gin-gonic/gin"
+
+// Protected routes (requires JWT)
+func RegisterCollaboratorRoutes(r *gin.RouterGroup, handler *CollaboratorHandler) {
+	r.GET("/notes/shared-with-me", handler.SharedWithMe)
+	r.GET("/notes/shared-by-me", handler.SharedByMe)
+
+	r.GET("/notes/:id/collaborators", handler.List)
+	r.POST("/notes/:id/collaborators", handler.Add)
+	r.DELETE("/notes/:id/collaborators/:userId", handler.Remove)
+}
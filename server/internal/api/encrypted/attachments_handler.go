@@ -0,0 +1,185 @@
+package encrypted
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// chunkNonceHeader carries the hex AES-GCM nonce of a chunk, both ways.
+const chunkNonceHeader = "X-Chunk-Nonce"
+
+type EncryptedAttachmentHandler struct {
+	Service *service.EncryptedAttachmentService
+}
+
+func NewEncryptedAttachmentHandler(s *service.EncryptedAttachmentService) *EncryptedAttachmentHandler {
+	return &EncryptedAttachmentHandler{Service: s}
+}
+
+// POST /api/encrypted-notes/:id/attachments
+// Starts a chunked upload: {metadata, metadata_nonce, file_salt,
+// wrapped_file_key, file_key_nonce, chunk_count}
+func (h *EncryptedAttachmentHandler) Create(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	var dto CreateEncryptedAttachmentDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	attachment, err := h.Service.Create(userID, noteID, model.CreateEncryptedAttachmentInput{
+		MetadataCiphertext: dto.MetadataCiphertext,
+		MetadataNonce:      dto.MetadataNonce,
+		FileSalt:           dto.FileSalt,
+		WrappedFileKey:     dto.WrappedFileKey,
+		FileKeyNonce:       dto.FileKeyNonce,
+		ChunkCount:         dto.ChunkCount,
+	})
+	if err != nil {
+		writeAttachmentError(ctx, err)
+		return
+	}
+
+	ctx.JSON(201, attachment)
+}
+
+// GET /api/encrypted-notes/:id/attachments
+func (h *EncryptedAttachmentHandler) List(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	attachments, err := h.Service.List(userID, noteID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "db error"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"attachments": attachments})
+}
+
+// GET /api/encrypted-notes/:id/attachments/:attachmentId
+// Returns the attachment with the nonce and size of every chunk
+func (h *EncryptedAttachmentHandler) Get(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	attachmentID := toInt64(ctx.Param("attachmentId"))
+
+	attachment, err := h.Service.Get(userID, noteID, attachmentID)
+	if err != nil {
+		writeAttachmentError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, attachment)
+}
+
+// PUT /api/encrypted-notes/:id/attachments/:attachmentId/chunks/:index
+// Body: the raw chunk ciphertext, nonce in the X-Chunk-Nonce header
+func (h *EncryptedAttachmentHandler) PutChunk(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	attachmentID := toInt64(ctx.Param("attachmentId"))
+
+	index, err := strconv.Atoi(ctx.Param("index"))
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid chunk index"})
+		return
+	}
+
+	body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, service.MaxEncryptedChunkSize)
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeAttachmentError(ctx, service.ErrAttachmentTooLarge)
+		return
+	}
+
+	err = h.Service.PutChunk(userID, noteID, attachmentID, index, ctx.GetHeader(chunkNonceHeader), data)
+	if err != nil {
+		writeAttachmentError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "stored", "index": index, "size": len(data)})
+}
+
+// POST /api/encrypted-notes/:id/attachments/:attachmentId/complete
+func (h *EncryptedAttachmentHandler) Complete(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	attachmentID := toInt64(ctx.Param("attachmentId"))
+
+	attachment, err := h.Service.Complete(userID, noteID, attachmentID)
+	if err != nil {
+		writeAttachmentError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, attachment)
+}
+
+// GET /api/encrypted-notes/:id/attachments/:attachmentId/chunks/:index
+// Returns the raw chunk ciphertext, nonce in the X-Chunk-Nonce header
+func (h *EncryptedAttachmentHandler) GetChunk(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	attachmentID := toInt64(ctx.Param("attachmentId"))
+
+	index, err := strconv.Atoi(ctx.Param("index"))
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid chunk index"})
+		return
+	}
+
+	chunk, data, err := h.Service.Chunk(userID, noteID, attachmentID, index)
+	if err != nil {
+		writeAttachmentError(ctx, err)
+		return
+	}
+
+	ctx.Header(chunkNonceHeader, chunk.Nonce)
+	ctx.Header("Cache-Control", "private, max-age=86400")
+	ctx.Data(200, "application/octet-stream", data)
+}
+
+// DELETE /api/encrypted-notes/:id/attachments/:attachmentId
+func (h *EncryptedAttachmentHandler) Delete(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	attachmentID := toInt64(ctx.Param("attachmentId"))
+
+	if err := h.Service.Delete(userID, noteID, attachmentID); err != nil {
+		writeAttachmentError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "deleted"})
+}
+
+func writeAttachmentError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrNoteNotFound), errors.Is(err, service.ErrAttachmentNotFound):
+		ctx.JSON(404, gin.H{"error": "not found"})
+	case errors.Is(err, service.ErrInvalidEncryptedAttachment),
+		errors.Is(err, service.ErrInvalidChunkNonce),
+		errors.Is(err, service.ErrChunkOutOfRange),
+		errors.Is(err, service.ErrNonceReused),
+		errors.Is(err, service.ErrAttachmentEmpty):
+		ctx.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUploadComplete), errors.Is(err, service.ErrUploadIncomplete):
+		ctx.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAttachmentTooLarge):
+		ctx.JSON(413, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrQuotaExceeded):
+		ctx.JSON(507, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(500, gin.H{"error": "db error"})
+	}
+}
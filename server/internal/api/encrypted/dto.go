@@ -6,6 +6,7 @@ type CreateEncryptedNoteDTO struct {
 	TitleNonce        string `json:"title_nonce"`
 	ContentNonce      string `json:"content_nonce"`
 	NoteSalt          string `json:"note_salt"`
+	FormatVersion     int    `json:"format_version"`
 }
 
 type UpdateEncryptedNoteDTO struct {
@@ -14,4 +15,98 @@ type UpdateEncryptedNoteDTO struct {
 	TitleNonce        string `json:"title_nonce"`
 	ContentNonce      string `json:"content_nonce"`
 	NoteSalt          string `json:"note_salt"`
+	FormatVersion     int    `json:"format_version"`
+	Version           *int64 `json:"version"` // alternative to If-Match, conflicts answer 409
+}
+
+// CreateEncryptedAttachmentDTO starts a chunked upload. metadata is the
+// encrypted file name and type (base64), nonce and salt are hex like the
+// fields of a note. wrapped_file_key (base64) is the random file key
+// wrapped with the key derived from file_salt, under file_key_nonce.
+type CreateEncryptedAttachmentDTO struct {
+	MetadataCiphertext string `json:"metadata"`
+	MetadataNonce      string `json:"metadata_nonce"`
+	FileSalt           string `json:"file_salt"`
+	WrappedFileKey     string `json:"wrapped_file_key"`
+	FileKeyNonce       string `json:"file_key_nonce"`
+	ChunkCount         int    `json:"chunk_count"`
+}
+
+// ListEncryptedNotesQuery holds the query parameters of GET /api/encrypted-notes.
+// Titles are ciphertext, so only the updated (default) and created sorts exist.
+type ListEncryptedNotesQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+	Sort   string `form:"sort"`
+}
+
+// ShareEncryptedNoteDTO shares an encrypted note with a user. wrapped_key
+// is the note key encrypted with a key agreed between ephemeral_public_key
+// and the recipient's public key.
+type ShareEncryptedNoteDTO struct {
+	UserID             int64  `json:"user_id" binding:"required"`
+	Role               string `json:"role"`
+	WrappedKey         string `json:"wrapped_key" binding:"required"`
+	EphemeralPublicKey string `json:"ephemeral_public_key" binding:"required"`
+	WrapNonce          string `json:"wrap_nonce" binding:"required"`
+}
+
+// RekeyEncryptedNotesDTO re-encrypts everything under a new master key.
+// notes and attachments must list every encrypted note and attachment the
+// user owns; kdf, key_check and private_key are optional. Without
+// recovery_key the recovery key is removed.
+type RekeyEncryptedNotesDTO struct {
+	UserSalt    string               `json:"user_salt" binding:"required"`
+	KDF         *KDFParamsDTO        `json:"kdf"`
+	Notes       []RekeyNoteDTO       `json:"notes" binding:"dive"`
+	Attachments []RekeyAttachmentDTO `json:"attachments" binding:"dive"`
+	KeyCheck    *RekeyKeyCheckDTO    `json:"key_check"`
+	PrivateKey  *RekeyPrivateKeyDTO  `json:"private_key"`
+	RecoveryKey *RekeyRecoveryKeyDTO `json:"recovery_key"`
+}
+
+type RekeyNoteDTO struct {
+	ID                int64  `json:"id" binding:"required"`
+	TitleCiphertext   string `json:"title"`
+	ContentCiphertext string `json:"content"`
+	TitleNonce        string `json:"title_nonce"`
+	ContentNonce      string `json:"content_nonce"`
+	NoteSalt          string `json:"note_salt" binding:"required"`
+	FormatVersion     int    `json:"format_version"`
+	Version           *int64 `json:"version"` // version that was re-encrypted, optional
+}
+
+// RekeyAttachmentDTO is the file key of an attachment wrapped under the new
+// master key, with the salt the wrapping key is derived from.
+type RekeyAttachmentDTO struct {
+	ID             int64  `json:"id" binding:"required"`
+	FileSalt       string `json:"file_salt"`
+	WrappedFileKey string `json:"wrapped_file_key"`
+	FileKeyNonce   string `json:"file_key_nonce"`
+}
+
+// KDFParamsDTO describes how the new master key is derived. memory_kib
+// and parallelism are for Argon2id only.
+type KDFParamsDTO struct {
+	Algorithm   string `json:"algorithm" binding:"required"`
+	Iterations  int    `json:"iterations" binding:"required"`
+	MemoryKiB   int    `json:"memory_kib"`
+	Parallelism int    `json:"parallelism"`
+}
+
+type RekeyKeyCheckDTO struct {
+	Ciphertext string `json:"ciphertext" binding:"required"`
+	Nonce      string `json:"nonce" binding:"required"`
+}
+
+type RekeyPrivateKeyDTO struct {
+	Ciphertext string `json:"ciphertext" binding:"required"`
+	Nonce      string `json:"nonce" binding:"required"`
+}
+
+// RekeyRecoveryKeyDTO is the new master key wrapped under the recovery key,
+// as in PUT /api/me/recovery-key.
+type RekeyRecoveryKeyDTO struct {
+	WrappedMasterKey string `json:"wrapped_master_key" binding:"required"`
+	Nonce            string `json:"nonce" binding:"required"`
 }
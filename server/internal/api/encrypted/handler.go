@@ -1,10 +1,13 @@
 package encrypted
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/pkg/etag"
+	"github.com/shamal-iroshan/notora/internal/pkg/pagination"
 	"github.com/shamal-iroshan/notora/internal/service"
 )
 
@@ -37,9 +40,13 @@ func (h *EncryptedNotesHandler) Create(ctx *gin.Context) {
 		TitleNonce:        dto.TitleNonce,
 		ContentNonce:      dto.ContentNonce,
 		NoteSalt:          dto.NoteSalt,
+		FormatVersion:     dto.FormatVersion,
 	}
 
 	id, err := h.Service.Create(userID, input)
+	if writeValidationError(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": "db error"})
 		return
@@ -52,13 +59,31 @@ func (h *EncryptedNotesHandler) Create(ctx *gin.Context) {
 func (h *EncryptedNotesHandler) List(ctx *gin.Context) {
 	userID := ctx.GetInt64("user_id")
 
-	notes, err := h.Service.List(userID)
+	var query ListEncryptedNotesQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid query parameters"})
+		return
+	}
+
+	sort, err := pagination.ParseSort(query.Sort, pagination.SortUpdated, pagination.SortCreated)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "sort must be updated or created"})
+		return
+	}
+
+	after, err := pagination.Decode(query.Cursor, sort)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid cursor"})
+		return
+	}
+
+	notes, next, err := h.Service.List(userID, sort, after, query.Limit)
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": "db error"})
 		return
 	}
 
-	ctx.JSON(200, notes)
+	ctx.JSON(200, gin.H{"notes": notes, "next_cursor": next})
 }
 
 // GET /api/encrypted-notes/:id
@@ -72,10 +97,14 @@ func (h *EncryptedNotesHandler) Get(ctx *gin.Context) {
 		return
 	}
 
+	ctx.Header("ETag", etag.Format(note.Version))
 	ctx.JSON(200, note)
 }
 
 // PUT /api/encrypted-notes/:id
+// PATCH /api/encrypted-notes/:id
+// Both replace the whole ciphertext tuple: the server can't merge fields
+// it can't read, so PATCH takes the same body as PUT.
 func (h *EncryptedNotesHandler) Update(ctx *gin.Context) {
 	userID := ctx.GetInt64("user_id")
 	noteID := toInt64(ctx.Param("id"))
@@ -92,14 +121,63 @@ func (h *EncryptedNotesHandler) Update(ctx *gin.Context) {
 		TitleNonce:        dto.TitleNonce,
 		ContentNonce:      dto.ContentNonce,
 		NoteSalt:          dto.NoteSalt,
+		FormatVersion:     dto.FormatVersion,
 	}
 
-	if err := h.Service.Update(userID, noteID, input); err != nil {
-		ctx.JSON(500, gin.H{"error": "db error"})
+	versions, conflictStatus, err := etag.IfMatch(ctx.GetHeader("If-Match"), dto.Version)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	input.IfMatch = versions
+
+	version, err := h.Service.Update(userID, noteID, input)
+	if err != nil {
+		h.writeUpdateError(ctx, err, userID, noteID, conflictStatus)
+		return
+	}
+
+	ctx.Header("ETag", etag.Format(version))
+	ctx.JSON(200, gin.H{"status": "updated", "version": version})
+}
+
+// writeUpdateError answers a failed update. A version conflict includes
+// the current note so the client can merge.
+func (h *EncryptedNotesHandler) writeUpdateError(ctx *gin.Context, err error, userID, noteID int64, conflictStatus int) {
+	if writeValidationError(ctx, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrNoteNotFound), errors.Is(err, service.ErrRevisionNotFound):
+		ctx.JSON(404, gin.H{"error": "not found"})
+	case errors.Is(err, service.ErrNoteReadOnly):
+		ctx.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNoteSaltChanged):
+		ctx.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrVersionConflict):
+		current, err := h.Service.Get(userID, noteID)
+		if err != nil {
+			ctx.JSON(404, gin.H{"error": "not found"})
+			return
+		}
+		ctx.Header("ETag", etag.Format(current.Version))
+		ctx.JSON(conflictStatus, gin.H{"error": "version conflict", "version": current.Version, "note": current})
+	default:
+		ctx.JSON(500, gin.H{"error": "db error"})
+	}
+}
+
+// writeValidationError answers a malformed encrypted payload with 400 and
+// the invalid fields. It reports whether err was one.
+func writeValidationError(ctx *gin.Context, err error) bool {
+	var verr *service.ValidationError
+	if !errors.As(err, &verr) {
+		return false
+	}
 
-	ctx.JSON(200, gin.H{"status": "updated"})
+	ctx.JSON(400, gin.H{"error": "invalid encrypted note", "fields": verr.Fields})
+	return true
 }
 
 // DELETE /api/encrypted-notes/:id
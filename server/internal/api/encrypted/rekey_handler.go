@@ -0,0 +1,96 @@
+package encrypted
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// POST /api/encrypted-notes/rekey
+// Replaces user_salt, all my encrypted notes and the file keys of my
+// encrypted attachments at once after a master password change or KDF
+// upgrade. All or nothing.
+func (h *EncryptedNotesHandler) Rekey(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	var dto RekeyEncryptedNotesDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	input := model.RekeyEncryptedNotesInput{
+		UserSalt:    dto.UserSalt,
+		Notes:       make([]model.RekeyEncryptedNote, len(dto.Notes)),
+		Attachments: make([]model.RekeyEncryptedAttachment, len(dto.Attachments)),
+	}
+	for i, n := range dto.Notes {
+		input.Notes[i] = model.RekeyEncryptedNote{
+			ID:                n.ID,
+			TitleCiphertext:   n.TitleCiphertext,
+			ContentCiphertext: n.ContentCiphertext,
+			TitleNonce:        n.TitleNonce,
+			ContentNonce:      n.ContentNonce,
+			NoteSalt:          n.NoteSalt,
+			FormatVersion:     n.FormatVersion,
+			Version:           n.Version,
+		}
+	}
+	for i, a := range dto.Attachments {
+		input.Attachments[i] = model.RekeyEncryptedAttachment{
+			ID:             a.ID,
+			FileSalt:       a.FileSalt,
+			WrappedFileKey: a.WrappedFileKey,
+			FileKeyNonce:   a.FileKeyNonce,
+		}
+	}
+	if kdf := dto.KDF; kdf != nil {
+		input.KDF = &model.KDFParams{
+			Algorithm:   kdf.Algorithm,
+			Iterations:  kdf.Iterations,
+			MemoryKiB:   kdf.MemoryKiB,
+			Parallelism: kdf.Parallelism,
+		}
+	}
+	if kc := dto.KeyCheck; kc != nil {
+		input.KeyCheck = &model.KeyCheck{Ciphertext: kc.Ciphertext, Nonce: kc.Nonce}
+	}
+	if pk := dto.PrivateKey; pk != nil {
+		input.PrivateKeyCiphertext = pk.Ciphertext
+		input.PrivateKeyNonce = pk.Nonce
+	}
+	if rk := dto.RecoveryKey; rk != nil {
+		input.RecoveryKey = &model.RecoveryKey{WrappedMasterKey: rk.WrappedMasterKey, Nonce: rk.Nonce}
+	}
+
+	err := h.Service.Rekey(userID, input)
+	if writeValidationError(ctx, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrInvalidUserSalt),
+		errors.Is(err, service.ErrDuplicateNote),
+		errors.Is(err, service.ErrDuplicateAttachment),
+		errors.Is(err, service.ErrNoteSaltReused),
+		errors.Is(err, service.ErrInvalidKeyCheck),
+		errors.Is(err, service.ErrUnsupportedKDF),
+		errors.Is(err, service.ErrWeakKDF),
+		errors.Is(err, service.ErrCostlyKDF),
+		errors.Is(err, service.ErrInvalidPrivateKey),
+		errors.Is(err, service.ErrInvalidRecoveryKey):
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrRekeyIncomplete),
+		errors.Is(err, service.ErrVersionConflict):
+		ctx.JSON(409, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		ctx.JSON(500, gin.H{"error": "db error"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "rekeyed", "notes": len(input.Notes), "attachments": len(input.Attachments)})
+}
@@ -0,0 +1,70 @@
+package encrypted
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/pkg/etag"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// GET /api/encrypted-notes/:id/revisions
+// Previous ciphertext tuples of the note, newest first, without content
+func (h *EncryptedNotesHandler) ListRevisions(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	revisions, err := h.Service.ListRevisions(userID, noteID)
+	if errors.Is(err, service.ErrNoteNotFound) {
+		ctx.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "db error"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"revisions": revisions})
+}
+
+// GET /api/encrypted-notes/:id/revisions/:rev
+func (h *EncryptedNotesHandler) GetRevision(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	revision := toInt64(ctx.Param("rev"))
+
+	rv, err := h.Service.GetRevision(userID, noteID, revision)
+	if errors.Is(err, service.ErrRevisionNotFound) {
+		ctx.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "db error"})
+		return
+	}
+
+	ctx.JSON(200, rv)
+}
+
+// POST /api/encrypted-notes/:id/revisions/:rev/restore
+// Copies the revision's ciphertext tuple back into the note. Honours If-Match.
+func (h *EncryptedNotesHandler) RestoreRevision(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	revision := toInt64(ctx.Param("rev"))
+
+	versions, conflictStatus, err := etag.IfMatch(ctx.GetHeader("If-Match"), nil)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, err := h.Service.RestoreRevision(userID, noteID, revision, versions)
+	if err != nil {
+		h.writeUpdateError(ctx, err, userID, noteID, conflictStatus)
+		return
+	}
+
+	ctx.Header("ETag", etag.Format(version))
+	ctx.JSON(200, gin.H{"status": "restored", "version": version})
+}
@@ -7,5 +7,27 @@ func RegisterEncryptedNotesRoutes(r *gin.RouterGroup, h *EncryptedNotesHandler)
 	r.GET("/", h.List)
 	r.GET("/:id", h.Get)
 	r.PUT("/:id", h.Update)
+	r.PATCH("/:id", h.Update)
 	r.DELETE("/:id", h.Delete)
+
+	r.POST("/rekey", h.Rekey)
+
+	r.GET("/shared", h.SharedWithMe)
+	r.POST("/:id/recipients", h.Share)
+	r.GET("/:id/recipients", h.Recipients)
+	r.DELETE("/:id/recipients/:userId", h.Revoke)
+
+	r.GET("/:id/revisions", h.ListRevisions)
+	r.GET("/:id/revisions/:rev", h.GetRevision)
+	r.POST("/:id/revisions/:rev/restore", h.RestoreRevision)
+}
+
+func RegisterEncryptedAttachmentRoutes(r *gin.RouterGroup, h *EncryptedAttachmentHandler) {
+	r.POST("/:id/attachments", h.Create)
+	r.GET("/:id/attachments", h.List)
+	r.GET("/:id/attachments/:attachmentId", h.Get)
+	r.DELETE("/:id/attachments/:attachmentId", h.Delete)
+	r.PUT("/:id/attachments/:attachmentId/chunks/:index", h.PutChunk)
+	r.GET("/:id/attachments/:attachmentId/chunks/:index", h.GetChunk)
+	r.POST("/:id/attachments/:attachmentId/complete", h.Complete)
 }
@@ -0,0 +1,99 @@
+package encrypted
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// GET /api/encrypted-notes/shared
+// Encrypted notes other users shared with me, with the wrapped keys
+func (h *EncryptedNotesHandler) SharedWithMe(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	notes, err := h.Service.SharedWithMe(userID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "db error"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"notes": notes})
+}
+
+// POST /api/encrypted-notes/:id/recipients
+// Shares the note, or replaces a recipient's wrapped key and role
+func (h *EncryptedNotesHandler) Share(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	var dto ShareEncryptedNoteDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid payload"})
+		return
+	}
+	if dto.Role == "" {
+		dto.Role = model.CollaboratorViewer
+	}
+
+	err := h.Service.Share(userID, noteID, model.ShareEncryptedNoteInput{
+		RecipientID:        dto.UserID,
+		Role:               dto.Role,
+		WrappedKey:         dto.WrappedKey,
+		EphemeralPublicKey: dto.EphemeralPublicKey,
+		WrapNonce:          dto.WrapNonce,
+	})
+	if err != nil {
+		writeSharingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "shared"})
+}
+
+// GET /api/encrypted-notes/:id/recipients
+func (h *EncryptedNotesHandler) Recipients(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	recipients, err := h.Service.Recipients(userID, noteID)
+	if err != nil {
+		writeSharingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"recipients": recipients})
+}
+
+// DELETE /api/encrypted-notes/:id/recipients/:userId
+// The owner revokes a recipient, or a recipient leaves the note
+func (h *EncryptedNotesHandler) Revoke(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	recipientID := toInt64(ctx.Param("userId"))
+
+	if err := h.Service.Revoke(userID, noteID, recipientID); err != nil {
+		writeSharingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "revoked"})
+}
+
+func writeSharingError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrNoteNotFound):
+		ctx.JSON(404, gin.H{"error": "not found"})
+	case errors.Is(err, service.ErrCollaboratorNotFound):
+		ctx.JSON(404, gin.H{"error": "recipient not found"})
+	case errors.Is(err, service.ErrInvalidRole),
+		errors.Is(err, service.ErrInvalidWrappedKey),
+		errors.Is(err, service.ErrShareWithSelf):
+		ctx.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrRecipientHasNoKeys):
+		ctx.JSON(409, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(500, gin.H{"error": "db error"})
+	}
+}
@@ -0,0 +1,39 @@
+package export
+
+import (
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// ExportHandler handles data export endpoints.
+type ExportHandler struct {
+	Service *service.ExportService
+}
+
+func NewExportHandler(service *service.ExportService) *ExportHandler {
+	return &ExportHandler{Service: service}
+}
+
+// -------------------------------------------------------------
+// GET /api/export
+// Streams a ZIP archive with all notes of the user: plaintext
+// notes as Markdown files, encrypted notes as ciphertext JSON.
+// -------------------------------------------------------------
+func (h *ExportHandler) Export(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	filename := "notora-export-" + time.Now().UTC().Format("20060102") + ".zip"
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Status(200)
+
+	// The response is already streaming, so a failure can only cut it short
+	if err := h.Service.Write(userID, ctx.Writer); err != nil {
+		log.Println("export failed:", err)
+		ctx.Abort()
+	}
+}
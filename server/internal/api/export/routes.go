@@ -0,0 +1,8 @@
+package export
+
+import "github.com/gin-gonic/gin"
+
+// Protected routes (requires JWT)
+func RegisterExportRoutes(r *gin.RouterGroup, handler *ExportHandler) {
+	r.GET("/export", handler.Export)
+}
@@ -0,0 +1,32 @@
+package folders
+
+type CreateFolderRequest struct {
+	Name     string `json:"name" binding:"required"`
+	ParentID *int64 `json:"parent_id"`
+}
+
+type RenameFolderRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MoveFolderRequest moves a folder under another one. A null parent_id
+// moves it to the top level.
+type MoveFolderRequest struct {
+	ParentID *int64 `json:"parent_id"`
+}
+
+// MoveNoteRequest moves a note into a folder. A null folder_id moves it
+// out of all folders.
+type MoveNoteRequest struct {
+	FolderID *int64 `json:"folder_id"`
+}
+
+type DeleteFolderQuery struct {
+	Mode string `form:"mode"`
+}
+
+type FolderContentsQuery struct {
+	Recursive bool `form:"recursive"`
+	Page      int  `form:"page"`
+	Limit     int  `form:"limit"`
+}
@@ -0,0 +1,201 @@
+package folders
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// FolderHandler handles folder (notebook) endpoints.
+type FolderHandler struct {
+	Service *service.FolderService
+}
+
+func NewFolderHandler(service *service.FolderService) *FolderHandler {
+	return &FolderHandler{Service: service}
+}
+
+func toInt64(s string) int64 {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return v
+}
+
+// writeError maps folder service errors to HTTP responses.
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrFolderNotFound), errors.Is(err, service.ErrNoteNotFound):
+		ctx.JSON(404, gin.H{"error": "not found"})
+	case errors.Is(err, service.ErrInvalidFolderName):
+		ctx.JSON(400, gin.H{"error": "invalid folder name"})
+	case errors.Is(err, service.ErrInvalidDeleteMode):
+		ctx.JSON(400, gin.H{"error": "mode must be cascade or move_to_parent"})
+	case errors.Is(err, service.ErrFolderCycle):
+		ctx.JSON(409, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(500, gin.H{"error": "failed"})
+	}
+}
+
+// -------------------------------------------------------------
+// GET /api/folders
+// Lists all folders of the user (flat, linked by parent_id)
+// -------------------------------------------------------------
+func (h *FolderHandler) List(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	folders, err := h.Service.List(userID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"folders": folders})
+}
+
+// -------------------------------------------------------------
+// POST /api/folders
+// -------------------------------------------------------------
+func (h *FolderHandler) Create(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	var req CreateFolderRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	id, err := h.Service.Create(userID, req.ParentID, req.Name)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(201, gin.H{"id": id})
+}
+
+// -------------------------------------------------------------
+// GET /api/folders/:id?recursive=true&page=1&limit=50
+// Returns the folder, its subfolders and one page of notes
+// -------------------------------------------------------------
+func (h *FolderHandler) Contents(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	folderID := toInt64(ctx.Param("id"))
+
+	var query FolderContentsQuery
+	if ctx.ShouldBindQuery(&query) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 {
+		query.Limit = defaultPageLimit
+	}
+	if query.Limit > maxPageLimit {
+		query.Limit = maxPageLimit
+	}
+
+	contents, err := h.Service.Contents(userID, folderID, query.Recursive, query.Page, query.Limit)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, contents)
+}
+
+// -------------------------------------------------------------
+// PUT /api/folders/:id
+// Renames a folder
+// -------------------------------------------------------------
+func (h *FolderHandler) Rename(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	folderID := toInt64(ctx.Param("id"))
+
+	var req RenameFolderRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	if err := h.Service.Rename(userID, folderID, req.Name); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "renamed"})
+}
+
+// -------------------------------------------------------------
+// POST /api/folders/:id/move
+// Moves a folder under another parent
+// -------------------------------------------------------------
+func (h *FolderHandler) Move(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	folderID := toInt64(ctx.Param("id"))
+
+	var req MoveFolderRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	if err := h.Service.Move(userID, folderID, req.ParentID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "moved"})
+}
+
+// -------------------------------------------------------------
+// DELETE /api/folders/:id?mode=cascade|move_to_parent
+// -------------------------------------------------------------
+func (h *FolderHandler) Delete(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	folderID := toInt64(ctx.Param("id"))
+
+	var query DeleteFolderQuery
+	if ctx.ShouldBindQuery(&query) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	if err := h.Service.Delete(userID, folderID, query.Mode); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "deleted"})
+}
+
+// -------------------------------------------------------------
+// PUT /api/notes/:id/folder
+// Moves a note into a folder (or to the top level with null)
+// -------------------------------------------------------------
+func (h *FolderHandler) MoveNote(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	var req MoveNoteRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	if err := h.Service.MoveNote(userID, noteID, req.FolderID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "moved"})
+}
@@ -0,0 +1,15 @@
+package folders
+
+import "github.com/gin-gonic/gin"
+
+// Protected routes (requires JWT)
+func RegisterFolderRoutes(r *gin.RouterGroup, handler *FolderHandler) {
+	r.GET("/folders", handler.List)
+	r.POST("/folders", handler.Create)
+	r.GET("/folders/:id", handler.Contents)
+	r.PUT("/folders/:id", handler.Rename)
+	r.POST("/folders/:id/move", handler.Move)
+	r.DELETE("/folders/:id", handler.Delete)
+
+	r.PUT("/notes/:id/folder", handler.MoveNote)
+}
@@ -0,0 +1,77 @@
+package importer
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// maxImportRequestSize caps the whole multipart upload.
+const maxImportRequestSize = 64 << 20
+
+// ImportHandler handles note import endpoints.
+type ImportHandler struct {
+	Service *service.ImportService
+}
+
+func NewImportHandler(service *service.ImportService) *ImportHandler {
+	return &ImportHandler{Service: service}
+}
+
+// -------------------------------------------------------------
+// POST /api/import
+// Imports notes from uploaded files (multipart field "files"):
+// Markdown, plain text, Evernote ENEX, Google Keep Takeout
+// (JSON/HTML), Notion exports (Markdown/CSV) or ZIP archives
+// of those.
+// Returns a report per file.
+// -------------------------------------------------------------
+func (h *ImportHandler) Import(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportRequestSize)
+
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid upload (max 64 MB)"})
+		return
+	}
+
+	headers := append(form.File["files"], form.File["file"]...)
+	if len(headers) == 0 {
+		ctx.JSON(400, gin.H{"error": "no files uploaded"})
+		return
+	}
+
+	files := make([]service.ImportFile, 0, len(headers))
+	for _, fh := range headers {
+		data, err := readUpload(fh)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "could not read " + fh.Filename})
+			return
+		}
+		files = append(files, service.ImportFile{Name: fh.Filename, Data: data})
+	}
+
+	results := h.Service.Import(userID, files)
+
+	imported := 0
+	for _, r := range results {
+		imported += len(r.Notes)
+	}
+
+	ctx.JSON(200, gin.H{"results": results, "imported": imported})
+}
+
+func readUpload(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
@@ -0,0 +1,8 @@
+package importer
+
+import "github.com/gin-gonic/gin"
+
+// Protected routes (requires JWT)
+func RegisterImportRoutes(r *gin.RouterGroup, handler *ImportHandler) {
+	r.POST("/import", handler.Import)
+}
@@ -0,0 +1,42 @@
+package keys
+
+// PutKeysDTO registers or replaces the user's key pair. The private key is
+// encrypted with the master key on the client.
+type PutKeysDTO struct {
+	PublicKey            string `json:"public_key" binding:"required"`
+	PrivateKeyCiphertext string `json:"private_key_ciphertext" binding:"required"`
+	PrivateKeyNonce      string `json:"private_key_nonce" binding:"required"`
+}
+
+// PutKeyCheckDTO sets the key check. replaces, when present, is the
+// ciphertext the client expects to overwrite ("" = none yet); the update
+// is refused with 409 if another client changed it meanwhile. kdf, when
+// present, must be the user's current KDF parameters: they only change
+// through POST /api/encrypted-notes/rekey.
+type PutKeyCheckDTO struct {
+	Ciphertext string        `json:"ciphertext" binding:"required"`
+	Nonce      string        `json:"nonce" binding:"required"`
+	KDF        *KDFParamsDTO `json:"kdf"`
+	Replaces   *string       `json:"replaces"`
+}
+
+// KDFParamsDTO describes how the master key was derived. memory_kib and
+// parallelism are for Argon2id only.
+type KDFParamsDTO struct {
+	Algorithm   string `json:"algorithm" binding:"required"`
+	Iterations  int    `json:"iterations" binding:"required"`
+	MemoryKiB   int    `json:"memory_kib"`
+	Parallelism int    `json:"parallelism"`
+}
+
+// PutRecoveryKeyDTO sets or rotates the recovery key: the master key
+// wrapped under a recovery key only the user keeps.
+type PutRecoveryKeyDTO struct {
+	WrappedMasterKey string `json:"wrapped_master_key" binding:"required"`
+	Nonce            string `json:"nonce" binding:"required"`
+}
+
+// RecoverDTO carries the token emailed by POST /api/me/recovery-key/token.
+type RecoverDTO struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,140 @@
+package keys
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// KeyHandler handles the key material kept for encrypted notes: key
+// pairs, the master key check and the recovery key.
+type KeyHandler struct {
+	Service  *service.UserKeyService
+	Recovery *service.RecoveryService
+}
+
+func NewKeyHandler(service *service.UserKeyService, recovery *service.RecoveryService) *KeyHandler {
+	return &KeyHandler{Service: service, Recovery: recovery}
+}
+
+// writeError maps key service errors to HTTP responses.
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrKeysNotFound), errors.Is(err, service.ErrRecoveryKeyNotFound):
+		ctx.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidPublicKey),
+		errors.Is(err, service.ErrInvalidPrivateKey),
+		errors.Is(err, service.ErrInvalidKeyCheck),
+		errors.Is(err, service.ErrInvalidRecoveryKey):
+		ctx.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidRecoveryToken):
+		ctx.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrKeyCheckChanged), errors.Is(err, service.ErrKDFChanged):
+		ctx.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrRecoveryTokenLimit):
+		ctx.JSON(429, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(500, gin.H{"error": "failed"})
+	}
+}
+
+// -------------------------------------------------------------
+// GET /api/me/keys
+// My key pair, private key still encrypted
+// -------------------------------------------------------------
+func (h *KeyHandler) Get(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	keys, err := h.Service.Get(userID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, keys)
+}
+
+// -------------------------------------------------------------
+// PUT /api/me/keys
+// Register or replace my key pair. A new public key drops the
+// encrypted notes shared with the old one.
+// -------------------------------------------------------------
+func (h *KeyHandler) Put(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	var dto PutKeysDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	keys, err := h.Service.Put(userID, model.UserKeys{
+		PublicKey:            dto.PublicKey,
+		PrivateKeyCiphertext: dto.PrivateKeyCiphertext,
+		PrivateKeyNonce:      dto.PrivateKeyNonce,
+	})
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, keys)
+}
+
+// -------------------------------------------------------------
+// PUT /api/me/key-check
+// Set or replace my master key check. The current value is
+// part of GET /api/me.
+// -------------------------------------------------------------
+func (h *KeyHandler) PutKeyCheck(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	var dto PutKeyCheckDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	var kdf *model.KDFParams
+	if dto.KDF != nil {
+		kdf = &model.KDFParams{
+			Algorithm:   dto.KDF.Algorithm,
+			Iterations:  dto.KDF.Iterations,
+			MemoryKiB:   dto.KDF.MemoryKiB,
+			Parallelism: dto.KDF.Parallelism,
+		}
+	}
+
+	kc, err := h.Service.SetKeyCheck(userID, model.KeyCheck{
+		Ciphertext: dto.Ciphertext,
+		Nonce:      dto.Nonce,
+	}, kdf, dto.Replaces)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, kc)
+}
+
+// -------------------------------------------------------------
+// GET /api/users/public-key?email=
+// Public key of the user to share an encrypted note with
+// -------------------------------------------------------------
+func (h *KeyHandler) FindPublicKey(ctx *gin.Context) {
+	email := ctx.Query("email")
+	if email == "" {
+		ctx.JSON(400, gin.H{"error": "email is required"})
+		return
+	}
+
+	key, err := h.Service.FindPublicKey(email)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, key)
+}
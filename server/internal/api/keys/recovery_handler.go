@@ -0,0 +1,93 @@
+package keys
+
+import "github.com/gin-gonic/gin"
+
+// -------------------------------------------------------------
+// GET /api/me/recovery-key
+// Whether a recovery key is set. The wrapped key is only
+// returned by POST /api/me/recovery-key/recover.
+// -------------------------------------------------------------
+func (h *KeyHandler) RecoveryStatus(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	status, err := h.Recovery.Status(userID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, status)
+}
+
+// -------------------------------------------------------------
+// PUT /api/me/recovery-key
+// Set or rotate the recovery key
+// -------------------------------------------------------------
+func (h *KeyHandler) PutRecoveryKey(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	var dto PutRecoveryKeyDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	status, err := h.Recovery.Put(userID, dto.WrappedMasterKey, dto.Nonce)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, status)
+}
+
+// -------------------------------------------------------------
+// DELETE /api/me/recovery-key
+// -------------------------------------------------------------
+func (h *KeyHandler) DeleteRecoveryKey(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	if err := h.Recovery.Delete(userID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "deleted"})
+}
+
+// -------------------------------------------------------------
+// POST /api/me/recovery-key/token
+// Email a one-time token for recovering the master key
+// -------------------------------------------------------------
+func (h *KeyHandler) RequestRecoveryToken(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	if err := h.Recovery.RequestToken(userID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "sent"})
+}
+
+// -------------------------------------------------------------
+// POST /api/me/recovery-key/recover
+// Exchange an emailed token for the wrapped master key
+// -------------------------------------------------------------
+func (h *KeyHandler) Recover(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	var dto RecoverDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	key, err := h.Recovery.Recover(userID, dto.Token)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, key)
+}
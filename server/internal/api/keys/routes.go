@@ -0,0 +1,17 @@
+package keys
+
+import "github.com/gin-gonic/gin"
+
+// Protected routes (requires JWT)
+func RegisterKeyRoutes(r *gin.RouterGroup, handler *KeyHandler) {
+	r.GET("/me/keys", handler.Get)
+	r.PUT("/me/keys", handler.Put)
+	r.PUT("/me/key-check", handler.PutKeyCheck)
+
+	r.GET("/me/recovery-key", handler.RecoveryStatus)
+	r.PUT("/me/recovery-key", handler.PutRecoveryKey)
+	r.DELETE("/me/recovery-key", handler.DeleteRecoveryKey)
+	r.POST("/me/recovery-key/token", handler.RequestRecoveryToken)
+	r.POST("/me/recovery-key/recover", handler.Recover)
+	r.GET("/users/public-key", handler.FindPublicKey)
+}
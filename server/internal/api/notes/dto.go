@@ -1,25 +1,57 @@
 package notes
 
 type CreateNoteRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Title    string   `json:"title"`
+	Content  string   `json:"content"`
+	Tags     []string `json:"tags"`
+	FolderID *int64   `json:"folder_id"`
 }
 
 type UpdateNoteRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`    // nil = keep current tags, [] = remove all
+	Version *int64   `json:"version"` // alternative to If-Match, conflicts answer 409
 }
 
 type UpdateNoteFlagsRequest struct {
-	IsPinned   *bool `json:"is_pinned"`
-	IsArchived *bool `json:"is_archived"`
-	IsDeleted  *bool `json:"is_deleted"`
+	IsPinned   *bool  `json:"is_pinned"`
+	IsArchived *bool  `json:"is_archived"`
+	IsDeleted  *bool  `json:"is_deleted"`
+	Version    *int64 `json:"version"`
 }
 
-type SearchNotesRequest struct {
-	Query string `json:"query" binding:"required"`
+// SearchRequest is the body of POST /notes/search.
+// Query supports words, "phrases", prefix* and AND / OR / NOT.
+type SearchRequest struct {
+	Query    string `json:"query" binding:"required"`
+	Archived *bool  `json:"archived"`
+	Pinned   *bool  `json:"pinned"`
+	Tag      string `json:"tag"`
+	Limit    int    `json:"limit"`
 }
 
-type SearchRequest struct {
-	Query string `json:"query" binding:"required"`
+// RestoreTrashRequest is the body of POST /notes/trash/restore.
+// Either IDs lists the notes to restore or All restores the whole trash.
+type RestoreTrashRequest struct {
+	IDs []int64 `json:"ids"`
+	All bool    `json:"all"`
+}
+
+// DiffRevisionsRequest holds the query of GET /notes/:id/revisions/diff.
+// From is a pointer because 0, the current note, is a valid value.
+type DiffRevisionsRequest struct {
+	From *int64 `form:"from"`
+	To   int64  `form:"to"`
+}
+
+// ListNotesQuery holds the query parameters of GET /notes and GET /notes/meta.
+type ListNotesQuery struct {
+	Cursor   string `form:"cursor"`
+	Limit    int    `form:"limit"`
+	Sort     string `form:"sort"` // updated (default), created, title, pinned
+	Archived *bool  `form:"archived"`
+	Trashed  *bool  `form:"trashed"`
+	Pinned   *bool  `form:"pinned"`
+	Tag      string `form:"tag"`
 }
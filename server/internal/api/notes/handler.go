@@ -1,10 +1,15 @@
 package notes
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/pkg/etag"
+	"github.com/shamal-iroshan/notora/internal/pkg/pagination"
+	"github.com/shamal-iroshan/notora/internal/pkg/search"
 	"github.com/shamal-iroshan/notora/internal/service"
 )
 
@@ -26,7 +31,20 @@ func (h *NoteHandler) Create(ctx *gin.Context) {
 		return
 	}
 
-	id, err := h.Service.Create(userID, req.Title, req.Content)
+	id, err := h.Service.Create(userID, model.CreateNoteInput{
+		Title:    req.Title,
+		Content:  req.Content,
+		Tags:     req.Tags,
+		FolderID: req.FolderID,
+	})
+	if errors.Is(err, service.ErrInvalidTagName) {
+		ctx.JSON(400, gin.H{"error": "invalid tag name"})
+		return
+	}
+	if errors.Is(err, service.ErrFolderNotFound) {
+		ctx.JSON(400, gin.H{"error": "folder not found"})
+		return
+	}
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": "could not create"})
 		return
@@ -46,43 +64,26 @@ func (h *NoteHandler) Get(ctx *gin.Context) {
 		return
 	}
 
+	ctx.Header("ETag", etag.Format(note.Version))
 	ctx.JSON(200, gin.H{"note": note})
 }
 
-// Get all
+// Get all (paginated)
 func (h *NoteHandler) GetAll(ctx *gin.Context) {
 	userID := ctx.GetInt64("user_id")
 
-	rows, err := h.Service.GetAll(userID)
-	if err != nil {
-		ctx.JSON(500, gin.H{"error": "failed"})
+	input, ok := bindListInput(ctx)
+	if !ok {
 		return
 	}
-	defer rows.Close()
-
-	var notes []interface{}
 
-	for rows.Next() {
-		var (
-			id                        int64
-			title, content            string
-			pinned, archived, deleted int
-			createdAt, updatedAt      string
-		)
-
-		rows.Scan(&id, &title, &content, &pinned, &archived, &deleted, &createdAt, &updatedAt)
-
-		notes = append(notes, gin.H{
-			"id": id, "title": title, "content": content,
-			"is_pinned":   pinned == 1,
-			"is_archived": archived == 1,
-			"is_deleted":  deleted == 1,
-			"created_at":  createdAt,
-			"updated_at":  updatedAt,
-		})
+	notes, next, err := h.Service.GetAll(userID, input)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed"})
+		return
 	}
 
-	ctx.JSON(200, gin.H{"notes": notes})
+	ctx.JSON(200, gin.H{"notes": notes, "next_cursor": next})
 }
 
 // Update text
@@ -96,12 +97,29 @@ func (h *NoteHandler) Update(ctx *gin.Context) {
 		return
 	}
 
-	if err := h.Service.Update(userID, noteID, req.Title, req.Content); err != nil {
-		ctx.JSON(500, gin.H{"error": "failed"})
+	versions, conflictStatus, err := etag.IfMatch(ctx.GetHeader("If-Match"), req.Version)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.JSON(200, gin.H{"status": "updated"})
+	version, err := h.Service.Update(userID, noteID, model.UpdateNoteInput{
+		Title:   req.Title,
+		Content: req.Content,
+		Tags:    req.Tags,
+		IfMatch: versions,
+	})
+	if errors.Is(err, service.ErrInvalidTagName) {
+		ctx.JSON(400, gin.H{"error": "invalid tag name"})
+		return
+	}
+	if err != nil {
+		h.writeUpdateError(ctx, userID, noteID, conflictStatus, err)
+		return
+	}
+
+	ctx.Header("ETag", etag.Format(version))
+	ctx.JSON(200, gin.H{"status": "updated", "version": version})
 }
 
 // Update flags (pin/archive/delete)
@@ -115,12 +133,20 @@ func (h *NoteHandler) UpdateFlags(ctx *gin.Context) {
 		return
 	}
 
-	if err := h.Service.UpdateFlags(userID, noteID, req.IsPinned, req.IsArchived, req.IsDeleted); err != nil {
-		ctx.JSON(500, gin.H{"error": "failed"})
+	versions, conflictStatus, err := etag.IfMatch(ctx.GetHeader("If-Match"), req.Version)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, err := h.Service.UpdateFlags(userID, noteID, req.IsPinned, req.IsArchived, req.IsDeleted, versions...)
+	if err != nil {
+		h.writeUpdateError(ctx, userID, noteID, conflictStatus, err)
 		return
 	}
 
-	ctx.JSON(200, gin.H{"status": "updated"})
+	ctx.Header("ETag", etag.Format(version))
+	ctx.JSON(200, gin.H{"status": "updated", "version": version})
 }
 
 // Delete permanently
@@ -141,6 +167,27 @@ func toInt64(s string) int64 {
 	return val
 }
 
+// writeUpdateError answers a failed update. On a version conflict the
+// current server copy and its version are returned.
+func (h *NoteHandler) writeUpdateError(ctx *gin.Context, userID, noteID int64, conflictStatus int, err error) {
+	switch {
+	case errors.Is(err, service.ErrNoteNotFound):
+		ctx.JSON(404, gin.H{"error": "not found"})
+	case errors.Is(err, service.ErrNoteReadOnly):
+		ctx.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrVersionConflict):
+		note, err := h.Service.Get(userID, noteID)
+		if err != nil {
+			ctx.JSON(404, gin.H{"error": "not found"})
+			return
+		}
+		ctx.Header("ETag", etag.Format(note.Version))
+		ctx.JSON(conflictStatus, gin.H{"error": "version conflict", "version": note.Version, "note": note})
+	default:
+		ctx.JSON(500, gin.H{"error": "failed"})
+	}
+}
+
 func (h *NoteHandler) Duplicate(ctx *gin.Context) {
 	userID := ctx.GetInt64("user_id")
 	noteID := toInt64(ctx.Param("id"))
@@ -157,36 +204,50 @@ func (h *NoteHandler) Duplicate(ctx *gin.Context) {
 func (h *NoteHandler) Metadata(ctx *gin.Context) {
 	userID := ctx.GetInt64("user_id")
 
-	rows, err := h.Service.Metadata(userID)
+	input, ok := bindListInput(ctx)
+	if !ok {
+		return
+	}
+
+	notes, next, err := h.Service.Metadata(userID, input)
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": "failed"})
 		return
 	}
-	defer rows.Close()
 
-	var notes []interface{}
+	ctx.JSON(200, gin.H{"notes": notes, "next_cursor": next})
+}
 
-	for rows.Next() {
-		var (
-			id                        int64
-			title                     string
-			updatedAt                 string
-			pinned, archived, deleted int
-		)
+// bindListInput parses the listing query parameters (sort, cursor, limit and
+// filters). On failure it writes a 400 response and returns false.
+func bindListInput(ctx *gin.Context) (model.ListNotesInput, bool) {
+	var query ListNotesQuery
+	if ctx.ShouldBindQuery(&query) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid query parameters"})
+		return model.ListNotesInput{}, false
+	}
 
-		rows.Scan(&id, &title, &updatedAt, &pinned, &archived, &deleted)
+	sort, err := pagination.ParseSort(query.Sort)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "sort must be one of updated, created, title, pinned"})
+		return model.ListNotesInput{}, false
+	}
 
-		notes = append(notes, gin.H{
-			"id":          id,
-			"title":       title,
-			"updated_at":  updatedAt,
-			"is_pinned":   pinned == 1,
-			"is_archived": archived == 1,
-			"is_deleted":  deleted == 1,
-		})
+	after, err := pagination.Decode(query.Cursor, sort)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid cursor"})
+		return model.ListNotesInput{}, false
 	}
 
-	ctx.JSON(200, gin.H{"notes": notes})
+	return model.ListNotesInput{
+		Sort:     sort,
+		After:    after,
+		Limit:    query.Limit,
+		Archived: query.Archived,
+		Trashed:  query.Trashed,
+		Pinned:   query.Pinned,
+		Tag:      query.Tag,
+	}, true
 }
 
 // Search handles POST /notes/search
@@ -199,7 +260,17 @@ func (h *NoteHandler) Search(ctx *gin.Context) {
 		return
 	}
 
-	notes, err := h.Service.Search(userID, req.Query)
+	notes, err := h.Service.Search(userID, model.SearchNotesInput{
+		Query:    req.Query,
+		Archived: req.Archived,
+		Pinned:   req.Pinned,
+		Tag:      req.Tag,
+		Limit:    req.Limit,
+	})
+	if errors.Is(err, search.ErrInvalidQuery) {
+		ctx.JSON(400, gin.H{"error": "invalid query"})
+		return
+	}
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": "search failed"})
 		return
@@ -207,3 +278,117 @@ func (h *NoteHandler) Search(ctx *gin.Context) {
 
 	ctx.JSON(200, gin.H{"results": notes})
 }
+
+// ListRevisions handles GET /notes/:id/revisions
+func (h *NoteHandler) ListRevisions(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	revisions, err := h.Service.ListRevisions(userID, noteID)
+	if err != nil {
+		writeRevisionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"revisions": revisions})
+}
+
+// GetRevision handles GET /notes/:id/revisions/:rev
+func (h *NoteHandler) GetRevision(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	revision := toInt64(ctx.Param("rev"))
+
+	rv, err := h.Service.GetRevision(userID, noteID, revision)
+	if err != nil {
+		writeRevisionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"revision": rv})
+}
+
+// DiffRevisions handles GET /notes/:id/revisions/diff?from=<rev>&to=<rev>
+// Omitting "to" (or passing 0) compares against the current note.
+func (h *NoteHandler) DiffRevisions(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	var req DiffRevisionsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil || req.From == nil {
+		ctx.JSON(400, gin.H{"error": "from is required"})
+		return
+	}
+
+	lines, err := h.Service.DiffRevisions(userID, noteID, *req.From, req.To)
+	if err != nil {
+		writeRevisionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"from": *req.From, "to": req.To, "diff": lines})
+}
+
+// RestoreRevision handles POST /notes/:id/revisions/:rev/restore
+func (h *NoteHandler) RestoreRevision(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	revision := toInt64(ctx.Param("rev"))
+
+	if err := h.Service.RestoreRevision(userID, noteID, revision); err != nil {
+		writeRevisionError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "restored"})
+}
+
+// writeRevisionError answers a failed revision request.
+func writeRevisionError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrNoteNotFound), errors.Is(err, service.ErrRevisionNotFound):
+		ctx.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNoteReadOnly):
+		ctx.JSON(403, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(500, gin.H{"error": "failed"})
+	}
+}
+
+// EmptyTrash handles POST /notes/trash/empty
+func (h *NoteHandler) EmptyTrash(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	deleted, err := h.Service.EmptyTrash(userID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "emptied", "deleted": deleted})
+}
+
+// RestoreFromTrash handles POST /notes/trash/restore
+// Body: {"ids": [1, 2]} or {"all": true}
+func (h *NoteHandler) RestoreFromTrash(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	var req RestoreTrashRequest
+	if ctx.ShouldBindJSON(&req) != nil || (len(req.IDs) == 0 && !req.All) {
+		ctx.JSON(400, gin.H{"error": "ids or all required"})
+		return
+	}
+
+	ids := req.IDs
+	if req.All {
+		ids = nil
+	}
+
+	restored, err := h.Service.RestoreFromTrash(userID, ids)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "restored", "restored": restored})
+}
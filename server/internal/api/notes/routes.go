@@ -2,8 +2,8 @@ package notes
 
 import "github.com/gin-gonic/gin"
 
-func RegisterNoteRoutes(router *gin.RouterGroup, handler *NoteHandler, authMiddleware gin.HandlerFunc) {
-	router.Use(authMiddleware)
+func RegisterNoteRoutes(router *gin.RouterGroup, handler *NoteHandler, middlewares ...gin.HandlerFunc) {
+	router.Use(middlewares...)
 
 	router.POST("/notes", handler.Create)
 	router.GET("/notes", handler.GetAll)
@@ -15,4 +15,11 @@ func RegisterNoteRoutes(router *gin.RouterGroup, handler *NoteHandler, authMiddl
 	router.DELETE("/notes/:id", handler.DeleteForever)
 	router.POST("/notes/search", handler.Search)
 
+	router.POST("/notes/trash/empty", handler.EmptyTrash)
+	router.POST("/notes/trash/restore", handler.RestoreFromTrash)
+
+	router.GET("/notes/:id/revisions", handler.ListRevisions)
+	router.GET("/notes/:id/revisions/diff", handler.DiffRevisions)
+	router.GET("/notes/:id/revisions/:rev", handler.GetRevision)
+	router.POST("/notes/:id/revisions/:rev/restore", handler.RestoreRevision)
 }
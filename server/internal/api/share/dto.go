@@ -1,15 +1,43 @@
 package share
 
+import "github.com/shamal-iroshan/notora/internal/model"
+
+// ----- REQUEST DTOs -----
+
+// ShareOptionsDTO sets the label and limits of a share link. Omitted fields
+// are left unchanged; "" or 0 removes a limit.
+type ShareOptionsDTO struct {
+	Label     *string `json:"label"`
+	ExpiresAt *string `json:"expires_at"`
+	Password  *string `json:"password"`
+	MaxViews  *int64  `json:"max_views"`
+}
+
+type UnlockShareDTO struct {
+	Password string `json:"password" binding:"required"`
+}
+
 // ----- RESPONSE DTOs -----
 
 type ShareResponse struct {
-	ShareURL string `json:"share_url"`
+	ShareURL string       `json:"share_url"`
+	Share    *model.Share `json:"share"`
+}
+
+type RevokeShareResponse struct {
+	Status string `json:"status"`
 }
 
 type DisableShareResponse struct {
 	Status string `json:"status"`
 }
 
+type UnlockShareResponse struct {
+	Access    string `json:"access"`
+	ExpiresAt string `json:"expires_at"`
+}
+
 type PublicSharedNoteResponse struct {
-	Note interface{} `json:"note"` // Could replace with a concrete Note model later
+	Note        interface{}        `json:"note"` // Could replace with a concrete Note model later
+	Attachments []model.Attachment `json:"attachments"`
 }
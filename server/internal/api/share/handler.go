@@ -1,11 +1,14 @@
 package share
 
 import (
-	"fmt"
+	"errors"
+	"io"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	attachmentapi "github.com/shamal-iroshan/notora/internal/api/attachments"
 	"github.com/shamal-iroshan/notora/internal/config"
+	"github.com/shamal-iroshan/notora/internal/model"
 	"github.com/shamal-iroshan/notora/internal/service"
 )
 
@@ -29,37 +32,133 @@ func toInt64(s string) int64 {
 	return v
 }
 
+// Helper to get the access grant of a protected share link
+func accessGrant(ctx *gin.Context) string {
+	if access := ctx.GetHeader("X-Share-Access"); access != "" {
+		return access
+	}
+	return ctx.Query("access")
+}
+
 // -------------------------------------------------------------
-// POST /api/notes/:id/share  (Protected)
-// Creates a shareable link for a note
+// POST /api/notes/:id/shares  (Protected)
+// POST /api/notes/:id/share
+// Creates a shareable link for a note. The optional body sets a
+// label, an expiry time, a password and a view limit.
 // -------------------------------------------------------------
 func (h *ShareHandler) CreateShare(ctx *gin.Context) {
 	userID := ctx.GetInt64("user_id")
 	noteID := toInt64(ctx.Param("id"))
 
-	token, err := h.Service.CreateShare(userID, noteID)
+	var req ShareOptionsDTO
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, err := h.Service.CreateShare(userID, noteID, model.ShareOptionsInput(req))
 	if err != nil {
-		ctx.JSON(403, gin.H{"error": "not allowed"})
+		writeError(ctx, err)
 		return
 	}
 
-	shareURL := fmt.Sprintf("%s/api/share/%s", h.CFG.AppBaseURL, token)
+	ctx.JSON(200, ShareResponse{
+		ShareURL: share.URL,
+		Share:    share,
+	})
+}
+
+// -------------------------------------------------------------
+// GET /api/notes/:id/shares  (Protected)
+// Lists the share links of a note with their view counts
+// -------------------------------------------------------------
+func (h *ShareHandler) ListShares(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+
+	shares, err := h.Service.ListShares(userID, noteID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, shares)
+}
+
+// -------------------------------------------------------------
+// PATCH /api/notes/:id/shares/:shareId  (Protected)
+// Changes the label, expiry, password or view limit of a share link
+// -------------------------------------------------------------
+func (h *ShareHandler) UpdateShare(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	shareID := toInt64(ctx.Param("shareId"))
+
+	var req ShareOptionsDTO
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, err := h.Service.UpdateShare(userID, noteID, shareID, model.ShareOptionsInput(req))
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, share)
+}
+
+// -------------------------------------------------------------
+// POST /api/notes/:id/shares/:shareId/rotate  (Protected)
+// Replaces the token of a share link, invalidating its old URL
+// -------------------------------------------------------------
+func (h *ShareHandler) RotateShare(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	shareID := toInt64(ctx.Param("shareId"))
+
+	share, err := h.Service.RotateShare(userID, noteID, shareID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
 
 	ctx.JSON(200, ShareResponse{
-		ShareURL: shareURL,
+		ShareURL: share.URL,
+		Share:    share,
+	})
+}
+
+// -------------------------------------------------------------
+// DELETE /api/notes/:id/shares/:shareId  (Protected)
+// Revokes one share link of the note
+// -------------------------------------------------------------
+func (h *ShareHandler) RevokeShare(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	noteID := toInt64(ctx.Param("id"))
+	shareID := toInt64(ctx.Param("shareId"))
+
+	if err := h.Service.RevokeShare(userID, noteID, shareID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, RevokeShareResponse{
+		Status: "share_revoked",
 	})
 }
 
 // -------------------------------------------------------------
 // DELETE /api/notes/:id/share  (Protected)
-// Disables sharable link for the note
+// Disables all sharable links for the note
 // -------------------------------------------------------------
 func (h *ShareHandler) DisableShare(ctx *gin.Context) {
 	userID := ctx.GetInt64("user_id")
 	noteID := toInt64(ctx.Param("id"))
 
 	if err := h.Service.DisableShare(userID, noteID); err != nil {
-		ctx.JSON(403, gin.H{"error": "not allowed"})
+		writeError(ctx, err)
 		return
 	}
 
@@ -70,18 +169,86 @@ func (h *ShareHandler) DisableShare(ctx *gin.Context) {
 
 // -------------------------------------------------------------
 // GET /api/share/:token  (Public)
-// Public endpoint that fetches a shared note
+// Public endpoint that fetches a shared note. Password protected
+// links need the grant from the unlock endpoint in the X-Share-Access
+// header or the access query parameter.
 // -------------------------------------------------------------
 func (h *ShareHandler) PublicGet(ctx *gin.Context) {
 	token := ctx.Param("token")
 
-	note, err := h.Service.GetSharedNote(token)
+	shared, err := h.Service.GetSharedNote(token, accessGrant(ctx))
 	if err != nil {
-		ctx.JSON(404, gin.H{"error": "not found"})
+		writeError(ctx, err)
+		return
+	}
+
+	if notModified(ctx, shared) {
 		return
 	}
 
 	ctx.JSON(200, PublicSharedNoteResponse{
-		Note: note,
+		Note:        shared.Note,
+		Attachments: shared.Attachments,
+	})
+}
+
+// -------------------------------------------------------------
+// GET /api/share/:token/attachments/:id  (Public)
+// Downloads an attachment of a shared note
+// -------------------------------------------------------------
+func (h *ShareHandler) PublicAttachment(ctx *gin.Context) {
+	token := ctx.Param("token")
+	attachmentID := toInt64(ctx.Param("id"))
+
+	attachment, data, err := h.Service.GetSharedAttachment(token, accessGrant(ctx), attachmentID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	attachmentapi.Serve(ctx, attachment, data)
+}
+
+// -------------------------------------------------------------
+// POST /api/share/:token/unlock  (Public)
+// Checks the password of a share link and returns an access grant
+// -------------------------------------------------------------
+func (h *ShareHandler) Unlock(ctx *gin.Context) {
+	token := ctx.Param("token")
+
+	var req UnlockShareDTO
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	access, expiresAt, err := h.Service.Unlock(token, req.Password)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, UnlockShareResponse{
+		Access:    access,
+		ExpiresAt: expiresAt,
 	})
 }
+
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrShareNotAllowed):
+		ctx.JSON(403, gin.H{"error": "not allowed"})
+	case errors.Is(err, service.ErrInvalidShareOptions):
+		ctx.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrSharePasswordRequired), errors.Is(err, service.ErrShareWrongPassword):
+		ctx.JSON(401, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrShareAccessDenied):
+		ctx.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrShareExpired), errors.Is(err, service.ErrShareViewLimit):
+		ctx.JSON(410, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrShareNotFound), errors.Is(err, service.ErrAttachmentNotFound):
+		ctx.JSON(404, gin.H{"error": "not found"})
+	default:
+		ctx.JSON(500, gin.H{"error": "share request failed"})
+	}
+}
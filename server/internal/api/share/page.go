@@ -0,0 +1,197 @@
+package share
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/pkg/etag"
+	"github.com/shamal-iroshan/notora/internal/pkg/markdown"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+//go:embed templates/share.html
+var templates embed.FS
+
+var pageTemplate = template.Must(template.New("share.html").Funcs(template.FuncMap{
+	"size": formatSize,
+}).ParseFS(templates, "templates/share.html"))
+
+// pageCSP only allows the page's inline styles, images and the unlock form.
+// Sanitized notes carry no scripts, and this keeps it that way.
+const pageCSP = "default-src 'none'; img-src 'self' data: https:; style-src 'unsafe-inline'; " +
+	"form-action 'self'; base-uri 'none'; frame-ancestors 'none'"
+
+// accessCookie keeps the grant of an unlocked password link. Its path is
+// the link's page, so it is never sent anywhere else.
+const accessCookie = "share_access"
+
+// pageData is what the share page template renders: a note, the password
+// form or an error message.
+type pageData struct {
+	Note        *model.Note
+	Title       string
+	Description string
+	URL         string
+	Updated     string
+	Content     template.HTML
+	Attachments []model.Attachment
+
+	PasswordRequired bool
+	Error            string
+}
+
+// -------------------------------------------------------------
+// GET /s/:token  (Public)
+// Shows a shared note as a web page, or its Markdown with ?raw=1
+// -------------------------------------------------------------
+func (h *ShareHandler) Page(ctx *gin.Context) {
+	token := ctx.Param("token")
+
+	access := accessGrant(ctx)
+	if access == "" {
+		access, _ = ctx.Cookie(accessCookie)
+	}
+
+	shared, err := h.Service.GetSharedNote(token, access)
+	if err != nil {
+		h.renderError(ctx, err)
+		return
+	}
+
+	if notModified(ctx, shared) {
+		return
+	}
+
+	if ctx.Query("raw") == "1" {
+		ctx.Header("X-Content-Type-Options", "nosniff")
+		ctx.Data(200, "text/plain; charset=utf-8", []byte(shared.Note.Content))
+		return
+	}
+
+	content, err := markdown.ToHTML(shared.Note.Content)
+	if err != nil {
+		h.renderError(ctx, err)
+		return
+	}
+
+	title := shared.Note.Title
+	if title == "" {
+		title = "Untitled note"
+	}
+
+	description := markdown.Excerpt(content, 200)
+	if description == "" {
+		description = "A note shared with Notora"
+	}
+
+	h.render(ctx, 200, pageData{
+		Note:        shared.Note,
+		Title:       title,
+		Description: description,
+		URL:         h.Service.PageURL(token),
+		Updated:     formatDate(shared.Note.UpdatedAt),
+		Content:     template.HTML(content), // sanitized by the renderer
+		Attachments: shared.Attachments,
+	})
+}
+
+// -------------------------------------------------------------
+// POST /s/:token  (Public)
+// Unlocks a password protected share page from its password form
+// -------------------------------------------------------------
+func (h *ShareHandler) PageUnlock(ctx *gin.Context) {
+	token := ctx.Param("token")
+
+	access, expiresAt, err := h.Service.Unlock(token, ctx.PostForm("password"))
+	if err != nil {
+		h.renderError(ctx, err)
+		return
+	}
+
+	maxAge := 0
+	if expires, err := time.Parse(time.RFC3339, expiresAt); err == nil {
+		maxAge = int(time.Until(expires).Seconds())
+	}
+
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(accessCookie, access, maxAge, "/s/"+token, h.CFG.CookieDomain, h.CFG.CookieSecure, true)
+
+	// Post/Redirect/Get, so reloading the page doesn't resend the password
+	ctx.Redirect(303, "/s/"+token)
+}
+
+func (h *ShareHandler) render(ctx *gin.Context, status int, data pageData) {
+	ctx.Header("Content-Security-Policy", pageCSP)
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Header("Referrer-Policy", "no-referrer") // the URL holds the token
+
+	ctx.Status(status)
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	if err := pageTemplate.Execute(ctx.Writer, data); err != nil {
+		ctx.Error(err)
+	}
+}
+
+func (h *ShareHandler) renderError(ctx *gin.Context, err error) {
+	ctx.Header("Cache-Control", "no-store")
+
+	switch {
+	case errors.Is(err, service.ErrSharePasswordRequired):
+		h.render(ctx, 401, pageData{PasswordRequired: true})
+	case errors.Is(err, service.ErrShareWrongPassword):
+		h.render(ctx, 401, pageData{PasswordRequired: true, Error: "Wrong password, try again."})
+	case errors.Is(err, service.ErrShareExpired):
+		h.render(ctx, 410, pageData{Error: "This link has expired"})
+	case errors.Is(err, service.ErrShareViewLimit):
+		h.render(ctx, 410, pageData{Error: "This link has reached its view limit"})
+	case errors.Is(err, service.ErrShareNotFound):
+		h.render(ctx, 404, pageData{Error: "This note isn't shared anymore"})
+	default:
+		h.render(ctx, 500, pageData{Error: "Something went wrong"})
+	}
+}
+
+// notModified sets the cache headers of a shared note and answers a
+// conditional request with 304 when the client's copy is current.
+func notModified(ctx *gin.Context, shared *service.SharedNote) bool {
+	if shared.CacheFor <= 0 {
+		ctx.Header("Cache-Control", "private, no-store")
+		return false
+	}
+
+	tag := "W/" + etag.Format(shared.Note.Version)
+	ctx.Header("ETag", tag)
+	ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(shared.CacheFor.Seconds())))
+
+	if ctx.GetHeader("If-None-Match") == tag {
+		ctx.Status(304)
+		return true
+	}
+	return false
+}
+
+func formatDate(value string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return t.UTC().Format("January 2, 2006")
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return strconv.FormatFloat(float64(size)/(1<<20), 'f', 1, 64) + " MB"
+	case size >= 1<<10:
+		return strconv.FormatFloat(float64(size)/(1<<10), 'f', 1, 64) + " KB"
+	default:
+		return strconv.FormatInt(size, 10) + " B"
+	}
+}
@@ -8,9 +8,22 @@ import (
 func RegisterProtectedShareRoutes(r *gin.RouterGroup, handler *ShareHandler) {
 	r.POST("/notes/:id/share", handler.CreateShare)
 	r.DELETE("/notes/:id/share", handler.DisableShare)
+	r.GET("/notes/:id/shares", handler.ListShares)
+	r.POST("/notes/:id/shares", handler.CreateShare)
+	r.PATCH("/notes/:id/shares/:shareId", handler.UpdateShare)
+	r.DELETE("/notes/:id/shares/:shareId", handler.RevokeShare)
+	r.POST("/notes/:id/shares/:shareId/rotate", handler.RotateShare)
+}
+
+// Public share page, outside /api so links open in a browser
+func RegisterSharePageRoutes(r *gin.RouterGroup, handler *ShareHandler) {
+	r.GET("/s/:token", handler.Page)
+	r.POST("/s/:token", handler.PageUnlock)
 }
 
 // Public routes (no JWT)
 func RegisterPublicShareRoutes(r *gin.RouterGroup, handler *ShareHandler) {
 	r.GET("/share/:token", handler.PublicGet)
+	r.POST("/share/:token/unlock", handler.Unlock)
+	r.GET("/share/:token/attachments/:id", handler.PublicAttachment)
 }
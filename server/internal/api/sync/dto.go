@@ -0,0 +1,43 @@
+package sync
+
+// PullQuery holds the query parameters of GET /api/sync.
+type PullQuery struct {
+	Since string `form:"since"`
+	Limit int    `form:"limit"`
+}
+
+// PushRequest is a batch of offline changes. Since is the cursor of the
+// client's last pull; updates and deletes of entities changed after it are
+// reported as conflicts unless Force is set.
+type PushRequest struct {
+	Since   string       `json:"since"`
+	Force   bool         `json:"force"`
+	Changes []PushChange `json:"changes" binding:"required,dive"`
+}
+
+type PushChange struct {
+	Type          string             `json:"type" binding:"required,oneof=note encrypted_note"`
+	Op            string             `json:"op" binding:"required,oneof=create update delete"`
+	ID            int64              `json:"id"`
+	ClientID      string             `json:"client_id"`
+	Note          *PushNote          `json:"note"`
+	EncryptedNote *PushEncryptedNote `json:"encrypted_note"`
+}
+
+type PushNote struct {
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Tags       []string `json:"tags"`
+	IsPinned   *bool    `json:"is_pinned"`
+	IsArchived *bool    `json:"is_archived"`
+	IsDeleted  *bool    `json:"is_deleted"`
+}
+
+type PushEncryptedNote struct {
+	TitleCiphertext   string `json:"title"`
+	ContentCiphertext string `json:"content"`
+	TitleNonce        string `json:"title_nonce"`
+	ContentNonce      string `json:"content_nonce"`
+	NoteSalt          string `json:"note_salt"`
+	FormatVersion     int    `json:"format_version"`
+}
@@ -0,0 +1,109 @@
+package sync
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// SyncHandler handles the delta sync endpoints used by offline clients.
+type SyncHandler struct {
+	Service *service.SyncService
+}
+
+func NewSyncHandler(service *service.SyncService) *SyncHandler {
+	return &SyncHandler{Service: service}
+}
+
+// writeError maps sync service errors to HTTP responses.
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidSyncCursor):
+		ctx.JSON(400, gin.H{"error": "invalid cursor"})
+	case errors.Is(err, service.ErrSyncBatchTooLarge):
+		ctx.JSON(413, gin.H{"error": "too many changes"})
+	default:
+		ctx.JSON(500, gin.H{"error": "failed"})
+	}
+}
+
+// -------------------------------------------------------------
+// GET /api/sync?since=<cursor>
+// Returns the notes and encrypted notes created, updated or deleted
+// after the cursor, plus the cursor to use next time.
+// Without since, everything is returned (initial sync).
+// -------------------------------------------------------------
+func (h *SyncHandler) Pull(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	var query PullQuery
+	if ctx.ShouldBindQuery(&query) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	delta, err := h.Service.Pull(userID, query.Since, query.Limit)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, delta)
+}
+
+// -------------------------------------------------------------
+// POST /api/sync/push
+// Applies a batch of offline changes. Every item gets its own
+// result: applied, conflict (with the server version) or error.
+// -------------------------------------------------------------
+func (h *SyncHandler) Push(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	var req PushRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	items := make([]model.SyncPushItem, len(req.Changes))
+	for i, c := range req.Changes {
+		items[i] = model.SyncPushItem{
+			Type:     c.Type,
+			Op:       c.Op,
+			ID:       c.ID,
+			ClientID: c.ClientID,
+		}
+
+		if c.Note != nil {
+			items[i].Note = &model.SyncNoteFields{
+				Title:      c.Note.Title,
+				Content:    c.Note.Content,
+				Tags:       c.Note.Tags,
+				IsPinned:   c.Note.IsPinned,
+				IsArchived: c.Note.IsArchived,
+				IsDeleted:  c.Note.IsDeleted,
+			}
+		}
+
+		if c.EncryptedNote != nil {
+			items[i].EncryptedNote = &model.CreateEncryptedNoteInput{
+				TitleCiphertext:   c.EncryptedNote.TitleCiphertext,
+				ContentCiphertext: c.EncryptedNote.ContentCiphertext,
+				TitleNonce:        c.EncryptedNote.TitleNonce,
+				ContentNonce:      c.EncryptedNote.ContentNonce,
+				NoteSalt:          c.EncryptedNote.NoteSalt,
+				FormatVersion:     c.EncryptedNote.FormatVersion,
+			}
+		}
+	}
+
+	results, err := h.Service.Push(userID, req.Since, req.Force, items)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"results": results})
+}
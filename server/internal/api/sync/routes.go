@@ -0,0 +1,9 @@
+package sync
+
+import "github.com/gin-gonic/gin"
+
+// Protected routes (requires JWT)
+func RegisterSyncRoutes(r *gin.RouterGroup, handler *SyncHandler) {
+	r.GET("/sync", handler.Pull)
+	r.POST("/sync/push", handler.Push)
+}
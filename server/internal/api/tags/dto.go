@@ -0,0 +1,14 @@
+package tags
+
+type CreateTagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MergeTagsRequest merges the source tags into the tag from the URL.
+type MergeTagsRequest struct {
+	SourceIDs []int64 `json:"source_ids" binding:"required,min=1"`
+}
@@ -0,0 +1,152 @@
+package tags
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// TagHandler handles tag management endpoints.
+type TagHandler struct {
+	Service *service.TagService
+}
+
+func NewTagHandler(service *service.TagService) *TagHandler {
+	return &TagHandler{Service: service}
+}
+
+func toInt64(s string) int64 {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return v
+}
+
+// writeError maps tag service errors to HTTP responses.
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTagNotFound):
+		ctx.JSON(404, gin.H{"error": "not found"})
+	case errors.Is(err, service.ErrTagExists):
+		ctx.JSON(409, gin.H{"error": "tag already exists"})
+	case errors.Is(err, service.ErrInvalidTagName):
+		ctx.JSON(400, gin.H{"error": "invalid tag name"})
+	default:
+		ctx.JSON(500, gin.H{"error": "failed"})
+	}
+}
+
+// -------------------------------------------------------------
+// GET /api/tags
+// Lists the user's tags with note counts
+// -------------------------------------------------------------
+func (h *TagHandler) List(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	tags, err := h.Service.List(userID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"tags": tags})
+}
+
+// -------------------------------------------------------------
+// GET /api/tags/:id
+// -------------------------------------------------------------
+func (h *TagHandler) Get(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	tagID := toInt64(ctx.Param("id"))
+
+	tag, err := h.Service.Get(userID, tagID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"tag": tag})
+}
+
+// -------------------------------------------------------------
+// POST /api/tags
+// -------------------------------------------------------------
+func (h *TagHandler) Create(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+
+	var req CreateTagRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	id, err := h.Service.Create(userID, req.Name)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(201, gin.H{"id": id})
+}
+
+// -------------------------------------------------------------
+// PUT /api/tags/:id
+// Renames a tag. Renaming onto an existing name merges the tags,
+// the response contains the ID of the surviving tag.
+// -------------------------------------------------------------
+func (h *TagHandler) Rename(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	tagID := toInt64(ctx.Param("id"))
+
+	var req RenameTagRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	id, err := h.Service.Rename(userID, tagID, req.Name)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"id": id, "status": "renamed"})
+}
+
+// -------------------------------------------------------------
+// POST /api/tags/:id/merge
+// Moves all notes of the source tags onto this tag
+// -------------------------------------------------------------
+func (h *TagHandler) Merge(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	tagID := toInt64(ctx.Param("id"))
+
+	var req MergeTagsRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		ctx.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+
+	if err := h.Service.Merge(userID, req.SourceIDs, tagID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "merged"})
+}
+
+// -------------------------------------------------------------
+// DELETE /api/tags/:id
+// Removes the tag from all notes, notes are kept
+// -------------------------------------------------------------
+func (h *TagHandler) Delete(ctx *gin.Context) {
+	userID := ctx.GetInt64("user_id")
+	tagID := toInt64(ctx.Param("id"))
+
+	if err := h.Service.Delete(userID, tagID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "deleted"})
+}
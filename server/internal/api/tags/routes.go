@@ -0,0 +1,13 @@
+package tags
+
+import "github.com/gin-gonic/gin"
+
+// Protected routes (requires JWT)
+func RegisterTagRoutes(r *gin.RouterGroup, handler *TagHandler) {
+	r.GET("/tags", handler.List)
+	r.POST("/tags", handler.Create)
+	r.GET("/tags/:id", handler.Get)
+	r.PUT("/tags/:id", handler.Rename)
+	r.DELETE("/tags/:id", handler.Delete)
+	r.POST("/tags/:id/merge", handler.Merge)
+}
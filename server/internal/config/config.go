@@ -3,23 +3,57 @@ package config
 import (
 	"os"
 	"strconv"
+
+	"github.com/shamal-iroshan/notora/internal/pkg/encryption"
 )
 
 // Config holds all environment-driven configuration required
 // for running the application. These values are loaded once at startup.
 type Config struct {
-	Port                  string // Port the HTTP server listens on (e.g., "8000")
-	JWTSecret             string // Secret used to sign JWT access tokens
-	DBPath                string // Path to SQLite database file
-	DataDir               string // Directory where app data is stored (e.g., SQLite file)
-	CookieDomain          string // Domain for setting cookies (e.g., "localhost")
-	CookieSecure          bool   // Whether cookies require HTTPS (true in production)
-	AccessExpiry          int    // Access token lifetime in seconds
-	RefreshExpiry         int    // Refresh token lifetime in seconds
-	EncryptionKey         string // Server-side encryption key for notes
-	AppBaseURL            string // Base URL of the frontend app
-	EncryptedNotesEnabled bool
-	UserSaltLength        int
+	Port                   string // Port the HTTP server listens on (e.g., "8000")
+	JWTSecret              string // Secret used to sign JWT access tokens
+	DBPath                 string // Path to SQLite database file
+	DataDir                string // Directory where app data is stored (e.g., SQLite file)
+	CookieDomain           string // Domain for setting cookies (e.g., "localhost")
+	CookieSecure           bool   // Whether cookies require HTTPS (true in production)
+	AccessExpiry           int    // Access token lifetime in seconds
+	RefreshExpiry          int    // Refresh token lifetime in seconds
+	EncryptionKey          string // Server-side encryption key for notes, key ID "0" in the keyring
+	EncryptionKeys         string // More server-side keys as comma-separated id:key pairs
+	EncryptionKeyID        string // ID of the key new data is encrypted with
+	SearchIndexKey         string // Secret the search index is keyed with, kept across encryption key changes
+	AppBaseURL             string // Base URL of the frontend app
+	EncryptedNotesEnabled  bool
+	UserSaltLength         int
+	TrashRetentionDays     int // Days a trashed note is kept before it is purged (0 = keep forever)
+	TrashPurgeInterval     int // Seconds between runs of the trash purge worker
+	AttachmentMaxSizeMB    int // Largest accepted attachment upload, in MB
+	AttachmentQuotaMB      int // Attachment storage per user, in MB (0 = unlimited)
+	EncryptedRevisionsKept int // Revisions kept per encrypted note (0 = keep all)
+	EncryptedNoteMaxSizeKB int // Largest accepted encrypted note content, in KB of ciphertext
+
+	// Outgoing mail; without SMTPHost mails are only logged, without their body
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+
+	// Weakest master key derivation a client may switch to
+	KDFMinPBKDF2Iterations int
+	KDFMinArgon2Iterations int
+	KDFMinArgon2MemoryKiB  int
+	KDFMinArgon2Threads    int
+
+	// Costliest master key derivation a client may switch to (0 = no limit),
+	// so a typo can't lock a user out on their weaker devices
+	KDFMaxPBKDF2Iterations int
+	KDFMaxArgon2Iterations int
+	KDFMaxArgon2MemoryKiB  int
+	KDFMaxArgon2Threads    int
+
+	// Built from the encryption key settings by main
+	Keyring *encryption.Keyring
 }
 
 // getString retrieves a string value from the environment.
@@ -51,17 +85,39 @@ func getInt(key string, defaultValue int) int {
 // Default values are used when variables are not provided.
 func LoadFromEnv() *Config {
 	return &Config{
-		Port:                  getString("PORT", "8000"),
-		JWTSecret:             getString("JWT_SECRET", "dev_secret"),
-		DBPath:                getString("DB_PATH", "./data/app.db"),
-		DataDir:               getString("DATA_DIR", "./data"),
-		CookieDomain:          getString("COOKIE_DOMAIN", "localhost"),
-		CookieSecure:          getString("COOKIE_SECURE", "false") == "true",
-		EncryptionKey:         getString("ENCRYPTION_KEY", ""),
-		AppBaseURL:            getString("AppBaseURL", ""),
-		EncryptedNotesEnabled: getString("ENCRYPTED_NOTES_ENABLED", "true") == "true",
-		AccessExpiry:          getInt("ACCESS_EXPIRY", 300),
-		RefreshExpiry:         getInt("REFRESH_EXPIRY", 604800),
-		UserSaltLength:        getInt("ENCRYPTION_USER_SALT_LENGTH", 16),
+		Port:                   getString("PORT", "8000"),
+		JWTSecret:              getString("JWT_SECRET", "dev_secret"),
+		DBPath:                 getString("DB_PATH", "./data/app.db"),
+		DataDir:                getString("DATA_DIR", "./data"),
+		CookieDomain:           getString("COOKIE_DOMAIN", "localhost"),
+		CookieSecure:           getString("COOKIE_SECURE", "false") == "true",
+		EncryptionKey:          getString("ENCRYPTION_KEY", ""),
+		EncryptionKeys:         getString("ENCRYPTION_KEYS", ""),
+		EncryptionKeyID:        getString("ENCRYPTION_KEY_ID", ""),
+		SearchIndexKey:         getString("SEARCH_INDEX_KEY", getString("ENCRYPTION_KEY", "")),
+		AppBaseURL:             getString("AppBaseURL", ""),
+		EncryptedNotesEnabled:  getString("ENCRYPTED_NOTES_ENABLED", "true") == "true",
+		AccessExpiry:           getInt("ACCESS_EXPIRY", 300),
+		RefreshExpiry:          getInt("REFRESH_EXPIRY", 604800),
+		UserSaltLength:         getInt("ENCRYPTION_USER_SALT_LENGTH", 16),
+		TrashRetentionDays:     getInt("TRASH_RETENTION_DAYS", 30),
+		TrashPurgeInterval:     getInt("TRASH_PURGE_INTERVAL", 3600),
+		AttachmentMaxSizeMB:    getInt("ATTACHMENT_MAX_SIZE_MB", 25),
+		AttachmentQuotaMB:      getInt("ATTACHMENT_QUOTA_MB", 1024),
+		EncryptedRevisionsKept: getInt("ENCRYPTED_REVISIONS_KEPT", 50),
+		EncryptedNoteMaxSizeKB: getInt("ENCRYPTED_NOTE_MAX_SIZE_KB", 1024),
+		SMTPHost:               getString("SMTP_HOST", ""),
+		SMTPPort:               getInt("SMTP_PORT", 587),
+		SMTPUsername:           getString("SMTP_USERNAME", ""),
+		SMTPPassword:           getString("SMTP_PASSWORD", ""),
+		MailFrom:               getString("MAIL_FROM", "notora@localhost"),
+		KDFMinPBKDF2Iterations: getInt("KDF_MIN_PBKDF2_ITERATIONS", 250000),
+		KDFMinArgon2Iterations: getInt("KDF_MIN_ARGON2_ITERATIONS", 2),
+		KDFMinArgon2MemoryKiB:  getInt("KDF_MIN_ARGON2_MEMORY_KIB", 19456),
+		KDFMinArgon2Threads:    getInt("KDF_MIN_ARGON2_PARALLELISM", 1),
+		KDFMaxPBKDF2Iterations: getInt("KDF_MAX_PBKDF2_ITERATIONS", 10000000),
+		KDFMaxArgon2Iterations: getInt("KDF_MAX_ARGON2_ITERATIONS", 64),
+		KDFMaxArgon2MemoryKiB:  getInt("KDF_MAX_ARGON2_MEMORY_KIB", 1048576),
+		KDFMaxArgon2Threads:    getInt("KDF_MAX_ARGON2_PARALLELISM", 16),
 	}
 }
@@ -1,6 +1,9 @@
 package db
 
-import "database/sql"
+import (
+	"database/sql"
+	"fmt"
+)
 
 // Migrate runs all database schema migrations needed by the application.
 // If tables already exist, SQLite will ignore the creation (IF NOT EXISTS).
@@ -51,6 +54,22 @@ func Migrate(database *sql.DB) error {
 			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
 		);`,
 
+		// ----------------------------------------------------
+		// RECOVERY TOKENS TABLE
+		// One-time email tokens a signed-in user must present to
+		// download their wrapped master key (see users.recovery_*).
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS recovery_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT NOT NULL,
+			expires_at TEXT NOT NULL,
+			used INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_recovery_tokens_user_id ON recovery_tokens(user_id, created_at);`,
+
 		`CREATE TABLE IF NOT EXISTS notes (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			user_id INTEGER NOT NULL,
@@ -85,6 +104,234 @@ func Migrate(database *sql.DB) error {
 			updated_at TEXT NOT NULL,
 			FOREIGN KEY (user_id) REFERENCES users(id)
 		);`,
+
+		// ----------------------------------------------------
+		// NOTE REVISIONS TABLE
+		// Keeps an encrypted snapshot of a note every time it is
+		// updated, so previous contents can be diffed or restored.
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS note_revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			note_id INTEGER NOT NULL,
+			revision INTEGER NOT NULL,
+			title TEXT,
+			content TEXT,
+			created_at TEXT NOT NULL,
+			UNIQUE (note_id, revision),
+			FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+		);`,
+
+		// ----------------------------------------------------
+		// TAGS TABLES
+		// Tags are owned by a user and attached to notes through
+		// the note_tags join table. Names are unique per user
+		// (case-insensitive).
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL COLLATE NOCASE,
+			created_at TEXT NOT NULL,
+			UNIQUE (user_id, name),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS note_tags (
+			note_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (note_id, tag_id),
+			FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE,
+			FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_note_tags_tag_id ON note_tags(tag_id);`,
+
+		// ----------------------------------------------------
+		// FOLDERS TABLE
+		// Hierarchical notebooks. A NULL parent_id means the
+		// folder sits at the top level.
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS folders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			parent_id INTEGER,
+			name TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (parent_id) REFERENCES folders(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_folders_parent_id ON folders(parent_id);`,
+
+		// ----------------------------------------------------
+		// ATTACHMENT BLOBS
+		// One row per encrypted file under DATA_DIR/attachments.
+		// hash is a keyed hash of the plaintext, so identical
+		// uploads share one file.
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS attachment_blobs (
+			hash TEXT PRIMARY KEY,
+			size INTEGER NOT NULL,
+			created_at TEXT NOT NULL
+		);`,
+
+		// ----------------------------------------------------
+		// ATTACHMENTS TABLE
+		// Files attached to a note. Rows go with the note; blobs
+		// no attachment refers to anymore are collected after.
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS attachments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			note_id INTEGER NOT NULL,
+			blob_hash TEXT NOT NULL,
+			filename TEXT NOT NULL,
+			mime_type TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			created_at TEXT NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE,
+			FOREIGN KEY (blob_hash) REFERENCES attachment_blobs(hash)
+		);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_attachments_note_id ON attachments(note_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_attachments_user_id ON attachments(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_attachments_blob_hash ON attachments(blob_hash);`,
+
+		// ----------------------------------------------------
+		// NOTE COLLABORATORS TABLE
+		// Notes shared with other users. Viewers can read the
+		// note, editors can also change its title and content.
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS note_collaborators (
+			note_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL CHECK (role IN ('viewer', 'editor')),
+			invited_by INTEGER NOT NULL,
+			created_at TEXT NOT NULL,
+			PRIMARY KEY (note_id, user_id),
+			FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_note_collaborators_user_id ON note_collaborators(user_id);`,
+
+		// ----------------------------------------------------
+		// ENCRYPTED ATTACHMENTS
+		// Opaque, client-encrypted files of encrypted notes.
+		// Chunks are stored as-is under
+		// DATA_DIR/encrypted-attachments/<id>/<index>.
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS encrypted_attachments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			note_id INTEGER NOT NULL,
+			metadata_ciphertext TEXT NOT NULL,
+			metadata_nonce TEXT NOT NULL,
+			file_salt TEXT NOT NULL,
+			chunk_count INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			created_at TEXT NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (note_id) REFERENCES encrypted_notes(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_encrypted_attachments_note_id ON encrypted_attachments(note_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_encrypted_attachments_user_id ON encrypted_attachments(user_id);`,
+
+		`CREATE TABLE IF NOT EXISTS encrypted_attachment_chunks (
+			attachment_id INTEGER NOT NULL,
+			idx INTEGER NOT NULL,
+			nonce TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			PRIMARY KEY (attachment_id, idx),
+			FOREIGN KEY (attachment_id) REFERENCES encrypted_attachments(id) ON DELETE CASCADE
+		);`,
+
+		// ----------------------------------------------------
+		// ENCRYPTED NOTE KEYS TABLE
+		// Encrypted notes shared with other users. The note key
+		// is wrapped by the owner's client for the recipient's
+		// X25519 public key; the server never sees it unwrapped.
+		// note_salt is the salt the key was derived with.
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS encrypted_note_keys (
+			note_id INTEGER NOT NULL,
+			recipient_id INTEGER NOT NULL,
+			role TEXT NOT NULL CHECK (role IN ('viewer', 'editor')),
+			wrapped_key TEXT NOT NULL,
+			ephemeral_public_key TEXT NOT NULL,
+			wrap_nonce TEXT NOT NULL,
+			note_salt TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			PRIMARY KEY (note_id, recipient_id),
+			FOREIGN KEY (note_id) REFERENCES encrypted_notes(id) ON DELETE CASCADE,
+			FOREIGN KEY (recipient_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_encrypted_note_keys_recipient_id ON encrypted_note_keys(recipient_id);`,
+
+		// ----------------------------------------------------
+		// ENCRYPTED NOTE REVISIONS TABLE
+		// The ciphertext tuple an encrypted note had before each
+		// update, so a bad save can be undone. Only the newest
+		// ENCRYPTED_REVISIONS_KEPT revisions of a note are kept.
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS encrypted_note_revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			note_id INTEGER NOT NULL,
+			revision INTEGER NOT NULL,
+			version INTEGER NOT NULL,
+			title_ciphertext TEXT NOT NULL,
+			content_ciphertext TEXT NOT NULL,
+			title_nonce TEXT NOT NULL,
+			content_nonce TEXT NOT NULL,
+			note_salt TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			UNIQUE (note_id, revision),
+			FOREIGN KEY (note_id) REFERENCES encrypted_notes(id) ON DELETE CASCADE
+		);`,
+
+		// ----------------------------------------------------
+		// ENCRYPTED NOTE NONCES TABLE
+		// Every GCM nonce stored for an encrypted note, so a
+		// client cannot reuse one under the same note key.
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS encrypted_note_nonces (
+			note_id INTEGER NOT NULL,
+			nonce TEXT NOT NULL,
+			PRIMARY KEY (note_id, nonce),
+			FOREIGN KEY (note_id) REFERENCES encrypted_notes(id) ON DELETE CASCADE
+		);`,
+
+		// ----------------------------------------------------
+		// FULL-TEXT SEARCH INDEX (FTS5)
+		// rowid = notes.id. Holds keyed hashes of the note's
+		// words (see pkg/search), never plaintext. Requires the
+		// binary to be built with -tags sqlite_fts5.
+		// ----------------------------------------------------
+		`CREATE VIRTUAL TABLE IF NOT EXISTS notes_fts USING fts5(
+			title,
+			body,
+			prefixes,
+			tokenize = 'ascii'
+		);`,
+
+		`CREATE TRIGGER IF NOT EXISTS notes_fts_delete AFTER DELETE ON notes
+		BEGIN
+			DELETE FROM notes_fts WHERE rowid = old.id;
+		END;`,
+
+		// ----------------------------------------------------
+		// SEARCH INDEX STATE
+		// Fingerprint of the key notes_fts was built with, so
+		// the index is rebuilt when SEARCH_INDEX_KEY changes.
+		// ----------------------------------------------------
+		`CREATE TABLE IF NOT EXISTS search_index_state (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			key_check TEXT NOT NULL
+		);`,
 	}
 
 	// Execute each migration in sequence.
@@ -94,5 +341,199 @@ func Migrate(database *sql.DB) error {
 		}
 	}
 
+	// Columns added to existing tables after their first release.
+	// SQLite has no "ADD COLUMN IF NOT EXISTS", so each one is checked first.
+	columnMigrations := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"notes", "folder_id", "INTEGER REFERENCES folders(id) ON DELETE SET NULL"},
+
+		// Optimistic concurrency: bumped on every write, exposed as ETag
+		{"notes", "version", "INTEGER NOT NULL DEFAULT 1"},
+		{"encrypted_notes", "version", "INTEGER NOT NULL DEFAULT 1"},
+
+		// When the note was moved to the trash (NULL when not trashed)
+		{"notes", "deleted_at", "TEXT"},
+
+		// Optional share link limits (NULL = no limit)
+		{"shared_notes", "expires_at", "TEXT"},
+		{"shared_notes", "password_hash", "TEXT"},
+		{"shared_notes", "max_views", "INTEGER"},
+		{"shared_notes", "view_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"shared_notes", "label", "TEXT NOT NULL DEFAULT ''"},
+		{"shared_notes", "last_accessed_at", "TEXT"},
+
+		// Key pair for sharing encrypted notes. The private key is
+		// encrypted with the user's master key by the client.
+		{"users", "public_key", "TEXT"},
+		{"users", "private_key_ciphertext", "TEXT"},
+		{"users", "private_key_nonce", "TEXT"},
+		{"users", "keys_updated_at", "TEXT"},
+
+		// Key check: a known constant encrypted with the master key,
+		// so clients can tell a wrong master password from a corrupt
+		// note. The kdf_* columns say how that key is derived; NULL
+		// means model.LegacyKDF.
+		{"users", "key_check_ciphertext", "TEXT"},
+		{"users", "key_check_nonce", "TEXT"},
+		{"users", "kdf_algorithm", "TEXT"},
+		{"users", "kdf_iterations", "INTEGER"},
+		{"users", "key_check_updated_at", "TEXT"},
+		{"users", "kdf_memory_kib", "INTEGER"},
+		{"users", "kdf_parallelism", "INTEGER"},
+
+		// Master key wrapped by the client under a recovery key
+		// that only the user keeps.
+		{"users", "recovery_key_ciphertext", "TEXT"},
+		{"users", "recovery_key_nonce", "TEXT"},
+		{"users", "recovery_key_updated_at", "TEXT"},
+		{"encrypted_notes", "format_version", "INTEGER NOT NULL DEFAULT 1"},
+		{"encrypted_note_revisions", "format_version", "INTEGER NOT NULL DEFAULT 1"},
+
+		// Server key a blob file is sealed with; NULL for blobs stored
+		// before key IDs were recorded
+		{"attachment_blobs", "key_id", "TEXT"},
+
+		// File key of an encrypted attachment, wrapped under the master key;
+		// NULL for attachments whose file key is derived from file_salt
+		{"encrypted_attachments", "wrapped_file_key", "TEXT"},
+		{"encrypted_attachments", "file_key_nonce", "TEXT"},
+	}
+
+	for _, migration := range columnMigrations {
+		if err := addColumnIfMissing(database, migration.table, migration.column, migration.definition); err != nil {
+			return err
+		}
+	}
+
+	// Indexes on migrated columns must run after the columns exist.
+	indexStatements := []string{
+		`CREATE INDEX IF NOT EXISTS idx_notes_folder_id ON notes(folder_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_notes_deleted_at ON notes(deleted_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_shared_notes_note_id ON shared_notes(note_id);`,
+
+		// Notes trashed before deleted_at existed count from their last update
+		`UPDATE notes SET deleted_at = updated_at WHERE is_deleted = 1 AND deleted_at IS NULL;`,
+	}
+
+	indexStatements = append(indexStatements, syncStatements...)
+
+	for _, statement := range indexStatements {
+		if _, err := database.Exec(statement); err != nil {
+			return err
+		}
+	}
+
 	return nil // Migrations completed successfully
 }
+
+// addColumnIfMissing adds a column to a table unless it already exists.
+func addColumnIfMissing(database *sql.DB, table, column, definition string) error {
+	rows, err := database.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = database.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// syncStatements maintain the sync_changes log used by the delta sync API.
+//
+// Every write to notes and encrypted_notes gives the row a new, strictly
+// increasing seq. Only the latest change per entity is kept (INSERT OR REPLACE
+// on the unique key) and hard deletes leave a tombstone with op = 'delete'.
+// created_seq remembers when the entity first appeared (NULL = at seq) so
+// clients can tell created from updated rows.
+//
+// Triggers are used so that every code path, including ON DELETE CASCADE,
+// is covered. Tag changes "touch" the note so they are synced as well.
+var syncStatements = []string{
+	`CREATE TABLE IF NOT EXISTS sync_changes (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		entity TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		op TEXT NOT NULL,
+		created_seq INTEGER,
+		changed_at TEXT NOT NULL,
+		UNIQUE (entity, entity_id)
+	);`,
+
+	`CREATE INDEX IF NOT EXISTS idx_sync_changes_user_seq ON sync_changes(user_id, seq);`,
+
+	syncTrigger("notes_sync_insert", "INSERT", "notes", "note", "new", "upsert"),
+
+	// Re-encrypting a note with a new server key only sets content and
+	// doesn't change what clients see, so content alone is not a change. Every
+	// user-visible write also sets version or updated_at. Dropped first to
+	// replace the trigger of older versions, which fired on any column.
+	`DROP TRIGGER IF EXISTS notes_sync_update;`,
+	syncTrigger("notes_sync_update",
+		"UPDATE OF user_id, title, is_pinned, is_archived, is_deleted, folder_id, version, deleted_at, created_at, updated_at",
+		"notes", "note", "new", "upsert"),
+
+	syncTrigger("notes_sync_delete", "DELETE", "notes", "note", "old", "delete"),
+	syncTrigger("encrypted_notes_sync_insert", "INSERT", "encrypted_notes", "encrypted_note", "new", "upsert"),
+	syncTrigger("encrypted_notes_sync_update", "UPDATE", "encrypted_notes", "encrypted_note", "new", "upsert"),
+	syncTrigger("encrypted_notes_sync_delete", "DELETE", "encrypted_notes", "encrypted_note", "old", "delete"),
+
+	// The touched note no longer exists when note_tags rows are removed by
+	// the note's own ON DELETE CASCADE, so its tombstone is left alone.
+	`CREATE TRIGGER IF NOT EXISTS note_tags_sync_insert AFTER INSERT ON note_tags
+	BEGIN
+		UPDATE notes SET updated_at = updated_at WHERE id = new.note_id;
+	END;`,
+
+	`CREATE TRIGGER IF NOT EXISTS note_tags_sync_delete AFTER DELETE ON note_tags
+	BEGIN
+		UPDATE notes SET updated_at = updated_at WHERE id = old.note_id;
+	END;`,
+
+	`CREATE TRIGGER IF NOT EXISTS tags_sync_rename AFTER UPDATE OF name ON tags
+	BEGIN
+		UPDATE notes SET updated_at = updated_at
+		WHERE id IN (SELECT note_id FROM note_tags WHERE tag_id = new.id);
+	END;`,
+
+	// Backfill rows written before the sync log existed
+	`INSERT OR IGNORE INTO sync_changes (user_id, entity, entity_id, op, changed_at)
+	SELECT user_id, 'note', id, 'upsert', updated_at FROM notes ORDER BY updated_at, id;`,
+
+	`INSERT OR IGNORE INTO sync_changes (user_id, entity, entity_id, op, changed_at)
+	SELECT user_id, 'encrypted_note', id, 'upsert', updated_at FROM encrypted_notes ORDER BY updated_at, id;`,
+}
+
+// syncTrigger builds a trigger that records a change of one row in sync_changes.
+// row is "new" or "old" depending on the event.
+func syncTrigger(name, event, table, entity, row, op string) string {
+	createdSeq := "NULL"
+	if event != "INSERT" {
+		createdSeq = fmt.Sprintf(
+			"(SELECT COALESCE(created_seq, seq) FROM sync_changes WHERE entity = '%s' AND entity_id = %s.id)",
+			entity, row,
+		)
+	}
+
+	return fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s AFTER %s ON %s
+	BEGIN
+		INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id, op, created_seq, changed_at)
+		VALUES (%s.user_id, '%s', %s.id, '%s', %s, strftime('%%Y-%%m-%%dT%%H:%%M:%%SZ', 'now'));
+	END;`, name, event, table, row, entity, row, op, createdSeq)
+}
@@ -0,0 +1,18 @@
+package model
+
+type Attachment struct {
+	ID        int64  `json:"id"`
+	NoteID    int64  `json:"note_id"`
+	Filename  string `json:"filename"`
+	MimeType  string `json:"mime_type"`
+	Size      int64  `json:"size"`
+	CreatedAt string `json:"created_at"`
+	URL       string `json:"url"` // download path, usable in note content
+}
+
+// AttachmentUsage is the attachment storage used by a user. Quota is 0 when
+// storage is unlimited.
+type AttachmentUsage struct {
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota"`
+}
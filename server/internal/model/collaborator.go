@@ -0,0 +1,37 @@
+package model
+
+// Collaborator roles
+const (
+	CollaboratorViewer = "viewer" // can read the note
+	CollaboratorEditor = "editor" // can also change title and content
+)
+
+// Collaborator is a user a note has been shared with.
+type Collaborator struct {
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
+
+// SharedWithMeNote is a note another user shared with the current user. The
+// note itself is read through the regular notes endpoints.
+type SharedWithMeNote struct {
+	ID         int64  `json:"id"`
+	Title      string `json:"title"`
+	Role       string `json:"role"`
+	OwnerEmail string `json:"owner_email"`
+	OwnerName  string `json:"owner_name"`
+	SharedAt   string `json:"shared_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+// SharedByMeNote is a note of the current user that is shared with other
+// users.
+type SharedByMeNote struct {
+	ID            int64  `json:"id"`
+	Title         string `json:"title"`
+	Collaborators int    `json:"collaborators"`
+	UpdatedAt     string `json:"updated_at"`
+}
@@ -0,0 +1,52 @@
+package model
+
+// Encrypted attachment upload states
+const (
+	EncryptedAttachmentPending  = "pending"  // chunks are still being uploaded
+	EncryptedAttachmentComplete = "complete" // all chunks uploaded, read-only
+)
+
+// EncryptedAttachment is a file attached to an encrypted note. The server
+// only sees ciphertext: the file name and type are inside Metadata, the
+// content is split into chunks that are each sealed with their own nonce
+// under the file key. The file key is random and wrapped in WrappedFileKey
+// with a key derived from FileSalt, so a new master key only needs a new
+// wrapping. Attachments without WrappedFileKey use the derived key itself.
+type EncryptedAttachment struct {
+	ID                 int64            `json:"id"`
+	NoteID             int64            `json:"note_id"`
+	MetadataCiphertext string           `json:"metadata"`
+	MetadataNonce      string           `json:"metadata_nonce"`
+	FileSalt           string           `json:"file_salt"`
+	WrappedFileKey     string           `json:"wrapped_file_key,omitempty"` // base64
+	FileKeyNonce       string           `json:"file_key_nonce,omitempty"`   // hex
+	ChunkCount         int              `json:"chunk_count"`
+	Size               int64            `json:"size"` // ciphertext bytes uploaded so far
+	Status             string           `json:"status"`
+	CreatedAt          string           `json:"created_at"`
+	Chunks             []EncryptedChunk `json:"chunks,omitempty"`
+}
+
+type EncryptedChunk struct {
+	Index int    `json:"index"`
+	Nonce string `json:"nonce"`
+	Size  int64  `json:"size"`
+}
+
+type CreateEncryptedAttachmentInput struct {
+	MetadataCiphertext string
+	MetadataNonce      string
+	FileSalt           string
+	WrappedFileKey     string // empty = the key derived from FileSalt is the file key
+	FileKeyNonce       string
+	ChunkCount         int
+}
+
+// RekeyEncryptedAttachment is the file key of an attachment wrapped under a
+// new master key. The chunks and metadata stay as they are.
+type RekeyEncryptedAttachment struct {
+	ID             int64
+	FileSalt       string
+	WrappedFileKey string
+	FileKeyNonce   string
+}
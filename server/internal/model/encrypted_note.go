@@ -1,10 +1,16 @@
 package model
 
+// EncryptedNoteFormatVersion is the payload format clients write:
+// AES-256-GCM with 12-byte nonces, base64 ciphertexts, hex nonces and
+// salts. Notes stored before the field existed are version 1.
+const EncryptedNoteFormatVersion = 1
+
 type EncryptedNoteMetadata struct {
 	ID              int64  `json:"id"`
 	TitleCiphertext string `json:"title"`
 	TitleNonce      string `json:"title_nonce"`
 	NoteSalt        string `json:"note_salt"`
+	FormatVersion   int    `json:"format_version"`
 	CreatedAt       string `json:"created_at"`
 	UpdatedAt       string `json:"updated_at"`
 }
@@ -16,8 +22,13 @@ type EncryptedNoteResponse struct {
 	TitleNonce        string `json:"title_nonce"`
 	ContentNonce      string `json:"content_nonce"`
 	NoteSalt          string `json:"note_salt"`
+	FormatVersion     int    `json:"format_version"`
+	Version           int64  `json:"version"`
 	CreatedAt         string `json:"created_at"`
 	UpdatedAt         string `json:"updated_at"`
+
+	// Key is set when the note was shared with the user by someone else
+	Key *WrappedNoteKey `json:"key,omitempty"`
 }
 
 type CreateEncryptedNoteInput struct {
@@ -26,6 +37,7 @@ type CreateEncryptedNoteInput struct {
 	TitleNonce        string
 	ContentNonce      string
 	NoteSalt          string
+	FormatVersion     int
 }
 
 type UpdateEncryptedNoteInput struct {
@@ -34,4 +46,91 @@ type UpdateEncryptedNoteInput struct {
 	TitleNonce        string
 	ContentNonce      string
 	NoteSalt          string
+	FormatVersion     int
+	IfMatch           []int64 // accepted current versions, empty = unconditional
+}
+
+// RekeyEncryptedNote is one note re-encrypted under a new master key.
+// Version, when set, is the version the client re-encrypted.
+type RekeyEncryptedNote struct {
+	ID                int64
+	TitleCiphertext   string
+	ContentCiphertext string
+	TitleNonce        string
+	ContentNonce      string
+	NoteSalt          string
+	FormatVersion     int
+	Version           *int64
+}
+
+// RekeyEncryptedNotesInput replaces everything encrypted under the master
+// key at once: the user_salt it is derived with, every encrypted note, the
+// file key of every encrypted attachment, the recovery key and, optionally,
+// the KDF parameters, the key check and the encrypted private key.
+type RekeyEncryptedNotesInput struct {
+	UserSalt             string
+	KDF                  *KDFParams // nil = keep
+	Notes                []RekeyEncryptedNote
+	Attachments          []RekeyEncryptedAttachment
+	KeyCheck             *KeyCheck
+	PrivateKeyCiphertext string // empty = keep
+	PrivateKeyNonce      string
+	RecoveryKey          *RecoveryKey // nil = remove, it wraps the old master key
+}
+
+// EncryptedNoteRevision is the ciphertext tuple an encrypted note had
+// before an update. Version is the note version it replaced. Lists leave
+// out the content.
+type EncryptedNoteRevision struct {
+	ID                int64  `json:"id"`
+	NoteID            int64  `json:"note_id"`
+	Revision          int64  `json:"revision"`
+	Version           int64  `json:"version"`
+	TitleCiphertext   string `json:"title"`
+	ContentCiphertext string `json:"content,omitempty"`
+	TitleNonce        string `json:"title_nonce"`
+	ContentNonce      string `json:"content_nonce,omitempty"`
+	NoteSalt          string `json:"note_salt"`
+	FormatVersion     int    `json:"format_version"`
+	CreatedAt         string `json:"created_at"`
+}
+
+// WrappedNoteKey is the key of an encrypted note, wrapped for one
+// recipient: the owner's client derives a key from an ephemeral X25519 key
+// pair and the recipient's public key and encrypts the note key with it.
+type WrappedNoteKey struct {
+	WrappedKey         string `json:"wrapped_key"`          // base64
+	EphemeralPublicKey string `json:"ephemeral_public_key"` // X25519, base64
+	WrapNonce          string `json:"wrap_nonce"`           // hex
+	NoteSalt           string `json:"note_salt"`            // salt the wrapped key belongs to
+	Role               string `json:"role"`
+}
+
+// ShareEncryptedNoteInput shares an encrypted note with a user.
+type ShareEncryptedNoteInput struct {
+	RecipientID        int64
+	Role               string
+	WrappedKey         string
+	EphemeralPublicKey string
+	WrapNonce          string
+}
+
+// EncryptedNoteRecipient is a user an encrypted note is shared with. Stale
+// is set when the note's salt changed after the key was wrapped; the owner
+// has to share the note again for the recipient to read new versions.
+type EncryptedNoteRecipient struct {
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	Stale     bool   `json:"stale"`
+	CreatedAt string `json:"created_at"`
+}
+
+// SharedEncryptedNote is an encrypted note another user shared with the
+// current user, with the wrapped key to open it.
+type SharedEncryptedNote struct {
+	EncryptedNoteMetadata
+	OwnerEmail string         `json:"owner_email"`
+	Key        WrappedNoteKey `json:"key"`
 }
@@ -0,0 +1,9 @@
+package model
+
+type Folder struct {
+	ID        int64  `json:"id"`
+	ParentID  *int64 `json:"parent_id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
@@ -0,0 +1,24 @@
+package model
+
+// Import report statuses
+const (
+	ImportStatusImported = "imported"
+	ImportStatusPartial  = "partial"
+	ImportStatusFailed   = "failed"
+	ImportStatusSkipped  = "skipped"
+)
+
+// ImportedNote is a note created by an import.
+type ImportedNote struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// ImportFileResult reports what happened to one imported file. Files inside
+// a ZIP archive are reported individually as "<archive>/<path>".
+type ImportFileResult struct {
+	File   string         `json:"file"`
+	Status string         `json:"status"`
+	Notes  []ImportedNote `json:"notes,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
@@ -0,0 +1,21 @@
+package model
+
+// Key derivation functions a master key can be derived with.
+const (
+	KDFPBKDF2SHA256 = "PBKDF2-SHA256"
+	KDFArgon2id     = "Argon2id"
+)
+
+// KDFParams describe how the master key is derived from the master
+// password and the user's salt. MemoryKiB and Parallelism only apply to
+// Argon2id.
+type KDFParams struct {
+	Algorithm   string `json:"algorithm"`
+	Iterations  int    `json:"iterations"`
+	MemoryKiB   int    `json:"memory_kib,omitempty"`
+	Parallelism int    `json:"parallelism,omitempty"`
+}
+
+// LegacyKDF is what clients used before KDF parameters were stored, and
+// what users without stored parameters still use.
+var LegacyKDF = KDFParams{Algorithm: KDFPBKDF2SHA256, Iterations: 250000}
@@ -0,0 +1,9 @@
+package model
+
+// KeyCheck is a known constant encrypted with the master key. A client
+// that can decrypt it has derived the right master key.
+type KeyCheck struct {
+	Ciphertext string `json:"ciphertext"` // base64
+	Nonce      string `json:"nonce"`      // hex
+	UpdatedAt  string `json:"updated_at"`
+}
@@ -1,12 +1,95 @@
 package model
 
+import "github.com/shamal-iroshan/notora/internal/pkg/pagination"
+
 type Note struct {
-	ID         int64  `json:"id"`
-	Title      string `json:"title"`
-	Content    string `json:"content"`
-	IsPinned   bool   `json:"is_pinned"`
-	IsArchived bool   `json:"is_archived"`
-	IsDeleted  bool   `json:"is_deleted"`
-	CreatedAt  string `json:"created_at"`
-	UpdatedAt  string `json:"updated_at"`
+	ID         int64    `json:"id"`
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	IsPinned   bool     `json:"is_pinned"`
+	IsArchived bool     `json:"is_archived"`
+	IsDeleted  bool     `json:"is_deleted"`
+	Tags       []string `json:"tags,omitempty"`
+	FolderID   *int64   `json:"folder_id"`
+	Version    int64    `json:"version"`
+	DeletedAt  *string  `json:"deleted_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+// NoteMetadata is a note without its content, used for listings.
+type NoteMetadata struct {
+	ID         int64    `json:"id"`
+	Title      string   `json:"title"`
+	IsPinned   bool     `json:"is_pinned"`
+	IsArchived bool     `json:"is_archived"`
+	IsDeleted  bool     `json:"is_deleted"`
+	Tags       []string `json:"tags"`
+	FolderID   *int64   `json:"folder_id"`
+	DeletedAt  *string  `json:"deleted_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+type CreateNoteInput struct {
+	Title    string
+	Content  string
+	Tags     []string
+	FolderID *int64
+
+	// Set by imports, which keep the flags and dates of the original note
+	IsPinned   bool
+	IsArchived bool
+	IsDeleted  bool
+	CreatedAt  string // empty = now
+	UpdatedAt  string // empty = CreatedAt
+}
+
+type UpdateNoteInput struct {
+	Title   string
+	Content string
+	Tags    []string // nil = keep current tags
+	IfMatch []int64  // accepted current versions, empty = unconditional
+}
+
+type NoteRevision struct {
+	ID        int64  `json:"id"`
+	NoteID    int64  `json:"note_id"`
+	Revision  int64  `json:"revision"`
+	Title     string `json:"title"`
+	Content   string `json:"content,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type SearchNotesInput struct {
+	Query    string
+	Archived *bool
+	Pinned   *bool
+	Tag      string
+	Limit    int
+}
+
+type NoteSearchResult struct {
+	ID         int64    `json:"id"`
+	Title      string   `json:"title"`
+	Snippet    string   `json:"snippet"`
+	Score      float64  `json:"score"`
+	IsPinned   bool     `json:"is_pinned"`
+	IsArchived bool     `json:"is_archived"`
+	Tags       []string `json:"tags"`
+	FolderID   *int64   `json:"folder_id"`
+	UpdatedAt  string   `json:"updated_at"`
+	Content    string   `json:"-"` // decrypted, only used to build the snippet
+}
+
+// ListNotesInput describes one page of a note listing.
+// Nil filters are not applied.
+type ListNotesInput struct {
+	Sort     pagination.Sort
+	After    *pagination.Cursor
+	Limit    int
+	Archived *bool
+	Trashed  *bool
+	Pinned   *bool
+	Tag      string
 }
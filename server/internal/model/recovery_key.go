@@ -0,0 +1,16 @@
+package model
+
+// RecoveryKey is the user's master key wrapped (AES-GCM) under a recovery
+// key the client generated and the user keeps offline.
+type RecoveryKey struct {
+	WrappedMasterKey string `json:"wrapped_master_key"` // base64
+	Nonce            string `json:"nonce"`              // hex
+	UpdatedAt        string `json:"updated_at"`
+}
+
+// RecoveryKeyStatus tells whether a recovery key is set, without the
+// wrapped key itself.
+type RecoveryKeyStatus struct {
+	Enabled   bool    `json:"enabled"`
+	UpdatedAt *string `json:"updated_at"`
+}
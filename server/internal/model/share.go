@@ -0,0 +1,29 @@
+package model
+
+// Share is a public link to a note. A note can have several links, e.g. one
+// per person it was sent to, and each can be revoked on its own.
+type Share struct {
+	ID                int64   `json:"id"`
+	NoteID            int64   `json:"note_id"`
+	Label             string  `json:"label"`
+	Token             string  `json:"token"`
+	URL               string  `json:"url"`
+	ExpiresAt         *string `json:"expires_at"`
+	PasswordProtected bool    `json:"password_protected"`
+	MaxViews          *int64  `json:"max_views"`
+	ViewCount         int64   `json:"view_count"`
+	Active            bool    `json:"active"` // not expired and views left
+	CreatedAt         string  `json:"created_at"`
+	LastAccessedAt    *string `json:"last_accessed_at"`
+	PasswordHash      string  `json:"-"`
+}
+
+// ShareOptionsInput holds the label and the optional guardrails of a share
+// link. A nil field is left unchanged on update; an empty ExpiresAt or
+// Password or a MaxViews of 0 removes the limit.
+type ShareOptionsInput struct {
+	Label     *string
+	ExpiresAt *string
+	Password  *string
+	MaxViews  *int64
+}
@@ -0,0 +1,83 @@
+package model
+
+// Entities and operations recorded in the sync change log
+const (
+	SyncEntityNote          = "note"
+	SyncEntityEncryptedNote = "encrypted_note"
+
+	SyncOpUpsert = "upsert"
+	SyncOpDelete = "delete"
+)
+
+// SyncChange is the latest change of one entity.
+type SyncChange struct {
+	Seq        int64
+	Entity     string
+	EntityID   int64
+	Op         string
+	CreatedSeq int64 // seq at which the entity was created
+}
+
+// SyncNotesDelta holds the plaintext note changes since a cursor.
+type SyncNotesDelta struct {
+	Created []Note  `json:"created"`
+	Updated []Note  `json:"updated"`
+	Deleted []int64 `json:"deleted"`
+}
+
+// SyncEncryptedNotesDelta holds the encrypted note changes since a cursor.
+type SyncEncryptedNotesDelta struct {
+	Created []EncryptedNoteResponse `json:"created"`
+	Updated []EncryptedNoteResponse `json:"updated"`
+	Deleted []int64                 `json:"deleted"`
+}
+
+// SyncDelta is one page of changes returned by GET /api/sync.
+type SyncDelta struct {
+	Notes          SyncNotesDelta          `json:"notes"`
+	EncryptedNotes SyncEncryptedNotesDelta `json:"encrypted_notes"`
+	Cursor         string                  `json:"cursor"`
+	HasMore        bool                    `json:"has_more"`
+}
+
+// SyncPushItem is one change sent by a client. Note carries the fields of a
+// plaintext note, EncryptedNote those of an encrypted note.
+type SyncPushItem struct {
+	Type          string
+	Op            string
+	ID            int64
+	ClientID      string
+	Note          *SyncNoteFields
+	EncryptedNote *CreateEncryptedNoteInput
+}
+
+// SyncNoteFields are the plaintext note fields a client can push.
+// Nil pointers (and nil Tags) leave the server value unchanged on update.
+type SyncNoteFields struct {
+	Title      string
+	Content    string
+	Tags       []string
+	IsPinned   *bool
+	IsArchived *bool
+	IsDeleted  *bool
+}
+
+// Push result statuses
+const (
+	SyncStatusApplied  = "applied"
+	SyncStatusConflict = "conflict"
+	SyncStatusError    = "error"
+)
+
+// SyncPushResult reports what happened to one pushed item. On a conflict
+// Server holds the current server version (nil if it was deleted).
+type SyncPushResult struct {
+	Index    int         `json:"index"`
+	Type     string      `json:"type"`
+	Op       string      `json:"op"`
+	ID       int64       `json:"id,omitempty"`
+	ClientID string      `json:"client_id,omitempty"`
+	Status   string      `json:"status"`
+	Error    string      `json:"error,omitempty"`
+	Server   interface{} `json:"server,omitempty"`
+}
@@ -0,0 +1,8 @@
+package model
+
+type Tag struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	NoteCount int64  `json:"note_count"`
+	CreatedAt string `json:"created_at"`
+}
@@ -9,4 +9,6 @@ type User struct {
 	IsAdmin   bool
 	CreatedAt string
 	UserSalt  string
+	KDF       KDFParams // how the master key is derived
+	KeyCheck  *KeyCheck // nil until the client sets one
 }
@@ -0,0 +1,19 @@
+package model
+
+// UserKeys is a user's key pair for sharing encrypted notes. The private
+// key is encrypted with the user's master key (AES-GCM), so only their
+// client can use it.
+type UserKeys struct {
+	PublicKey            string `json:"public_key"`             // X25519, base64
+	PrivateKeyCiphertext string `json:"private_key_ciphertext"` // base64
+	PrivateKeyNonce      string `json:"private_key_nonce"`      // hex
+	UpdatedAt            string `json:"updated_at"`
+}
+
+// PublicKey is the public key of a user, looked up to share a note with them.
+type PublicKey struct {
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"`
+}
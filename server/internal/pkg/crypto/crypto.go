@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
@@ -45,3 +46,18 @@ func SHA256Hex(input string) string {
 	hash := sha256.Sum256([]byte(input))
 	return hex.EncodeToString(hash[:])
 }
+
+// HMACHex signs a message with HMAC-SHA256 and returns the MAC as hex.
+//
+// Used for short-lived, stateless grants (e.g. unlocked share links)
+// that the server verifies again later with VerifyHMACHex.
+func HMACHex(key []byte, message string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyHMACHex checks a MAC created by HMACHex in constant time.
+func VerifyHMACHex(key []byte, message, macHex string) bool {
+	return hmac.Equal([]byte(HMACHex(key, message)), []byte(macHex))
+}
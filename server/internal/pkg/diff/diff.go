@@ -0,0 +1,126 @@
+package diff
+
+import "strings"
+
+// Operation describes how a line changed between two texts.
+type Operation string
+
+const (
+	Equal  Operation = "equal"
+	Insert Operation = "insert"
+	Delete Operation = "delete"
+)
+
+// Line is a single entry of a line-level diff.
+//
+// OldLine / NewLine are 1-based line numbers in the old and new text.
+// A zero value means the line does not exist on that side
+// (e.g. OldLine is 0 for inserted lines).
+type Line struct {
+	Op      Operation `json:"op"`
+	Text    string    `json:"text"`
+	OldLine int       `json:"old_line,omitempty"`
+	NewLine int       `json:"new_line,omitempty"`
+}
+
+// Lines computes a line-level diff between oldText and newText using
+// Myers' O(ND) algorithm, so the result is a shortest edit script.
+func Lines(oldText, newText string) []Line {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+
+	// v[k] holds the furthest x reached on diagonal k, reads go one
+	// diagonal beyond ±d. trace keeps a copy of v for every edit
+	// distance d so we can backtrack.
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // move down (insertion)
+			} else {
+				x = v[offset+k-1] + 1 // move right (deletion)
+			}
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	return backtrack(trace, a, b, offset)
+}
+
+// backtrack walks the saved Myers traces from the end of both texts
+// back to the start and builds the edit script in forward order.
+func backtrack(trace [][]int, a, b []string, offset int) []Line {
+	x, y := len(a), len(b)
+	var reversed []Line
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, Line{Op: Equal, Text: a[x-1], OldLine: x, NewLine: y})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, Line{Op: Insert, Text: b[y-1], NewLine: y})
+			} else {
+				reversed = append(reversed, Line{Op: Delete, Text: a[x-1], OldLine: x})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	result := make([]Line, len(reversed))
+	for i, line := range reversed {
+		result[len(reversed)-1-i] = line
+	}
+	return result
+}
+
+// splitLines splits text on newlines. An empty text has no lines.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
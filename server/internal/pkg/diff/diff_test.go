@@ -0,0 +1,111 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []Line
+	}{
+		{
+			name: "both empty",
+			want: []Line{},
+		},
+		{
+			name: "all inserted",
+			new:  "a\nb",
+			want: []Line{
+				{Op: Insert, Text: "a", NewLine: 1},
+				{Op: Insert, Text: "b", NewLine: 2},
+			},
+		},
+		{
+			name: "all deleted",
+			old:  "a\nb\n",
+			want: []Line{
+				{Op: Delete, Text: "a", OldLine: 1},
+				{Op: Delete, Text: "b", OldLine: 2},
+			},
+		},
+		{
+			name: "unchanged",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: []Line{
+				{Op: Equal, Text: "a", OldLine: 1, NewLine: 1},
+				{Op: Equal, Text: "b", OldLine: 2, NewLine: 2},
+			},
+		},
+		{
+			name: "line replaced",
+			old:  "a\nb\nc",
+			new:  "a\nx\nc",
+			want: []Line{
+				{Op: Equal, Text: "a", OldLine: 1, NewLine: 1},
+				{Op: Delete, Text: "b", OldLine: 2},
+				{Op: Insert, Text: "x", NewLine: 2},
+				{Op: Equal, Text: "c", OldLine: 3, NewLine: 3},
+			},
+		},
+		{
+			name: "CRLF matches LF",
+			old:  "a\r\nb\r\n",
+			new:  "a\nb\nc",
+			want: []Line{
+				{Op: Equal, Text: "a", OldLine: 1, NewLine: 1},
+				{Op: Equal, Text: "b", OldLine: 2, NewLine: 2},
+				{Op: Insert, Text: "c", NewLine: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.old, tt.new)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines(%q, %q) =\n%v\nwant\n%v", tt.old, tt.new, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLinesShortest checks the script is minimal and rebuilds both texts.
+func TestLinesShortest(t *testing.T) {
+	old := "a\nb\nc\na\nb\nb\na"
+	new := "c\nb\na\nb\na\nc"
+
+	lines := Lines(old, new)
+
+	var oldSide, newSide []string
+	edits := 0
+	for _, l := range lines {
+		if l.Op != Insert {
+			oldSide = append(oldSide, l.Text)
+		}
+		if l.Op != Delete {
+			newSide = append(newSide, l.Text)
+		}
+		if l.Op != Equal {
+			edits++
+		}
+	}
+
+	if got := strings.Join(oldSide, "\n"); got != old {
+		t.Errorf("old side = %q, want %q", got, old)
+	}
+	if got := strings.Join(newSide, "\n"); got != new {
+		t.Errorf("new side = %q, want %q", got, new)
+	}
+	// The example from Myers' paper has an edit distance of 5
+	if edits != 5 {
+		t.Errorf("edits = %d, want 5", edits)
+	}
+}
@@ -9,54 +9,134 @@ import (
 	"io"
 )
 
-func EncryptAES(key []byte, plaintext string) (string, error) {
-	block, err := aes.NewCipher(key)
+// EncryptAES seals a string with the active key of the keyring.
+func EncryptAES(kr *Keyring, plaintext string) (string, error) {
+	key := kr.ActiveKey()
+	if key == nil {
+		return "", ErrNoActiveKey
+	}
+
+	ciphertext, err := seal(key, []byte(plaintext))
 	if err != nil {
 		return "", err
 	}
+	return stringPrefix + kr.ActiveID() + ":" + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptAES opens a string sealed by EncryptAES with the key it names,
+// or, for strings from before key IDs, with whichever key fits.
+func DecryptAES(kr *Keyring, ciphertext string) (string, error) {
+	if id, body, ok := splitString(ciphertext); ok {
+		key, found := kr.keys[id]
+		if !found {
+			return "", ErrUnknownKey
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return "", err
+		}
 
-	gcm, err := cipher.NewGCM(block)
+		plain, err := open(key, raw)
+		if err != nil {
+			return "", err
+		}
+		return string(plain), nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(ciphertext)
 	if err != nil {
 		return "", err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	plain, err := openAny(kr.ordered(), raw)
+	if err != nil {
 		return "", err
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return string(plain), nil
 }
 
-func DecryptAES(key []byte, ciphertext string) (string, error) {
-	raw, err := base64.StdEncoding.DecodeString(ciphertext)
+// EncryptBytes seals binary data with AES-GCM and the active key. The
+// result is the key ID header, the random nonce and the ciphertext.
+func EncryptBytes(kr *Keyring, plaintext []byte) ([]byte, error) {
+	key := kr.ActiveKey()
+	if key == nil {
+		return nil, ErrNoActiveKey
+	}
+
+	id := kr.ActiveID()
+	header := append(append(append([]byte{}, bytesMagic...), byte(len(id))), id...)
+
+	sealed, err := seal(key, plaintext)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return append(header, sealed...), nil
+}
 
-	block, err := aes.NewCipher(key)
+// DecryptBytes opens data sealed by EncryptBytes. Data without a key ID
+// header is opened with whichever key fits.
+func DecryptBytes(kr *Keyring, raw []byte) ([]byte, error) {
+	if id, body, ok := splitBytes(raw); ok {
+		if key, found := kr.keys[id]; found {
+			if plain, err := open(key, body); err == nil {
+				return plain, nil
+			}
+		}
+		// Old data can start like a header by chance; try it as such
+	}
+
+	return openAny(kr.ordered(), raw)
+}
+
+// seal encrypts plaintext and returns the random nonce followed by the
+// ciphertext.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts the output of seal.
+func open(key, raw []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	nonceSize := gcm.NonceSize()
 	if len(raw) < nonceSize {
-		return "", errors.New("ciphertext too short")
+		return nil, errors.New("ciphertext too short")
 	}
 
 	nonce := raw[:nonceSize]
 	body := raw[nonceSize:]
 
-	plain, err := gcm.Open(nil, nonce, body, nil)
-	if err != nil {
-		return "", err
+	return gcm.Open(nil, nonce, body, nil)
+}
+
+func openAny(keys [][]byte, raw []byte) ([]byte, error) {
+	for _, key := range keys {
+		if plain, err := open(key, raw); err == nil {
+			return plain, nil
+		}
 	}
+	return nil, ErrDecrypt
+}
 
-	return string(plain), nil
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
 }
@@ -0,0 +1,220 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustKeyring(t *testing.T, keys, activeID, legacyKey string) *Keyring {
+	t.Helper()
+
+	k, err := NewKeyring(keys, activeID, legacyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// sealWithNonce seals plaintext the way data was sealed before key IDs,
+// with a chosen nonce.
+func sealWithNonce(t *testing.T, key string, nonce, plaintext []byte) []byte {
+	t.Helper()
+
+	gcm, err := newGCM([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gcm.Seal(append([]byte{}, nonce...), nonce, plaintext, nil)
+}
+
+func TestAESRoundTrip(t *testing.T) {
+	k := mustKeyring(t, "1:"+key1, "1", key0)
+
+	for _, plaintext := range []string{"", "note", strings.Repeat("ünïcode ", 1000)} {
+		sealed, err := EncryptAES(k, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(sealed, k.ActivePrefix()) {
+			t.Errorf("EncryptAES(%q) = %q, want prefix %q", plaintext, sealed, k.ActivePrefix())
+		}
+
+		got, err := DecryptAES(k, sealed)
+		if err != nil {
+			t.Errorf("DecryptAES: %v", err)
+			continue
+		}
+		if got != plaintext {
+			t.Errorf("DecryptAES = %q, want %q", got, plaintext)
+		}
+	}
+}
+
+func TestAESAfterRotation(t *testing.T) {
+	old := mustKeyring(t, "", "", key0)
+	sealed, err := EncryptAES(old, "note")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := mustKeyring(t, "1:"+key1, "1", key0)
+	if strings.HasPrefix(sealed, k.ActivePrefix()) {
+		t.Errorf("%q has the prefix of the new active key", sealed)
+	}
+	if got, err := DecryptAES(k, sealed); err != nil || got != "note" {
+		t.Errorf("DecryptAES = %q, %v, want \"note\"", got, err)
+	}
+}
+
+func TestAESLegacy(t *testing.T) {
+	k := mustKeyring(t, "1:"+key1, "1", key0)
+
+	nonce := make([]byte, 12)
+	rand.Read(nonce)
+	sealed := base64.StdEncoding.EncodeToString(sealWithNonce(t, key0, nonce, []byte("note")))
+
+	got, err := DecryptAES(k, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "note" {
+		t.Errorf("DecryptAES = %q, want \"note\"", got)
+	}
+
+	other := mustKeyring(t, "2:"+key2, "", "")
+	if _, err := DecryptAES(other, sealed); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("DecryptAES without the key: error = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestAESUnknownKey(t *testing.T) {
+	sealed, err := EncryptAES(mustKeyring(t, "2:"+key2, "", ""), "note")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := mustKeyring(t, "1:"+key1, "1", key0)
+	if _, err := DecryptAES(k, sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("DecryptAES error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestAESTampered(t *testing.T) {
+	k := mustKeyring(t, "1:"+key1, "1", "")
+
+	sealed, err := EncryptAES(k, "note")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, k.ActivePrefix()))
+	raw[len(raw)-1] ^= 1
+	tampered := k.ActivePrefix() + base64.StdEncoding.EncodeToString(raw)
+
+	if _, err := DecryptAES(k, tampered); err == nil {
+		t.Error("DecryptAES of tampered ciphertext succeeded")
+	}
+}
+
+func TestBytesRoundTrip(t *testing.T) {
+	k := mustKeyring(t, "1:"+key1, "1", key0)
+
+	for _, plaintext := range [][]byte{{}, []byte("file"), bytes.Repeat([]byte{0, 0xff}, 5000)} {
+		sealed, err := EncryptBytes(k, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !k.IsActive(sealed) {
+			t.Errorf("EncryptBytes output is not on the active key")
+		}
+
+		got, err := DecryptBytes(k, sealed)
+		if err != nil {
+			t.Errorf("DecryptBytes: %v", err)
+			continue
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("DecryptBytes = %q, want %q", got, plaintext)
+		}
+	}
+}
+
+func TestBytesAfterRotation(t *testing.T) {
+	sealed, err := EncryptBytes(mustKeyring(t, "", "", key0), []byte("file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := mustKeyring(t, "1:"+key1, "1", key0)
+	if k.IsActive(sealed) {
+		t.Error("data sealed with key 0 is reported as on the active key")
+	}
+	if got, err := DecryptBytes(k, sealed); err != nil || string(got) != "file" {
+		t.Errorf("DecryptBytes = %q, %v, want \"file\"", got, err)
+	}
+}
+
+func TestBytesLegacy(t *testing.T) {
+	k := mustKeyring(t, "1:"+key1, "1", key0)
+
+	nonce := make([]byte, 12)
+	rand.Read(nonce)
+	nonce[0] = 'X' // not the header magic
+
+	got, err := DecryptBytes(k, sealWithNonce(t, key0, nonce, []byte("file")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "file" {
+		t.Errorf("DecryptBytes = %q, want \"file\"", got)
+	}
+}
+
+// Data sealed before key IDs starts with a random nonce, which can look
+// like a header by chance.
+func TestBytesLegacyWithMagic(t *testing.T) {
+	k := mustKeyring(t, "1:"+key1, "1", key0)
+
+	tests := []struct {
+		name       string
+		nonce      string
+		wantHeader bool
+	}{
+		{"names the active key", "NKR\x01\x011abcdef", true},
+		{"names the legacy key", "NKR\x01\x010abcdef", true},
+		{"names an unknown key", "NKR\x01\x019abcdef", true},
+		{"ID length past the end", "NKR\x01\xffabcdefg", false},
+	}
+
+	for _, tt := range tests {
+		sealed := sealWithNonce(t, key0, []byte(tt.nonce), []byte("file"))
+		if _, _, ok := splitBytes(sealed); ok != tt.wantHeader {
+			t.Fatalf("%s: splitBytes ok = %v, want %v", tt.name, ok, tt.wantHeader)
+		}
+
+		got, err := DecryptBytes(k, sealed)
+		if err != nil {
+			t.Errorf("%s: DecryptBytes: %v", tt.name, err)
+			continue
+		}
+		if string(got) != "file" {
+			t.Errorf("%s: DecryptBytes = %q, want \"file\"", tt.name, got)
+		}
+	}
+}
+
+func TestBytesUnknownKey(t *testing.T) {
+	sealed, err := EncryptBytes(mustKeyring(t, "2:"+key2, "", ""), []byte("file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := mustKeyring(t, "1:"+key1, "1", key0)
+	if _, err := DecryptBytes(k, sealed); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("DecryptBytes error = %v, want ErrDecrypt", err)
+	}
+}
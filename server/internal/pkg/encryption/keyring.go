@@ -0,0 +1,186 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Ciphertexts name the key that sealed them, so keys can be rotated:
+//
+//	strings: "v1:<key id>:" + base64(nonce || ciphertext)
+//	bytes:   "NKR" 0x01, len(key id), key id, nonce || ciphertext
+//
+// Anything else was written before key IDs existed. Those are opened by
+// trying every key of the keyring; GCM authentication tells the right one.
+
+const (
+	stringPrefix = "v1:"
+
+	// LegacyKeyID is the ID ENCRYPTION_KEY gets in the keyring.
+	LegacyKeyID = "0"
+
+	maxKeyIDLength = 32
+)
+
+var bytesMagic = []byte{'N', 'K', 'R', 1}
+
+var (
+	ErrNoActiveKey = errors.New("no encryption key configured")
+	ErrUnknownKey  = errors.New("ciphertext was sealed with a key that is not in the keyring")
+	ErrDecrypt     = errors.New("no key in the keyring opens the ciphertext")
+)
+
+// Keyring holds the server encryption keys by ID. New data is sealed with
+// the active key, the others are only used to open older data until it is
+// re-encrypted.
+type Keyring struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+// NewKeyring builds the keyring from ENCRYPTION_KEYS ("id:key,id:key"),
+// ENCRYPTION_KEY_ID and the single ENCRYPTION_KEY, which joins the keyring
+// as LegacyKeyID. activeID may be left empty when there is only one key.
+// With no keys at all the keyring is empty and sealing fails.
+func NewKeyring(keys, activeID, legacyKey string) (*Keyring, error) {
+	k := &Keyring{keys: map[string][]byte{}}
+
+	for _, entry := range strings.Split(keys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, key, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("encryption key %q: expected id:key", entry)
+		}
+		if err := k.add(id, []byte(key)); err != nil {
+			return nil, err
+		}
+	}
+
+	if legacyKey != "" {
+		if existing, ok := k.keys[LegacyKeyID]; !ok {
+			if err := k.add(LegacyKeyID, []byte(legacyKey)); err != nil {
+				return nil, err
+			}
+		} else if string(existing) != legacyKey {
+			return nil, fmt.Errorf("encryption key ID %q is taken by ENCRYPTION_KEY", LegacyKeyID)
+		}
+	}
+
+	switch {
+	case activeID != "":
+		if _, ok := k.keys[activeID]; !ok {
+			return nil, fmt.Errorf("active encryption key %q is not in the keyring", activeID)
+		}
+		k.activeID = activeID
+	case len(k.keys) == 1:
+		for id := range k.keys {
+			k.activeID = id
+		}
+	case len(k.keys) > 1:
+		return nil, errors.New("several encryption keys are configured, set the active one with ENCRYPTION_KEY_ID")
+	}
+
+	return k, nil
+}
+
+func (k *Keyring) add(id string, key []byte) error {
+	if !validKeyID(id) {
+		return fmt.Errorf("encryption key ID %q: use up to %d letters, digits, '-' or '_'", id, maxKeyIDLength)
+	}
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("encryption key ID %q is used twice", id)
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("encryption key %q: must be 16, 24 or 32 bytes", id)
+	}
+
+	k.keys[id] = key
+	return nil
+}
+
+func validKeyID(id string) bool {
+	if id == "" || len(id) > maxKeyIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// ActiveID returns the ID of the key new data is sealed with.
+func (k *Keyring) ActiveID() string {
+	return k.activeID
+}
+
+// ActiveKey returns the key new data is sealed with, nil if there is none.
+// Attachment addresses are keyed hashes derived from it too.
+func (k *Keyring) ActiveKey() []byte {
+	return k.keys[k.activeID]
+}
+
+// ActivePrefix is how strings sealed with the active key start. Rows that
+// don't start with it still need re-encrypting.
+func (k *Keyring) ActivePrefix() string {
+	return stringPrefix + k.activeID + ":"
+}
+
+// IsActive reports whether bytes sealed by EncryptBytes use the active key.
+func (k *Keyring) IsActive(raw []byte) bool {
+	id, _, ok := splitBytes(raw)
+	return ok && id == k.activeID
+}
+
+// ordered returns the keys with the active one first, for opening data
+// without a key ID.
+func (k *Keyring) ordered() [][]byte {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		if id != k.activeID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	keys := make([][]byte, 0, len(k.keys))
+	if key, ok := k.keys[k.activeID]; ok {
+		keys = append(keys, key)
+	}
+	for _, id := range ids {
+		keys = append(keys, k.keys[id])
+	}
+	return keys
+}
+
+// splitString splits a labelled string into key ID and base64 body.
+func splitString(s string) (id, body string, ok bool) {
+	rest, ok := strings.CutPrefix(s, stringPrefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
+
+// splitBytes splits labelled bytes into key ID and sealed body.
+func splitBytes(raw []byte) (id string, body []byte, ok bool) {
+	n := len(bytesMagic)
+	if len(raw) <= n || string(raw[:n]) != string(bytesMagic) {
+		return "", nil, false
+	}
+
+	idLen := int(raw[n])
+	if idLen == 0 || len(raw) < n+1+idLen {
+		return "", nil, false
+	}
+
+	return string(raw[n+1 : n+1+idLen]), raw[n+1+idLen:], true
+}
@@ -0,0 +1,126 @@
+package encryption
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	key0 = "0123456789abcdef0123456789abcdef"
+	key1 = "abcdefghijklmnopqrstuvwxyz012345"
+	key2 = "ABCDEFGHIJKLMNOP"
+)
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      string
+		activeID  string
+		legacyKey string
+		wantID    string
+	}{
+		{"empty", "", "", "", ""},
+		{"legacy key only", "", "", key0, LegacyKeyID},
+		{"single key is active", "1:" + key1, "", "", "1"},
+		{"active key", "1:" + key1 + ", 2:" + key2, "2", "", "2"},
+		{"legacy key joins keyring", "1:" + key1, "1", key0, "1"},
+		{"legacy ID repeats ENCRYPTION_KEY", "0:" + key0 + ",1:" + key1, "1", key0, "1"},
+		{"blank entries", ",1:" + key1 + ", ,", "", "", "1"},
+		{"ID characters", "Key_2-b:" + key1, "", "", "Key_2-b"},
+	}
+
+	for _, tt := range tests {
+		k, err := NewKeyring(tt.keys, tt.activeID, tt.legacyKey)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if k.ActiveID() != tt.wantID {
+			t.Errorf("%s: active ID = %q, want %q", tt.name, k.ActiveID(), tt.wantID)
+		}
+	}
+}
+
+func TestNewKeyringInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      string
+		activeID  string
+		legacyKey string
+	}{
+		{"missing colon", key1, "", ""},
+		{"empty ID", ":" + key1, "", ""},
+		{"ID with space", "a b:" + key1, "", ""},
+		{"ID with colon", "a:b:" + key1, "", ""},
+		{"ID too long", strings.Repeat("a", maxKeyIDLength+1) + ":" + key1, "", ""},
+		{"short key", "1:short", "", ""},
+		{"33 byte key", "1:" + key1 + "x", "", ""},
+		{"short legacy key", "", "", "short"},
+		{"ID used twice", "1:" + key1 + ",1:" + key2, "1", ""},
+		{"ID clashes with ENCRYPTION_KEY", "0:" + key1, "0", key0},
+		{"several keys without active ID", "1:" + key1 + ",2:" + key2, "", ""},
+		{"legacy and another key without active ID", "1:" + key1, "", key0},
+		{"unknown active ID", "1:" + key1, "2", ""},
+		{"active ID without keys", "", "1", ""},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewKeyring(tt.keys, tt.activeID, tt.legacyKey); err == nil {
+			t.Errorf("%s: NewKeyring(%q, %q, %q) succeeded, want an error", tt.name, tt.keys, tt.activeID, tt.legacyKey)
+		}
+	}
+}
+
+func TestKeyringActive(t *testing.T) {
+	k, err := NewKeyring("1:"+key1, "1", key0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := k.ActivePrefix(); got != "v1:1:" {
+		t.Errorf("ActivePrefix() = %q, want \"v1:1:\"", got)
+	}
+	if string(k.ActiveKey()) != key1 {
+		t.Errorf("ActiveKey() = %q, want key 1", k.ActiveKey())
+	}
+
+	keys := k.ordered()
+	if len(keys) != 2 || string(keys[0]) != key1 || string(keys[1]) != key0 {
+		t.Errorf("ordered() = %q, want the active key first", keys)
+	}
+
+	tests := []struct {
+		raw  []byte
+		want bool
+	}{
+		{[]byte("NKR\x01\x011sealed"), true},
+		{[]byte("NKR\x01\x010sealed"), false},
+		{[]byte("NKR\x01\x00sealed"), false},
+		{[]byte("NKR\x01\x051"), false},
+		{[]byte("NKR\x02\x011sealed"), false},
+		{[]byte("sealed"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := k.IsActive(tt.raw); got != tt.want {
+			t.Errorf("IsActive(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyKeyring(t *testing.T) {
+	k, err := NewKeyring("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k.ActiveKey() != nil {
+		t.Errorf("ActiveKey() = %q, want nil", k.ActiveKey())
+	}
+	if _, err := EncryptAES(k, "note"); err != ErrNoActiveKey {
+		t.Errorf("EncryptAES error = %v, want ErrNoActiveKey", err)
+	}
+	if _, err := EncryptBytes(k, []byte("file")); err != ErrNoActiveKey {
+		t.Errorf("EncryptBytes error = %v, want ErrNoActiveKey", err)
+	}
+}
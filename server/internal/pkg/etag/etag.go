@@ -0,0 +1,60 @@
+package etag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ETags for versioned resources. The ETag of a note is its version counter
+// in quotes, e.g. "7". Clients send it back in If-Match to make an update
+// conditional on the version they started editing from.
+
+var ErrInvalidHeader = errors.New("invalid If-Match header")
+
+// Format returns the ETag of a version.
+func Format(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ParseIfMatch returns the versions listed in an If-Match header.
+// An empty header or "*" returns nil, meaning the update is unconditional.
+// Weak validators (W/"7") are accepted as if they were strong.
+func ParseIfMatch(header string) ([]int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			return nil, ErrInvalidHeader
+		}
+
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil {
+			return nil, ErrInvalidHeader
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// IfMatch returns the versions an update is conditional on: those in the
+// If-Match header, or else the "version" field of the body. It also returns
+// the status to answer a mismatch with, 412 for If-Match and 409 for the
+// body field.
+func IfMatch(header string, bodyVersion *int64) ([]int64, int, error) {
+	versions, err := ParseIfMatch(header)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if versions == nil && bodyVersion != nil {
+		return []int64{*bodyVersion}, 409, nil
+	}
+	return versions, 412, nil
+}
@@ -0,0 +1,79 @@
+package etag
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	if got := Format(7); got != `"7"` {
+		t.Errorf("Format(7) = %s, want \"7\"", got)
+	}
+}
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []int64
+	}{
+		{``, nil},
+		{`*`, nil},
+		{` * `, nil},
+		{`"7"`, []int64{7}},
+		{`W/"7"`, []int64{7}},
+		{`"3", W/"4" ,"5"`, []int64{3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseIfMatch(tt.header)
+		if err != nil {
+			t.Errorf("ParseIfMatch(%q): %v", tt.header, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseIfMatch(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestParseIfMatchInvalid(t *testing.T) {
+	for _, header := range []string{`7`, `"7`, `"`, `"abc"`, `"7",`, `w/"7"`, `"7" "8"`} {
+		if _, err := ParseIfMatch(header); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("ParseIfMatch(%q) error = %v, want ErrInvalidHeader", header, err)
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	body := int64(9)
+
+	tests := []struct {
+		name       string
+		header     string
+		body       *int64
+		want       []int64
+		wantStatus int
+	}{
+		{"header", `"7"`, nil, []int64{7}, 412},
+		{"header wins over body", `"7"`, &body, []int64{7}, 412},
+		{"body", ``, &body, []int64{9}, 409},
+		{"wildcard falls back to body", `*`, &body, []int64{9}, 409},
+		{"unconditional", ``, nil, nil, 412},
+	}
+
+	for _, tt := range tests {
+		got, status, err := IfMatch(tt.header, tt.body)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) || status != tt.wantStatus {
+			t.Errorf("%s: got %v, %d, want %v, %d", tt.name, got, status, tt.want, tt.wantStatus)
+		}
+	}
+
+	if _, _, err := IfMatch(`7`, &body); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("invalid header with body: error = %v, want ErrInvalidHeader", err)
+	}
+}
@@ -0,0 +1,74 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// enexNote is a <note> element of an Evernote export.
+type enexNote struct {
+	Title   string   `xml:"title"`
+	Content string   `xml:"content"`
+	Created string   `xml:"created"`
+	Updated string   `xml:"updated"`
+	Tags    []string `xml:"tag"`
+}
+
+// ParseENEX reads an Evernote .enex export. Every <note> becomes a note;
+// its ENML content is converted to Markdown. Attachments are not imported.
+func ParseENEX(data []byte) ([]Note, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	// ENEX files declare a DOCTYPE and sometimes non UTF-8 charsets
+	dec.Strict = false
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var notes []Note
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("invalid ENEX file")
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "note" {
+			continue
+		}
+
+		var n enexNote
+		if err := dec.DecodeElement(&n, &start); err != nil {
+			return nil, errors.New("invalid ENEX file")
+		}
+
+		content, err := HTMLToMarkdown(n.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		title := strings.TrimSpace(n.Title)
+		if title == "" {
+			title = "Untitled"
+		}
+
+		notes = append(notes, Note{
+			Title:     title,
+			Content:   content,
+			Tags:      n.Tags,
+			CreatedAt: NormalizeTime(n.Created),
+			UpdatedAt: NormalizeTime(n.Updated),
+		})
+	}
+
+	if len(notes) == 0 {
+		return nil, errors.New("no notes found in ENEX file")
+	}
+
+	return notes, nil
+}
@@ -0,0 +1,60 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testENEX = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-export SYSTEM "http://xml.evernote.com/pub/evernote-export3.dtd">
+<en-export>
+<note>
+  <title>Groceries</title>
+  <content><![CDATA[<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-note SYSTEM "http://xml.evernote.com/pub/enml2.dtd">
+<en-note><div>Buy <b>milk</b></div><en-todo checked="true"/>done<br/></en-note>]]></content>
+  <created>20200102T030405Z</created>
+  <updated>20200203T040506Z</updated>
+  <tag>home</tag>
+  <tag>shopping</tag>
+</note>
+<note>
+  <title> </title>
+  <content><![CDATA[<en-note>second</en-note>]]></content>
+</note>
+</en-export>`
+
+func TestParseENEX(t *testing.T) {
+	notes, err := ParseENEX([]byte(testENEX))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Note{
+		{
+			Title:     "Groceries",
+			Content:   "Buy **milk**\n- [x] done",
+			Tags:      []string{"home", "shopping"},
+			CreatedAt: "2020-01-02T03:04:05Z",
+			UpdatedAt: "2020-02-03T04:05:06Z",
+		},
+		{Title: "Untitled", Content: "second"},
+	}
+	if !reflect.DeepEqual(notes, want) {
+		t.Errorf("ParseENEX() =\n%#v\nwant\n%#v", notes, want)
+	}
+}
+
+func TestParseENEXInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no notes":  `<en-export></en-export>`,
+		"truncated": `<en-export><note><title>x</note>`,
+		"empty":     ``,
+	}
+
+	for name, data := range tests {
+		if _, err := ParseENEX([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+package importer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// HTMLToMarkdown converts an HTML (or Evernote ENML) document to Markdown.
+// It covers the markup note apps actually export: paragraphs and line
+// divs, headings, emphasis, links, images, lists, task checkboxes, quotes,
+// code blocks and simple tables. Unknown elements keep only their text.
+func HTMLToMarkdown(src string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return "", err
+	}
+
+	return nodeToMarkdown(doc), nil
+}
+
+// ParseHTML reads an HTML file. Google Keep Takeout notes are recognized
+// and read with their metadata; any other page becomes one note titled by
+// its <title>, its first heading or the file name.
+func ParseHTML(name string, data []byte) (Note, error) {
+	text, err := decodeText(data)
+	if err != nil {
+		return Note{}, err
+	}
+
+	doc, err := html.Parse(strings.NewReader(text))
+	if err != nil {
+		return Note{}, err
+	}
+
+	if keep := findFirst(doc, func(n *html.Node) bool { return hasClass(n, "note") }); keep != nil {
+		return parseKeepHTML(name, keep), nil
+	}
+
+	body := findFirst(doc, func(n *html.Node) bool { return n.Data == "body" })
+	if body == nil {
+		body = doc
+	}
+
+	note := Note{Content: nodeToMarkdown(body)}
+	if t := findFirst(doc, func(n *html.Node) bool { return n.Data == "title" }); t != nil {
+		note.Title = textOf(t)
+	}
+	if note.Title == "" {
+		note.Title = firstHeading(note.Content)
+	}
+	if note.Title == "" {
+		note.Title = titleFromName(name)
+	}
+
+	return note, nil
+}
+
+// nodeToMarkdown converts the children of an HTML node to Markdown.
+func nodeToMarkdown(n *html.Node) string {
+	w := &mdWriter{}
+	w.children(n)
+	return cleanMarkdown(w.String())
+}
+
+var (
+	spaceRun   = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// mdWriter accumulates Markdown while walking the HTML tree.
+type mdWriter struct {
+	b     strings.Builder
+	pre   bool // inside <pre>: keep whitespace
+	depth int  // list nesting depth
+}
+
+func (w *mdWriter) String() string {
+	return w.b.String()
+}
+
+// write appends inline text, dropping leading spaces at the start of a line.
+func (w *mdWriter) write(s string) {
+	if !w.pre && w.atLineStart() {
+		s = strings.TrimLeft(s, " ")
+	}
+	w.b.WriteString(s)
+}
+
+func (w *mdWriter) atLineStart() bool {
+	s := w.b.String()
+	return s == "" || strings.HasSuffix(s, "\n")
+}
+
+// newline ends the current line, if any.
+func (w *mdWriter) newline() {
+	if !w.atLineStart() {
+		w.b.WriteString("\n")
+	}
+}
+
+// blankLine separates blocks with an empty line.
+func (w *mdWriter) blankLine() {
+	s := w.b.String()
+	switch {
+	case s == "", strings.HasSuffix(s, "\n\n"):
+	case strings.HasSuffix(s, "\n"):
+		w.b.WriteString("\n")
+	default:
+		w.b.WriteString("\n\n")
+	}
+}
+
+// capture renders the children of n into a separate buffer.
+func (w *mdWriter) capture(n *html.Node, depth int, pre bool) string {
+	sub := &mdWriter{pre: pre, depth: depth}
+	sub.children(n)
+	return sub.String()
+}
+
+func (w *mdWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c)
+	}
+}
+
+func (w *mdWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if w.pre {
+			w.write(n.Data)
+		} else {
+			w.write(spaceRun.ReplaceAllString(n.Data, " "))
+		}
+	case html.ElementNode:
+		w.element(n)
+	case html.DocumentNode:
+		w.children(n)
+	}
+}
+
+func (w *mdWriter) element(n *html.Node) {
+	switch tag := n.Data; tag {
+	case "head", "script", "style", "title", "en-media", "object", "embed":
+		// not content
+
+	case "br":
+		w.b.WriteString("\n")
+
+	case "p":
+		w.blankLine()
+		w.children(n)
+		w.blankLine()
+
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(tag[1:])
+		text := oneLine(w.capture(n, w.depth, false))
+		w.blankLine()
+		if text != "" {
+			w.write(strings.Repeat("#", level) + " " + text)
+		}
+		w.blankLine()
+
+	case "strong", "b":
+		w.write(wrapInline(w.capture(n, w.depth, w.pre), "**"))
+	case "em", "i":
+		w.write(wrapInline(w.capture(n, w.depth, w.pre), "*"))
+	case "s", "del", "strike":
+		w.write(wrapInline(w.capture(n, w.depth, w.pre), "~~"))
+
+	case "code":
+		if w.pre {
+			w.children(n)
+		} else {
+			w.write(wrapInline(w.capture(n, w.depth, false), "`"))
+		}
+
+	case "pre":
+		code := strings.Trim(w.capture(n, w.depth, true), "\n")
+		w.blankLine()
+		w.b.WriteString("```\n" + code + "\n```")
+		w.blankLine()
+
+	case "blockquote":
+		inner := strings.TrimSpace(cleanMarkdown(w.capture(n, 0, false)))
+		w.blankLine()
+		for i, line := range strings.Split(inner, "\n") {
+			if i > 0 {
+				w.b.WriteString("\n")
+			}
+			w.b.WriteString(strings.TrimRight("> "+line, " "))
+		}
+		w.blankLine()
+
+	case "hr":
+		w.blankLine()
+		w.b.WriteString("---")
+		w.blankLine()
+
+	case "a":
+		text := oneLine(w.capture(n, w.depth, false))
+		href := strings.TrimSpace(attr(n, "href"))
+		switch {
+		case href == "" || strings.HasPrefix(strings.ToLower(href), "javascript:"):
+			w.write(text)
+		case text == "":
+			w.write("<" + href + ">")
+		default:
+			w.write("[" + text + "](" + href + ")")
+		}
+
+	case "img":
+		src := attr(n, "src")
+		if src != "" && !strings.HasPrefix(src, "data:") {
+			w.write("![" + attr(n, "alt") + "](" + src + ")")
+		}
+
+	case "ul", "ol":
+		w.list(n, tag == "ol")
+
+	case "en-todo":
+		// HTML parsing doesn't honor <en-todo/>, the text after it ends up inside
+		w.checkbox(attr(n, "checked") == "true")
+		w.children(n)
+	case "input":
+		if attr(n, "type") == "checkbox" {
+			_, checked := attrOK(n, "checked")
+			w.checkbox(checked)
+		}
+
+	case "table":
+		w.table(n)
+
+	case "div", "section", "article", "header", "footer", "main", "en-note", "li", "tr", "dl", "dt", "dd":
+		w.newline()
+		w.children(n)
+		w.newline()
+
+	default:
+		w.children(n)
+	}
+}
+
+// checkbox writes a task marker. Outside of a list it starts a task list item.
+func (w *mdWriter) checkbox(checked bool) {
+	mark := "[ ] "
+	if checked {
+		mark = "[x] "
+	}
+	if w.depth == 0 {
+		w.newline()
+		mark = "- " + mark
+	}
+	w.write(mark)
+}
+
+// list writes a (possibly nested) list. Nested lists are indented by
+// two spaces per level.
+func (w *mdWriter) list(n *html.Node, ordered bool) {
+	if w.depth == 0 {
+		w.blankLine()
+	} else {
+		w.newline()
+	}
+
+	indent := strings.Repeat("  ", w.depth)
+	nested := strings.Repeat("  ", w.depth+1)
+	number := 1
+
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.Data != "li" {
+			continue
+		}
+
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+
+		content := strings.Trim(w.capture(li, w.depth+1, false), "\n")
+		first := true
+		for _, line := range strings.Split(content, "\n") {
+			switch {
+			case strings.TrimSpace(line) == "":
+				continue
+			case first:
+				w.b.WriteString(indent + marker + strings.TrimSpace(line) + "\n")
+				first = false
+			case strings.HasPrefix(line, nested):
+				w.b.WriteString(line + "\n") // nested list, already indented
+			default:
+				w.b.WriteString(nested + strings.TrimSpace(line) + "\n")
+			}
+		}
+		if first {
+			w.b.WriteString(indent + strings.TrimSpace(marker) + "\n")
+		}
+	}
+
+	if w.depth == 0 {
+		w.blankLine()
+	}
+}
+
+// table writes a GitHub style pipe table. The first row is the header.
+func (w *mdWriter) table(n *html.Node) {
+	var rows [][]string
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.Data != "tr" {
+				collect(c)
+				continue
+			}
+
+			var cells []string
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+					text := oneLine(w.capture(cell, 1, false))
+					cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+				}
+			}
+			rows = append(rows, cells)
+		}
+	}
+	collect(n)
+
+	if len(rows) == 0 {
+		return
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+
+	w.blankLine()
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		w.b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			w.b.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+		}
+	}
+	w.blankLine()
+}
+
+// wrapInline puts Markdown emphasis markers around text, keeping
+// surrounding spaces outside of the markers.
+func wrapInline(s, marker string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+
+	lead := s[:len(s)-len(strings.TrimLeft(s, " \n"))]
+	trail := s[len(strings.TrimRight(s, " \n")):]
+	return lead + marker + trimmed + marker + trail
+}
+
+func oneLine(s string) string {
+	return strings.TrimSpace(spaceRun.ReplaceAllString(s, " "))
+}
+
+// cleanMarkdown trims trailing spaces and collapses runs of blank lines.
+func cleanMarkdown(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	s = strings.Join(lines, "\n")
+	return strings.TrimSpace(blankLines.ReplaceAllString(s, "\n\n"))
+}
+
+// findFirst returns the first element below n (depth-first) that matches.
+func findFirst(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && match(c) {
+			return c
+		}
+		if found := findFirst(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findAll returns all elements below n that match, in document order.
+// Matching elements are not searched further.
+func findAll(n *html.Node, match func(*html.Node) bool) []*html.Node {
+	var found []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && match(c) {
+			found = append(found, c)
+			continue
+		}
+		found = append(found, findAll(c, match)...)
+	}
+	return found
+}
+
+// textOf returns the text inside n on one line.
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return oneLine(b.String())
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	v, _ := attrOK(n, key)
+	return v
+}
+
+func attrOK(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,171 @@
+// Package importer turns files exported by other note apps into notes.
+// It only parses; creating the notes is up to the caller.
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported file type")
+	ErrEmpty       = errors.New("file is empty")
+	ErrNotText     = errors.New("file is not valid UTF-8 text")
+)
+
+// Note is one imported note. Empty timestamps mean "now".
+type Note struct {
+	Title     string
+	Content   string
+	Tags      []string
+	CreatedAt string // RFC 3339, UTC
+	UpdatedAt string // RFC 3339, UTC
+	Pinned    bool
+	Archived  bool
+	Trashed   bool
+}
+
+// Parse converts one file into notes, based on its extension.
+// Archives are not handled here; the caller unpacks them and calls Parse
+// for every entry.
+func Parse(name string, data []byte) ([]Note, error) {
+	switch Ext(name) {
+	case ".md", ".markdown":
+		text, err := decodeText(data)
+		if err != nil {
+			return nil, err
+		}
+		if isNotionName(name) {
+			return []Note{ParseNotionMarkdown(name, text)}, nil
+		}
+		return []Note{ParseMarkdown(name, text)}, nil
+
+	case ".txt":
+		text, err := decodeText(data)
+		if err != nil {
+			return nil, err
+		}
+		return []Note{ParseText(name, text)}, nil
+
+	case ".enex":
+		return ParseENEX(data)
+
+	case ".html", ".htm":
+		return one(ParseHTML(name, data))
+
+	case ".json":
+		return one(ParseKeepJSON(name, data))
+
+	case ".csv":
+		return one(ParseCSV(name, data))
+
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// Ignored reports whether a file inside an export archive is not a note but
+// metadata of the archive, an OS or the exporting app.
+func Ignored(name string) bool {
+	base := path.Base(name)
+	switch {
+	case strings.HasPrefix(name, "__MACOSX/"), strings.HasPrefix(base, "."):
+		return true
+	case strings.EqualFold(base, "archive_browser.html"): // Google Takeout index page
+		return true
+	case strings.EqualFold(base, "Labels.txt") && path.Base(path.Dir(name)) == "Keep":
+		return true
+	}
+	return false
+}
+
+// Superseded reports whether a file can be left out because another file of
+// the same import holds the same note in a better form, and returns that
+// file: the HTML copy of a Google Keep note next to its JSON, or a Notion
+// database CSV next to its "_all" variant.
+func Superseded(name string, names map[string]bool) (string, bool) {
+	stem := strings.TrimSuffix(name, path.Ext(name))
+
+	switch Ext(name) {
+	case ".html", ".htm":
+		for _, other := range []string{stem + ".json", stem + ".JSON"} {
+			if names[other] {
+				return other, true
+			}
+		}
+	case ".csv":
+		if other := stem + "_all" + path.Ext(name); names[other] {
+			return other, true
+		}
+	}
+	return "", false
+}
+
+// Ext returns the lowercase extension of a file name.
+func Ext(name string) string {
+	return strings.ToLower(path.Ext(name))
+}
+
+// decodeText checks that data is non-empty UTF-8 text and strips a BOM.
+func decodeText(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if len(bytes.TrimSpace(data)) == 0 {
+		return "", ErrEmpty
+	}
+	if !utf8.Valid(data) {
+		return "", ErrNotText
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n"), nil
+}
+
+// one adapts a single-note parser to Parse.
+func one(note Note, err error) ([]Note, error) {
+	if err != nil {
+		return nil, err
+	}
+	return []Note{note}, nil
+}
+
+// titleFromName uses the file name without directory, extension and Notion
+// page ID as title.
+func titleFromName(name string) string {
+	base := path.Base(strings.ReplaceAll(name, `\`, "/"))
+	title := strings.TrimSuffix(base, path.Ext(base))
+	title = strings.TrimSpace(notionID.ReplaceAllString(title, ""))
+	if title == "" || title == "." || title == "/" {
+		return "Untitled"
+	}
+	return title
+}
+
+// timeLayouts are the timestamp formats found in the supported exports.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"20060102T150405Z",        // Evernote
+	"Jan 2, 2006, 3:04:05 PM", // Google Keep HTML
+	"January 2, 2006 3:04 PM", // Notion
+	"January 2, 2006",         // Notion
+}
+
+// NormalizeTime parses a timestamp in one of the known formats and returns
+// it as RFC 3339 in UTC, or "" when it cannot be parsed.
+func NormalizeTime(value string) string {
+	// Newer exports use no-break spaces around times
+	value = strings.NewReplacer("\u00a0", " ", "\u202f", " ").Replace(value)
+	value = strings.TrimSpace(spaceRun.ReplaceAllString(value, " "))
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,222 @@
+package importer
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Google Keep notes come from Google Takeout as one JSON and one HTML file
+// per note (Takeout/Keep/<title>.json and .html). Both carry the same note;
+// the JSON is preferred when both are imported together (see Superseded).
+
+// keepNote is the JSON form of a Keep note.
+type keepNote struct {
+	Title                   string         `json:"title"`
+	TextContent             *string        `json:"textContent"`
+	ListContent             []keepListItem `json:"listContent"`
+	Labels                  []keepLabel    `json:"labels"`
+	Annotations             []keepLink     `json:"annotations"`
+	IsPinned                bool           `json:"isPinned"`
+	IsArchived              bool           `json:"isArchived"`
+	IsTrashed               bool           `json:"isTrashed"`
+	CreatedTimestampUsec    int64          `json:"createdTimestampUsec"`
+	UserEditedTimestampUsec int64          `json:"userEditedTimestampUsec"`
+}
+
+type keepListItem struct {
+	Text      string `json:"text"`
+	IsChecked bool   `json:"isChecked"`
+}
+
+type keepLabel struct {
+	Name string `json:"name"`
+}
+
+// keepLink is a web link Keep attached to a note.
+type keepLink struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// ParseKeepJSON reads a Google Keep note in Takeout JSON format. Labels
+// become tags, checklists become Markdown task lists. Other JSON files
+// are rejected with ErrUnsupported.
+func ParseKeepJSON(name string, data []byte) (Note, error) {
+	text, err := decodeText(data)
+	if err != nil {
+		return Note{}, err
+	}
+
+	var k keepNote
+	if err := json.Unmarshal([]byte(text), &k); err != nil || (k.TextContent == nil && k.ListContent == nil) {
+		return Note{}, ErrUnsupported
+	}
+
+	var content string
+	if k.ListContent != nil {
+		items := make([]keepItem, len(k.ListContent))
+		for i, item := range k.ListContent {
+			items[i] = keepItem{Text: item.Text, Checked: item.IsChecked}
+		}
+		content = keepChecklist(items)
+	} else {
+		content = strings.ReplaceAll(*k.TextContent, "\r\n", "\n")
+	}
+
+	var links []keepLink
+	for _, a := range k.Annotations {
+		if a.URL != "" {
+			links = append(links, a)
+		}
+	}
+	content = appendKeepLinks(content, links)
+
+	note := Note{
+		Title:     keepTitle(name, k.Title, content),
+		Content:   content,
+		CreatedAt: keepTime(k.CreatedTimestampUsec),
+		UpdatedAt: keepTime(k.UserEditedTimestampUsec),
+		Pinned:    k.IsPinned,
+		Archived:  k.IsArchived,
+		Trashed:   k.IsTrashed,
+	}
+	for _, l := range k.Labels {
+		note.Tags = append(note.Tags, l.Name)
+	}
+
+	return note, nil
+}
+
+// parseKeepHTML reads the <div class="note"> of a Keep Takeout HTML file.
+// The HTML form has no creation time; the heading holds the last edit.
+func parseKeepHTML(name string, n *html.Node) Note {
+	var note Note
+
+	if heading := findFirst(n, func(e *html.Node) bool { return hasClass(e, "heading") }); heading != nil {
+		for c := heading.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				if t := NormalizeTime(c.Data); t != "" {
+					note.UpdatedAt = t
+				}
+			}
+		}
+		note.Pinned = findFirst(heading, func(e *html.Node) bool { return hasClass(e, "pinned") }) != nil
+		note.Archived = findFirst(heading, func(e *html.Node) bool { return hasClass(e, "archived") }) != nil
+		note.Trashed = findFirst(heading, func(e *html.Node) bool { return hasClass(e, "trashed") }) != nil
+	}
+
+	var title string
+	if t := findFirst(n, func(e *html.Node) bool { return hasClass(e, "title") }); t != nil {
+		title = textOf(t)
+	}
+
+	if body := findFirst(n, func(e *html.Node) bool { return hasClass(e, "content") }); body != nil {
+		if items := findAll(body, func(e *html.Node) bool { return hasClass(e, "listitem") }); len(items) > 0 {
+			list := make([]keepItem, len(items))
+			for i, item := range items {
+				text := item
+				if t := findFirst(item, func(e *html.Node) bool { return hasClass(e, "text") }); t != nil {
+					text = t
+				}
+				list[i] = keepItem{Text: textOf(text), Checked: hasClass(item, "checked")}
+			}
+			note.Content = keepChecklist(list)
+		} else {
+			note.Content = nodeToMarkdown(body)
+		}
+	}
+
+	var links []keepLink
+	if annotations := findFirst(n, func(e *html.Node) bool { return hasClass(e, "annotations") }); annotations != nil {
+		for _, a := range findAll(annotations, func(e *html.Node) bool { return e.Data == "a" }) {
+			if href := attr(a, "href"); href != "" {
+				links = append(links, keepLink{URL: href, Title: textOf(a)})
+			}
+		}
+	}
+	note.Content = appendKeepLinks(note.Content, links)
+
+	for _, label := range findAll(n, func(e *html.Node) bool { return hasClass(e, "label-name") }) {
+		if tag := textOf(label); tag != "" {
+			note.Tags = append(note.Tags, tag)
+		}
+	}
+
+	note.Title = keepTitle(name, title, note.Content)
+	return note
+}
+
+// keepItem is one checklist entry, from either format.
+type keepItem struct {
+	Text    string
+	Checked bool
+}
+
+// keepChecklist renders a Keep checklist as a Markdown task list.
+func keepChecklist(items []keepItem) string {
+	var b strings.Builder
+	for _, item := range items {
+		if item.Checked {
+			b.WriteString("- [x] ")
+		} else {
+			b.WriteString("- [ ] ")
+		}
+		b.WriteString(oneLine(item.Text))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// appendKeepLinks adds the web links of a note as a list after its content.
+func appendKeepLinks(content string, links []keepLink) string {
+	if len(links) == 0 {
+		return content
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(content, "\n"))
+	b.WriteString("\n\n")
+	for _, l := range links {
+		title := oneLine(l.Title)
+		if title == "" {
+			title = l.URL
+		}
+		b.WriteString("- [" + title + "](" + l.URL + ")\n")
+	}
+	return strings.TrimLeft(b.String(), "\n")
+}
+
+// keepTitle picks a title for a Keep note. Keep notes often have none; the
+// Takeout file is then named after a timestamp, so the first line of the
+// note reads better.
+func keepTitle(name, title, content string) string {
+	if title = strings.TrimSpace(title); title != "" {
+		return title
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "- [ ] "), "- [x] "))
+		if line == "" {
+			continue
+		}
+		if runes := []rune(line); len(runes) > maxDerivedTitle {
+			line = strings.TrimSpace(string(runes[:maxDerivedTitle])) + "…"
+		}
+		return line
+	}
+
+	return titleFromName(name)
+}
+
+// maxDerivedTitle is the length of a title taken from the note text.
+const maxDerivedTitle = 80
+
+func keepTime(usec int64) string {
+	if usec <= 0 {
+		return ""
+	}
+	return time.UnixMicro(usec).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,150 @@
+package importer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseKeepJSON(t *testing.T) {
+	data := `{
+		"title": "",
+		"listContent": [
+			{"text": "milk", "isChecked": true},
+			{"text": "eggs\nand bread", "isChecked": false}
+		],
+		"labels": [{"name": "home"}, {"name": "shopping"}],
+		"annotations": [{"url": "https://example.com", "title": "Shop"}],
+		"isPinned": true,
+		"isArchived": true,
+		"isTrashed": false,
+		"createdTimestampUsec": 1600000000000000,
+		"userEditedTimestampUsec": 1600000060000000
+	}`
+
+	note, err := ParseKeepJSON("Takeout/Keep/2020-09-13T12_26_40.000Z.json", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Note{
+		Title:     "milk",
+		Content:   "- [x] milk\n- [ ] eggs and bread\n\n- [Shop](https://example.com)\n",
+		Tags:      []string{"home", "shopping"},
+		CreatedAt: "2020-09-13T12:26:40Z",
+		UpdatedAt: "2020-09-13T12:27:40Z",
+		Pinned:    true,
+		Archived:  true,
+	}
+	if !reflect.DeepEqual(note, want) {
+		t.Errorf("ParseKeepJSON() =\n%#v\nwant\n%#v", note, want)
+	}
+}
+
+func TestParseKeepJSONText(t *testing.T) {
+	note, err := ParseKeepJSON("Keep/Idea.json", []byte(`{"title": " Idea ", "textContent": "line one\r\nline two", "isTrashed": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Note{Title: "Idea", Content: "line one\nline two", Trashed: true}
+	if !reflect.DeepEqual(note, want) {
+		t.Errorf("ParseKeepJSON() =\n%#v\nwant\n%#v", note, want)
+	}
+}
+
+func TestParseKeepJSONUnsupported(t *testing.T) {
+	for _, data := range []string{`{"name": "not a note"}`, `[1, 2]`, `{`} {
+		if _, err := ParseKeepJSON("x.json", []byte(data)); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("ParseKeepJSON(%s) error = %v, want ErrUnsupported", data, err)
+		}
+	}
+}
+
+func TestParseKeepHTML(t *testing.T) {
+	data := `<html><body><div class="note">
+		<div class="heading"><div class="pinned"></div>Sep 13, 2020, 12:26:40 PM</div>
+		<div class="title">Todo</div>
+		<div class="content">
+			<div class="listitem checked"><span class="text">milk</span></div>
+			<div class="listitem"><span class="text">eggs</span></div>
+		</div>
+		<div class="chips"><span class="label"><span class="label-name">home</span></span></div>
+	</div></body></html>`
+
+	note, err := ParseHTML("Takeout/Keep/Todo.html", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Note{
+		Title:     "Todo",
+		Content:   "- [x] milk\n- [ ] eggs\n",
+		Tags:      []string{"home"},
+		UpdatedAt: "2020-09-13T12:26:40Z",
+		Pinned:    true,
+	}
+	if !reflect.DeepEqual(note, want) {
+		t.Errorf("ParseHTML() =\n%#v\nwant\n%#v", note, want)
+	}
+}
+
+func TestKeepTitle(t *testing.T) {
+	long := ""
+	for len(long) < 2*maxDerivedTitle {
+		long += "word "
+	}
+
+	tests := []struct {
+		name, title, content, want string
+	}{
+		{"a.json", " Given ", "text", "Given"},
+		{"a.json", "", "\n- [ ] first item\nsecond", "first item"},
+		{"Keep/a.json", "", "", "a"},
+		{"a.json", "", long, long[:maxDerivedTitle-1] + "…"},
+	}
+
+	for _, tt := range tests {
+		if got := keepTitle(tt.name, tt.title, tt.content); got != tt.want {
+			t.Errorf("keepTitle(%q, %q, ...) = %q, want %q", tt.name, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoredAndSuperseded(t *testing.T) {
+	for name, want := range map[string]bool{
+		"__MACOSX/Keep/a.json":         true,
+		"Keep/.DS_Store":               true,
+		"Takeout/archive_browser.html": true,
+		"Takeout/Keep/Labels.txt":      true,
+		"Notes/Labels.txt":             false,
+		"Takeout/Keep/a.json":          false,
+	} {
+		if got := Ignored(name); got != want {
+			t.Errorf("Ignored(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	names := map[string]bool{
+		"Keep/a.html":    true,
+		"Keep/a.json":    true,
+		"Keep/b.html":    true,
+		"db abc.csv":     true,
+		"db abc_all.csv": true,
+	}
+	tests := []struct {
+		name, other string
+		ok          bool
+	}{
+		{"Keep/a.html", "Keep/a.json", true},
+		{"Keep/b.html", "", false},
+		{"Keep/a.json", "", false},
+		{"db abc.csv", "db abc_all.csv", true},
+		{"db abc_all.csv", "", false},
+	}
+	for _, tt := range tests {
+		if other, ok := Superseded(tt.name, names); other != tt.other || ok != tt.ok {
+			t.Errorf("Superseded(%q) = %q, %v, want %q, %v", tt.name, other, ok, tt.other, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ParseMarkdown reads a Markdown file with optional YAML front matter.
+//
+// The title comes from the front matter, else from the first heading, else
+// from the file name. Front matter keys understood (others are ignored):
+// title, created_at/created/date, updated_at/updated/modified,
+// tags/tag/keywords, pinned, archived, trashed/deleted.
+// The format written by the NOTORA export is read back unchanged.
+func ParseMarkdown(name, text string) Note {
+	meta, body := splitFrontMatter(text)
+
+	note := Note{Content: strings.TrimLeft(body, "\n")}
+
+	if meta != nil {
+		note.Title = metaString(meta, "title")
+		note.CreatedAt = NormalizeTime(metaString(meta, "created_at", "created", "date"))
+		note.UpdatedAt = NormalizeTime(metaString(meta, "updated_at", "updated", "modified"))
+		note.Tags = metaList(meta, "tags", "tag", "keywords")
+		note.Pinned = metaBool(meta, "pinned")
+		note.Archived = metaBool(meta, "archived")
+		note.Trashed = metaBool(meta, "trashed", "deleted")
+	}
+
+	if note.Title == "" {
+		note.Title = firstHeading(note.Content)
+	}
+	if note.Title == "" {
+		note.Title = titleFromName(name)
+	}
+
+	return note
+}
+
+// ParseText reads a plain text file. The file name is used as the title.
+func ParseText(name, text string) Note {
+	return Note{Title: titleFromName(name), Content: text}
+}
+
+// splitFrontMatter separates a leading "---" YAML block from the body.
+// Without (valid) front matter the whole text is the body.
+func splitFrontMatter(text string) (map[string]interface{}, string) {
+	if !strings.HasPrefix(text, "---\n") {
+		return nil, text
+	}
+
+	rest := text[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		return nil, text
+	}
+
+	block := rest[:end]
+	body := rest[end+len("\n---"):]
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+		// Anything after the closing "---" on the same line is ignored
+		body = body[nl+1:]
+	} else {
+		body = ""
+	}
+
+	var meta map[string]interface{}
+	if err := yaml.Unmarshal([]byte(block), &meta); err != nil {
+		return nil, text
+	}
+
+	return meta, body
+}
+
+// firstHeading returns the text of the first ATX heading ("# Title").
+func firstHeading(text string) string {
+	inFence := false
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || !strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+		heading := trimmed[level:]
+		if level > 6 || (heading != "" && heading[0] != ' ' && heading[0] != '\t') {
+			continue
+		}
+
+		heading = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(heading), "#"))
+		if heading != "" {
+			return heading
+		}
+	}
+
+	return ""
+}
+
+// metaString returns the first of the keys that is set, as a string.
+func metaString(meta map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch v := meta[key].(type) {
+		case nil:
+			continue
+		case string:
+			return strings.TrimSpace(v)
+		case time.Time:
+			return v.Format(time.RFC3339)
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+// metaList accepts both a YAML list and a comma separated string.
+func metaList(meta map[string]interface{}, keys ...string) []string {
+	for _, key := range keys {
+		var items []string
+
+		switch v := meta[key].(type) {
+		case nil:
+			continue
+		case []interface{}:
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+		case string:
+			items = strings.Split(v, ",")
+		default:
+			items = []string{fmt.Sprint(v)}
+		}
+
+		var list []string
+		for _, item := range items {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func metaBool(meta map[string]interface{}, keys ...string) bool {
+	for _, key := range keys {
+		switch v := meta[key].(type) {
+		case bool:
+			return v
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "yes", "1":
+				return true
+			}
+			return false
+		}
+	}
+	return false
+}
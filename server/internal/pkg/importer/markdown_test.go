@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name, file, text string
+		want             Note
+	}{
+		{
+			name: "front matter",
+			file: "notes/file.md",
+			text: "---\ntitle: Front\ntags: [a, b]\ncreated: 2021-05-06\npinned: true\n---\n\n# Heading\nbody\n",
+			want: Note{
+				Title:     "Front",
+				Content:   "# Heading\nbody\n",
+				Tags:      []string{"a", "b"},
+				CreatedAt: "2021-05-06T00:00:00Z",
+				Pinned:    true,
+			},
+		},
+		{
+			name: "comma separated tags, title from file name",
+			file: "notes/My note.md",
+			text: "---\ntags: a, b\n---\n",
+			want: Note{Title: "My note", Tags: []string{"a", "b"}},
+		},
+		{
+			name: "first heading outside code fences",
+			file: "file.md",
+			text: "```\n# not this\n```\n#nor this\n## Real ##\n",
+			want: Note{Title: "Real", Content: "```\n# not this\n```\n#nor this\n## Real ##\n"},
+		},
+		{
+			name: "unclosed front matter is content",
+			file: "file.md",
+			text: "---\ntitle: x\n",
+			want: Note{Title: "file", Content: "---\ntitle: x\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := ParseMarkdown(tt.file, tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseMarkdown() =\n%#v\nwant\n%#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	notes, err := Parse("x.md", []byte("\xef\xbb\xbf# T\r\nline\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Note{{Title: "T", Content: "# T\nline\n"}}; !reflect.DeepEqual(notes, want) {
+		t.Errorf("Parse() = %#v, want %#v", notes, want)
+	}
+
+	errTests := []struct {
+		file, data string
+		want       error
+	}{
+		{"x.md", "  \n", ErrEmpty},
+		{"x.txt", "\xff\xfe", ErrNotText},
+		{"x.pdf", "%PDF", ErrUnsupported},
+	}
+	for _, tt := range errTests {
+		if _, err := Parse(tt.file, []byte(tt.data)); err != tt.want {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.file, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTime(t *testing.T) {
+	tests := map[string]string{
+		"2021-05-06T07:08:09+02:00": "2021-05-06T05:08:09Z",
+		"2021-05-06 07:08":          "2021-05-06T07:08:00Z",
+		"20200102T030405Z":          "2020-01-02T03:04:05Z",
+		"Jan 2, 2006, 3:04:05 PM":   "2006-01-02T15:04:05Z",
+		"January  2, 2006 3:04 PM":  "2006-01-02T15:04:00Z",
+		"yesterday":                 "",
+		"":                          "",
+	}
+
+	for in, want := range tests {
+		if got := NormalizeTime(in); got != want {
+			t.Errorf("NormalizeTime(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
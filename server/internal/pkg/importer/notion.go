@@ -0,0 +1,138 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Notion exports a workspace as Markdown pages and CSV databases. Files and
+// folders are named "<Title> <32 hex page ID>"; a database comes as
+// "<Name> <id>.csv" (newer exports add "<Name> <id>_all.csv" with every row)
+// next to a folder holding one Markdown page per row.
+
+var (
+	notionID = regexp.MustCompile(`\s+[0-9a-f]{32}$`)
+
+	// notionProperty matches a "Name: value" line of a page's property block.
+	notionProperty = regexp.MustCompile(`^([^:\n]{1,50}):\s(.*)$`)
+)
+
+// isNotionName reports whether a file name carries a Notion page ID.
+func isNotionName(name string) bool {
+	base := path.Base(strings.ReplaceAll(name, `\`, "/"))
+	stem := strings.TrimSuffix(strings.TrimSuffix(base, path.Ext(base)), "_all")
+	return notionID.MatchString(stem)
+}
+
+// ParseNotionMarkdown reads a page of a Notion Markdown export. The title is
+// the leading heading. Database rows start with a block of "Name: value"
+// property lines; tags and creation/edit times are taken from it and the
+// block itself stays in the content.
+func ParseNotionMarkdown(name, text string) Note {
+	note := ParseMarkdown(name, text)
+
+	props := notionProperties(note.Content)
+	for key, value := range props {
+		switch strings.ToLower(key) {
+		case "tags", "tag", "labels", "keywords":
+			for _, tag := range strings.Split(value, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					note.Tags = append(note.Tags, tag)
+				}
+			}
+		case "created", "created time", "created at", "date created":
+			note.CreatedAt = NormalizeTime(value)
+		case "last edited time", "last edited", "updated", "updated at":
+			note.UpdatedAt = NormalizeTime(value)
+		}
+	}
+
+	return note
+}
+
+// notionProperties returns the property block that follows the leading
+// heading, or nil when there is none.
+func notionProperties(content string) map[string]string {
+	lines := strings.Split(content, "\n")
+
+	i := 0
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	if i == len(lines) || !strings.HasPrefix(lines[i], "# ") {
+		return nil
+	}
+	i++
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+
+	props := map[string]string{}
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		m := notionProperty.FindStringSubmatch(lines[i])
+		if m == nil {
+			// Not a property block, just a paragraph
+			return nil
+		}
+		props[strings.TrimSpace(m[1])] = strings.TrimSpace(m[2])
+	}
+
+	return props
+}
+
+// ParseCSV reads a CSV file, such as a Notion database export, into a note
+// holding the data as a Markdown table. The rows of a Notion database are
+// also exported as pages of their own, so they are not split into notes.
+func ParseCSV(name string, data []byte) (Note, error) {
+	text, err := decodeText(data)
+	if err != nil {
+		return Note{}, err
+	}
+
+	r := csv.NewReader(strings.NewReader(text))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return Note{}, errors.New("invalid CSV file")
+	}
+	if len(records) == 0 {
+		return Note{}, ErrEmpty
+	}
+
+	width := 0
+	for _, rec := range records {
+		width = max(width, len(rec))
+	}
+
+	var b strings.Builder
+	for i, rec := range records {
+		b.WriteString("|")
+		for col := 0; col < width; col++ {
+			cell := ""
+			if col < len(rec) {
+				cell = csvCell(rec[col])
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+		}
+	}
+
+	stem := strings.TrimSuffix(strings.TrimSuffix(name, path.Ext(name)), "_all")
+	return Note{Title: titleFromName(stem + ".csv"), Content: b.String()}, nil
+}
+
+// csvCell makes a CSV value safe to use as a Markdown table cell.
+func csvCell(value string) string {
+	value = strings.ReplaceAll(value, "|", `\|`)
+	value = strings.ReplaceAll(value, "\r\n", "<br>")
+	return strings.TrimSpace(strings.ReplaceAll(value, "\n", "<br>"))
+}
@@ -0,0 +1,82 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNotionMarkdown(t *testing.T) {
+	text := "# Meeting notes\n\nTags: work, weekly\nCreated: January 2, 2006 3:04 PM\nLast edited time: January 3, 2006\n\nAgenda: none\n"
+
+	notes, err := Parse("Workspace/Meeting notes 0123456789abcdef0123456789abcdef.md", []byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Note{{
+		Title:     "Meeting notes",
+		Content:   text,
+		Tags:      []string{"work", "weekly"},
+		CreatedAt: "2006-01-02T15:04:00Z",
+		UpdatedAt: "2006-01-03T00:00:00Z",
+	}}
+	if !reflect.DeepEqual(notes, want) {
+		t.Errorf("Parse() =\n%#v\nwant\n%#v", notes, want)
+	}
+}
+
+func TestNotionProperties(t *testing.T) {
+	tests := []struct {
+		content string
+		want    map[string]string
+	}{
+		{"# Page\n\nStatus: done\nTags: a\n\nbody", map[string]string{"Status": "done", "Tags": "a"}},
+		{"# Page\n\nStatus: done\nnot a property\n", nil},
+		{"Status: done\n", nil},
+		{"# Page\n", map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		if got := notionProperties(tt.content); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("notionProperties(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestIsNotionName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"Page 0123456789abcdef0123456789abcdef.md":        true,
+		`Dir\Db 0123456789abcdef0123456789abcdef_all.csv`: true,
+		"Page.md": false,
+		"Page 0123456789ABCDEF0123456789ABCDEF.md": false,
+	} {
+		if got := isNotionName(name); got != want {
+			t.Errorf("isNotionName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	data := "Name,Tags\r\nFirst,\"a|b\"\r\n\"Multi\nline\"\r\nShort\r\n"
+
+	note, err := ParseCSV("Tasks 0123456789abcdef0123456789abcdef_all.csv", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Note{
+		Title: "Tasks",
+		Content: "| Name | Tags |\n" +
+			"| --- | --- |\n" +
+			"| First | a\\|b |\n" +
+			"| Multi<br>line |  |\n" +
+			"| Short |  |\n",
+	}
+	if !reflect.DeepEqual(note, want) {
+		t.Errorf("ParseCSV() =\n%#v\nwant\n%#v", note, want)
+	}
+
+	if _, err := ParseCSV("empty.csv", []byte(" ")); err != ErrEmpty {
+		t.Errorf("empty CSV: error = %v, want ErrEmpty", err)
+	}
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMessage(t *testing.T) {
+	date := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	msg, err := message("notora@example.com", "user@example.com", "Your token", "line one\nline two\r\n", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "From: notora@example.com\r\n" +
+		"To: user@example.com\r\n" +
+		"Subject: Your token\r\n" +
+		"Date: Mon, 04 Mar 2024 05:06:07 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		"line one\r\nline two\r\n"
+	if string(msg) != want {
+		t.Errorf("message() =\n%q\nwant\n%q", msg, want)
+	}
+}
+
+func TestMessageRejectsHeaderInjection(t *testing.T) {
+	tests := [][3]string{
+		{"notora@example.com\r\nBcc: x@example.com", "user@example.com", "s"},
+		{"notora@example.com", "user@example.com\nBcc: x@example.com", "s"},
+		{"notora@example.com", "user@example.com", "s\r\nBcc: x@example.com"},
+	}
+
+	for _, tt := range tests {
+		if _, err := message(tt[0], tt[1], tt[2], "body", time.Now()); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("message(%q, %q, %q) error = %v, want ErrInvalidHeader", tt[0], tt[1], tt[2], err)
+		}
+	}
+
+	if err := (LogMailer{}).Send("user@example.com\nBcc: x", "s", "b"); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("LogMailer.Send() error = %v, want ErrInvalidHeader", err)
+	}
+}
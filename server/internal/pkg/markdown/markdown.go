@@ -0,0 +1,86 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	renderhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"golang.org/x/net/html"
+)
+
+// renderer converts GitHub flavoured Markdown to HTML. It runs in goldmark's
+// safe mode: raw HTML in the source is dropped and javascript:, vbscript:,
+// file: and non-image data: URLs are blanked (autolinks to them are left as
+// text), so the output can be embedded in a page as is.
+var renderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(
+		parser.WithASTTransformers(util.Prioritized(autoLinkFilter{}, 1000)),
+	),
+)
+
+// autoLinkFilter turns autolinks to dangerous URLs (<javascript:...>) into
+// plain text. Safe mode only blanks the URLs of links and images.
+type autoLinkFilter struct{}
+
+func (autoLinkFilter) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var unsafe []*ast.AutoLink
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*ast.AutoLink); ok && entering && renderhtml.IsDangerousURL(link.URL(source)) {
+			unsafe = append(unsafe, link)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, link := range unsafe {
+		link.Parent().ReplaceChild(link.Parent(), link, ast.NewString(link.Label(source)))
+	}
+}
+
+// ToHTML renders Markdown to sanitized HTML.
+func ToHTML(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Excerpt returns the first maxRunes characters of the text of rendered
+// Markdown, with whitespace collapsed, for previews and meta descriptions.
+func Excerpt(renderedHTML string, maxRunes int) string {
+	doc, err := html.Parse(strings.NewReader(renderedHTML))
+	if err != nil {
+		return ""
+	}
+
+	var text strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+			text.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	excerpt := strings.Join(strings.Fields(text.String()), " ")
+	if utf8.RuneCountInString(excerpt) <= maxRunes {
+		return excerpt
+	}
+
+	runes := []rune(excerpt)[:maxRunes]
+	return strings.TrimRight(string(runes), " ") + "…"
+}
@@ -0,0 +1,79 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToHTMLSanitizes(t *testing.T) {
+	tests := []struct {
+		source, want string
+	}{
+		{"<script>alert(1)</script>", "<!-- raw HTML omitted -->\n"},
+		{"hi <img src=x onerror=alert(1)> there", "<p>hi <!-- raw HTML omitted --> there</p>\n"},
+		{"[x](javascript:alert(1))", `<p><a href="">x</a></p>` + "\n"},
+		{"[x](JaVaScRiPt:alert(1))", `<p><a href="">x</a></p>` + "\n"},
+		{"[x](vbscript:msgbox)", `<p><a href="">x</a></p>` + "\n"},
+		{"[x](file:///etc/passwd)", `<p><a href="">x</a></p>` + "\n"},
+		{"[x](data:text/html;base64,PHNjcmlwdD4=)", `<p><a href="">x</a></p>` + "\n"},
+		{"<javascript:alert(1)>", "<p>javascript:alert(1)</p>\n"},
+		{"<VBSCRIPT:msgbox>", "<p>VBSCRIPT:msgbox</p>\n"},
+		{"`<b>`", "<p><code>&lt;b&gt;</code></p>\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := ToHTML(tt.source)
+		if err != nil {
+			t.Errorf("ToHTML(%q): %v", tt.source, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ToHTML(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestToHTMLKeepsSafeMarkup(t *testing.T) {
+	tests := []struct {
+		source, want string
+	}{
+		{"![x](data:image/png;base64,iVBORw0KGgo=)", `<img src="data:image/png;base64,iVBORw0KGgo=" alt="x">`},
+		{`[x](https://example.com "t")`, `<a href="https://example.com" title="t">x</a>`},
+		{"<https://example.com>", `<a href="https://example.com">https://example.com</a>`},
+		{"see www.example.com", `<a href="http://www.example.com">www.example.com</a>`},
+		{"~~gone~~", "<del>gone</del>"},
+		{"- [x] done", `<input checked="" disabled="" type="checkbox"> done`},
+		{"| a |\n|---|\n| 1 |", "<td>1</td>"},
+	}
+
+	for _, tt := range tests {
+		got, err := ToHTML(tt.source)
+		if err != nil {
+			t.Errorf("ToHTML(%q): %v", tt.source, err)
+			continue
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("ToHTML(%q) = %q, want it to contain %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		html     string
+		maxRunes int
+		want     string
+	}{
+		{"<p>Hello   <b>big</b>\nworld</p><p>again</p>", 100, "Hello big world again"},
+		{"<p>Hello world again</p>", 6, "Hello…"},
+		{"<p>héllo</p>", 3, "hél…"},
+		{"<p>exact</p>", 5, "exact"},
+		{"", 10, ""},
+	}
+
+	for _, tt := range tests {
+		if got := Excerpt(tt.html, tt.maxRunes); got != tt.want {
+			t.Errorf("Excerpt(%q, %d) = %q, want %q", tt.html, tt.maxRunes, got, tt.want)
+		}
+	}
+}
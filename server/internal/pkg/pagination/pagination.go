@@ -0,0 +1,174 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Keyset (cursor) pagination helpers shared by the note listing endpoints.
+//
+// A cursor is an opaque, URL-safe string that encodes the sort it belongs to
+// and the sort key of the last row of the previous page. The next page starts
+// strictly after that row, so pages stay stable while notes are created or
+// deleted, and no OFFSET scan is needed.
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+type Sort string
+
+const (
+	SortUpdated Sort = "updated" // most recently updated first (default)
+	SortCreated Sort = "created" // most recently created first
+	SortTitle   Sort = "title"   // alphabetical, case-insensitive
+	SortPinned  Sort = "pinned"  // pinned notes first, then most recently updated
+)
+
+// Cursor is the decoded form of a page cursor.
+type Cursor struct {
+	Sort   Sort   `json:"s"`
+	Value  string `json:"v"`
+	Pinned int    `json:"p,omitempty"`
+	ID     int64  `json:"id"`
+}
+
+// Row holds the sort keys of a listed row, used to build the next cursor.
+type Row struct {
+	ID        int64
+	Title     string
+	Pinned    bool
+	CreatedAt string
+	UpdatedAt string
+}
+
+// ParseSort validates a sort name. An empty string means SortUpdated.
+// allowed restricts which sorts the caller supports; nil allows all.
+func ParseSort(value string, allowed ...Sort) (Sort, error) {
+	sort := Sort(value)
+	if sort == "" {
+		sort = SortUpdated
+	}
+
+	switch sort {
+	case SortUpdated, SortCreated, SortTitle, SortPinned:
+	default:
+		return "", ErrInvalidSort
+	}
+
+	if len(allowed) == 0 {
+		return sort, nil
+	}
+	for _, a := range allowed {
+		if a == sort {
+			return sort, nil
+		}
+	}
+	return "", ErrInvalidSort
+}
+
+// ClampLimit applies the default and maximum page size.
+func ClampLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+	if limit > MaxLimit {
+		return MaxLimit
+	}
+	return limit
+}
+
+// Decode parses an opaque cursor and checks it was issued for the same sort.
+// An empty string means "first page" and returns nil.
+func Decode(value string, sort Sort) (*Cursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// Next builds the cursor pointing after the given row.
+func Next(sort Sort, row Row) string {
+	c := Cursor{Sort: sort, ID: row.ID}
+
+	switch sort {
+	case SortCreated:
+		c.Value = row.CreatedAt
+	case SortTitle:
+		c.Value = row.Title
+	case SortPinned:
+		c.Value = row.UpdatedAt
+		if row.Pinned {
+			c.Pinned = 1
+		}
+	default:
+		c.Value = row.UpdatedAt
+	}
+
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// OrderBy returns the ORDER BY expression for a sort. id is always the
+// final tie-breaker so the order is total.
+func (s Sort) OrderBy() string {
+	switch s {
+	case SortCreated:
+		return "created_at DESC, id DESC"
+	case SortTitle:
+		return "title COLLATE NOCASE ASC, id ASC"
+	case SortPinned:
+		return "is_pinned DESC, updated_at DESC, id DESC"
+	default:
+		return "updated_at DESC, id DESC"
+	}
+}
+
+// After returns a WHERE condition (and its arguments) that selects the rows
+// following the cursor in this sort order.
+func (s Sort) After(c *Cursor) (string, []interface{}) {
+	switch s {
+	case SortCreated:
+		return "(created_at < ? OR (created_at = ? AND id < ?))",
+			[]interface{}{c.Value, c.Value, c.ID}
+	case SortTitle:
+		return "(title COLLATE NOCASE > ? OR (title COLLATE NOCASE = ? AND id > ?))",
+			[]interface{}{c.Value, c.Value, c.ID}
+	case SortPinned:
+		return "(is_pinned < ? OR (is_pinned = ? AND (updated_at < ? OR (updated_at = ? AND id < ?))))",
+			[]interface{}{c.Pinned, c.Pinned, c.Value, c.Value, c.ID}
+	default:
+		return "(updated_at < ? OR (updated_at = ? AND id < ?))",
+			[]interface{}{c.Value, c.Value, c.ID}
+	}
+}
+
+// Trim cuts a result fetched with limit+1 rows down to limit and returns the
+// cursor of the next page, or nil when this was the last page.
+func Trim[T any](items []T, limit int, sort Sort, row func(T) Row) ([]T, *string) {
+	if len(items) <= limit {
+		return items, nil
+	}
+
+	items = items[:limit]
+	next := Next(sort, row(items[limit-1]))
+	return items, &next
+}
@@ -0,0 +1,123 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		value   string
+		allowed []Sort
+		want    Sort
+		wantErr bool
+	}{
+		{value: "", want: SortUpdated},
+		{value: "title", want: SortTitle},
+		{value: "pinned", want: SortPinned},
+		{value: "random", wantErr: true},
+		{value: "created", allowed: []Sort{SortUpdated, SortCreated}, want: SortCreated},
+		{value: "title", allowed: []Sort{SortUpdated, SortCreated}, wantErr: true},
+		{value: "", allowed: []Sort{SortCreated}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSort(tt.value, tt.allowed...)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSort(%q, %v) = %q, %v", tt.value, tt.allowed, got, err)
+		}
+	}
+}
+
+func TestClampLimit(t *testing.T) {
+	for in, want := range map[int]int{-1: DefaultLimit, 0: DefaultLimit, 1: 1, MaxLimit: MaxLimit, MaxLimit + 1: MaxLimit} {
+		if got := ClampLimit(in); got != want {
+			t.Errorf("ClampLimit(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	row := Row{ID: 42, Title: "Émile & co", Pinned: true, CreatedAt: "2024-01-02T03:04:05Z", UpdatedAt: "2024-05-06T07:08:09Z"}
+
+	tests := []struct {
+		sort Sort
+		want Cursor
+	}{
+		{SortUpdated, Cursor{Sort: SortUpdated, Value: row.UpdatedAt, ID: 42}},
+		{SortCreated, Cursor{Sort: SortCreated, Value: row.CreatedAt, ID: 42}},
+		{SortTitle, Cursor{Sort: SortTitle, Value: row.Title, ID: 42}},
+		{SortPinned, Cursor{Sort: SortPinned, Value: row.UpdatedAt, Pinned: 1, ID: 42}},
+	}
+
+	for _, tt := range tests {
+		next := Next(tt.sort, row)
+		got, err := Decode(next, tt.sort)
+		if err != nil {
+			t.Errorf("Decode(Next(%s)): %v", tt.sort, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("Decode(Next(%s)) = %+v, want %+v", tt.sort, *got, tt.want)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if c, err := Decode("", SortTitle); c != nil || err != nil {
+		t.Errorf("Decode(\"\") = %v, %v, want first page", c, err)
+	}
+
+	tests := map[string]string{
+		"not base64":     "***",
+		"not json":       base64.RawURLEncoding.EncodeToString([]byte("nope")),
+		"other sort":     Next(SortCreated, Row{ID: 1}),
+		"padded base64":  base64.URLEncoding.EncodeToString([]byte(`{"s":"title","v":"a","id":1}`)),
+		"standard chars": "+/+/",
+	}
+	for name, value := range tests {
+		if _, err := Decode(value, SortTitle); err != ErrInvalidCursor {
+			t.Errorf("%s: Decode(%q) error = %v, want ErrInvalidCursor", name, value, err)
+		}
+	}
+}
+
+func TestAfter(t *testing.T) {
+	c := &Cursor{Value: "v", Pinned: 1, ID: 7}
+
+	for _, sort := range []Sort{SortUpdated, SortCreated, SortTitle, SortPinned} {
+		cond, args := sort.After(c)
+		placeholders := 0
+		for _, r := range cond {
+			if r == '?' {
+				placeholders++
+			}
+		}
+		if placeholders != len(args) {
+			t.Errorf("%s: %d placeholders, %d args", sort, placeholders, len(args))
+		}
+		if args[len(args)-1] != int64(7) {
+			t.Errorf("%s: last arg = %v, want the id", sort, args[len(args)-1])
+		}
+	}
+}
+
+func TestTrim(t *testing.T) {
+	items := []int64{5, 4, 3}
+	row := func(id int64) Row { return Row{ID: id, UpdatedAt: "t"} }
+
+	got, next := Trim(items, 3, SortUpdated, row)
+	if !reflect.DeepEqual(got, items) || next != nil {
+		t.Errorf("last page: got %v, next %v", got, next)
+	}
+
+	got, next = Trim(items, 2, SortUpdated, row)
+	if !reflect.DeepEqual(got, []int64{5, 4}) || next == nil {
+		t.Fatalf("more pages: got %v, next %v", got, next)
+	}
+	c, err := Decode(*next, SortUpdated)
+	if err != nil || c.ID != 4 {
+		t.Errorf("next cursor = %+v, %v, want after id 4", c, err)
+	}
+}
@@ -0,0 +1,252 @@
+package search
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// The full-text index never stores plaintext. Every word is replaced by a
+// keyed hash ("blind token") before it is written to the FTS5 table, and
+// queries are hashed the same way before they are matched. Ranking (BM25),
+// phrases and boolean operators keep working because FTS5 only ever compares
+// whole tokens and their positions.
+//
+// Prefix queries cannot work on hashes directly, so every word additionally
+// contributes hashed prefixes (MinPrefixLength..MaxPrefixLength runes) to a
+// separate "prefixes" column.
+
+const (
+	MinPrefixLength = 2
+	MaxPrefixLength = 12
+
+	// blindTokenBytes is how much of the HMAC is kept per token.
+	blindTokenBytes = 10
+)
+
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// Document is the blinded form of a note, ready to be written to the index.
+type Document struct {
+	Title    string
+	Body     string
+	Prefixes string
+}
+
+// Query is a parsed search query.
+type Query struct {
+	// Expr is the FTS5 MATCH expression over blind tokens.
+	Expr string
+	// Words and Prefixes are the (plaintext, lowercase) positive search terms,
+	// used to highlight snippets after the notes are decrypted.
+	Words    []string
+	Prefixes []string
+}
+
+// Indexer hashes words with a secret key.
+type Indexer struct {
+	key []byte
+}
+
+// NewIndexer derives the index key from a server secret so the blind
+// tokens cannot be reproduced without it. The secret must outlive server
+// encryption key changes; a new secret means a new index.
+func NewIndexer(secret []byte) *Indexer {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("notora-search-index"))
+	return &Indexer{key: mac.Sum(nil)}
+}
+
+// KeyCheck identifies the index key without revealing it, so an index
+// built with another key can be recognized.
+func (ix *Indexer) KeyCheck() string {
+	mac := hmac.New(sha256.New, ix.key)
+	mac.Write([]byte("key-check"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Tokenize lowercases text and splits it into words made of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Document builds the blinded index entry for a note.
+func (ix *Indexer) Document(title, body string) Document {
+	titleWords := Tokenize(title)
+	bodyWords := Tokenize(body)
+
+	var prefixes []string
+	seen := map[string]bool{}
+
+	for _, words := range [][]string{titleWords, bodyWords} {
+		for _, word := range words {
+			runes := []rune(word)
+			for n := MinPrefixLength; n <= len(runes) && n <= MaxPrefixLength; n++ {
+				prefix := string(runes[:n])
+				if seen[prefix] {
+					continue
+				}
+				seen[prefix] = true
+				prefixes = append(prefixes, ix.blind("p", prefix))
+			}
+		}
+	}
+
+	return Document{
+		Title:    ix.blindAll(titleWords),
+		Body:     ix.blindAll(bodyWords),
+		Prefixes: strings.Join(prefixes, " "),
+	}
+}
+
+// ParseQuery turns a user query into an FTS5 expression over blind tokens.
+//
+// Supported syntax:
+//
+//	word            matches the word in title or body
+//	"some phrase"   matches the words next to each other, in order
+//	pre*            matches words starting with "pre"
+//	AND, OR, NOT    boolean operators (upper case), adjacent terms are ANDed
+//	( ... )         grouping
+//
+// Operators need a term on both sides (NOT is "a NOT b"), groups can't be
+// empty. Anything else returns ErrInvalidQuery, so FTS5 never sees a
+// malformed expression.
+func (ix *Indexer) ParseQuery(input string) (*Query, error) {
+	q := &Query{}
+	var parts []string
+	negate := false
+	depth := 0
+	needTerm := true // at the start, after an operator or after "("
+
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+
+		case r == '(' || r == ')':
+			if r == '(' {
+				depth++
+				needTerm = true
+			} else {
+				depth--
+				if needTerm {
+					return nil, ErrInvalidQuery
+				}
+			}
+			if depth < 0 {
+				return nil, ErrInvalidQuery
+			}
+			parts = append(parts, string(r))
+			i++
+			continue
+
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, ErrInvalidQuery
+			}
+			words := Tokenize(string(runes[i+1 : end]))
+			i = end + 1
+			if len(words) == 0 {
+				continue
+			}
+			parts = append(parts, `{title body} : "`+ix.blindAll(words)+`"`)
+			if !negate {
+				q.Words = append(q.Words, words...)
+			}
+			negate = false
+			needTerm = false
+			continue
+		}
+
+		end := i
+		for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()"`, runes[end]) {
+			end++
+		}
+		raw := string(runes[i:end])
+		i = end
+
+		switch raw {
+		case "AND", "OR", "NOT":
+			if needTerm {
+				return nil, ErrInvalidQuery
+			}
+			parts = append(parts, raw)
+			negate = raw == "NOT"
+			needTerm = true
+			continue
+		}
+
+		prefix := strings.HasSuffix(raw, "*")
+		words := Tokenize(strings.TrimSuffix(raw, "*"))
+		if len(words) == 0 {
+			continue
+		}
+
+		if prefix {
+			// Only the last word of something like "e-ma*" is a prefix
+			last := []rune(words[len(words)-1])
+			if len(last) < MinPrefixLength {
+				return nil, ErrInvalidQuery
+			}
+			if len(last) > MaxPrefixLength {
+				last = last[:MaxPrefixLength]
+			}
+
+			expr := `prefixes : "` + ix.blind("p", string(last)) + `"`
+			if len(words) > 1 {
+				expr = `({title body} : "` + ix.blindAll(words[:len(words)-1]) + `" AND ` + expr + `)`
+			}
+			parts = append(parts, expr)
+
+			if !negate {
+				q.Words = append(q.Words, words[:len(words)-1]...)
+				q.Prefixes = append(q.Prefixes, string(last))
+			}
+		} else {
+			parts = append(parts, `{title body} : "`+ix.blindAll(words)+`"`)
+			if !negate {
+				q.Words = append(q.Words, words...)
+			}
+		}
+		negate = false
+		needTerm = false
+	}
+
+	if depth != 0 || needTerm || len(q.Words)+len(q.Prefixes) == 0 {
+		return nil, ErrInvalidQuery
+	}
+
+	q.Expr = strings.Join(parts, " ")
+	return q, nil
+}
+
+func (ix *Indexer) blindAll(words []string) string {
+	blinded := make([]string, len(words))
+	for i, word := range words {
+		blinded[i] = ix.blind("t", word)
+	}
+	return strings.Join(blinded, " ")
+}
+
+// blind returns the keyed hash of a word. kind separates full words ("t")
+// from prefixes ("p") so the two never collide. The leading letter keeps
+// FTS5 from treating a hash as a number or keyword.
+func (ix *Indexer) blind(kind, word string) string {
+	mac := hmac.New(sha256.New, ix.key)
+	mac.Write([]byte(kind + ":" + word))
+	return "x" + hex.EncodeToString(mac.Sum(nil)[:blindTokenBytes])
+}
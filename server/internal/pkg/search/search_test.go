@@ -0,0 +1,154 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"Hello, World!", []string{"hello", "world"}},
+		{"e-mail user@example.com", []string{"e", "mail", "user", "example", "com"}},
+		{"Ünïcödé 123abc", []string{"ünïcödé", "123abc"}},
+		{"  \t\n ", nil},
+	}
+
+	for _, tt := range tests {
+		got := Tokenize(tt.in)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDocumentIsBlinded(t *testing.T) {
+	ix := NewIndexer([]byte("0123456789abcdef0123456789abcdef"))
+	doc := ix.Document("Secret Title", "secret body text")
+
+	for _, field := range []string{doc.Title, doc.Body, doc.Prefixes} {
+		if strings.Contains(field, "secret") || strings.Contains(field, "body") {
+			t.Errorf("index entry contains plaintext: %q", field)
+		}
+	}
+
+	// The same word hashes the same in title and body
+	if strings.Fields(doc.Title)[0] != strings.Fields(doc.Body)[0] {
+		t.Errorf("title token %q != body token %q", strings.Fields(doc.Title)[0], strings.Fields(doc.Body)[0])
+	}
+
+	// "se".."secret", "ti".."title", "bo".."body", "te".."text", shared prefixes once
+	if n := len(strings.Fields(doc.Prefixes)); n != 5+4+3+3 {
+		t.Errorf("got %d prefixes, want 15", n)
+	}
+
+	other := NewIndexer([]byte("fedcba9876543210fedcba9876543210")).Document("Secret Title", "")
+	if other.Title == doc.Title {
+		t.Error("different keys produced the same tokens")
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	ix := NewIndexer([]byte("0123456789abcdef0123456789abcdef"))
+	word := func(w string) string { return `{title body} : "` + ix.blind("t", w) + `"` }
+	prefix := func(p string) string { return `prefixes : "` + ix.blind("p", p) + `"` }
+
+	tests := []struct {
+		in       string
+		expr     string
+		words    []string
+		prefixes []string
+	}{
+		{in: "Hello", expr: word("hello"), words: []string{"hello"}},
+		{in: "a b", expr: word("a") + " " + word("b"), words: []string{"a", "b"}},
+		{
+			in:    `"big cat"`,
+			expr:  `{title body} : "` + ix.blind("t", "big") + " " + ix.blind("t", "cat") + `"`,
+			words: []string{"big", "cat"},
+		},
+		{in: "pre*", expr: prefix("pre"), prefixes: []string{"pre"}},
+		{in: "a NOT b", expr: word("a") + " NOT " + word("b"), words: []string{"a"}},
+		{
+			in:    "(a OR b) AND c",
+			expr:  "( " + word("a") + " OR " + word("b") + " ) AND " + word("c"),
+			words: []string{"a", "b", "c"},
+		},
+		{
+			in:       "e-ma*",
+			expr:     `({title body} : "` + ix.blind("t", "e") + `" AND ` + prefix("ma") + ")",
+			words:    []string{"e"},
+			prefixes: []string{"ma"},
+		},
+		{in: "abcdefghijklmnop*", expr: prefix("abcdefghijkl"), prefixes: []string{"abcdefghijkl"}},
+	}
+
+	for _, tt := range tests {
+		q, err := ix.ParseQuery(tt.in)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.in, err)
+			continue
+		}
+		if q.Expr != tt.expr {
+			t.Errorf("ParseQuery(%q).Expr = %q, want %q", tt.in, q.Expr, tt.expr)
+		}
+		if !reflect.DeepEqual(q.Words, tt.words) || !reflect.DeepEqual(q.Prefixes, tt.prefixes) {
+			t.Errorf("ParseQuery(%q) words %q prefixes %q, want %q %q", tt.in, q.Words, q.Prefixes, tt.words, tt.prefixes)
+		}
+	}
+}
+
+func TestParseQueryInvalid(t *testing.T) {
+	ix := NewIndexer([]byte("0123456789abcdef0123456789abcdef"))
+
+	for _, in := range []string{
+		"",
+		"   ",
+		"!!!",
+		`"unterminated`,
+		"a*",
+		"NOT a",
+		"a AND",
+		"a OR OR b",
+		"AND a",
+		"()",
+		"(a",
+		"a)",
+		"(a OR) b",
+		"a AND -",
+	} {
+		if _, err := ix.ParseQuery(in); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("ParseQuery(%q) error = %v, want ErrInvalidQuery", in, err)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	q := &Query{Words: []string{"cat"}, Prefixes: []string{"dog"}}
+
+	tests := []struct {
+		name, text string
+		radius     int
+		want       string
+	}{
+		{"highlights words and prefixes", "The Cat and the doggo", 50, "The <mark>Cat</mark> and the <mark>doggo</mark>"},
+		{"escapes html", "<b>cat</b>", 50, "&lt;b&gt;<mark>cat</mark>&lt;/b&gt;"},
+		{"cuts around the first match", "one two three four cat five six seven eight", 6, "… four <mark>cat</mark> five …"},
+		{"no match keeps the start", "nothing to see here at all", 5, "nothing to…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Snippet(tt.text, q, tt.radius); got != tt.want {
+				t.Errorf("Snippet = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	highlightOpen  = "<mark>"
+	highlightClose = "</mark>"
+	ellipsis       = "…"
+)
+
+// span is the rune range [start, end) of a word inside a text.
+type span struct {
+	start, end int
+}
+
+// Snippet returns an HTML-escaped excerpt of text around the first word that
+// matches the query, with every matching word wrapped in <mark> tags.
+// radius is the approximate number of characters kept on each side.
+// If nothing matches (e.g. only the title matched) the start of the text is used.
+func Snippet(text string, q *Query, radius int) string {
+	runes := []rune(text)
+	words := wordSpans(runes)
+
+	var matches []span
+	for _, w := range words {
+		if q.matches(lowerRunes(runes[w.start:w.end])) {
+			matches = append(matches, w)
+		}
+	}
+
+	from, to := 0, len(runes)
+	if len(matches) > 0 {
+		from = matches[0].start - radius
+		to = matches[0].end + radius
+	} else {
+		to = 2 * radius
+	}
+	from = max(from, 0)
+	to = min(to, len(runes))
+
+	// Don't cut words in half at the edges of the window
+	for from > 0 && isWordRune(runes[from-1]) && isWordRune(runes[from]) {
+		from--
+	}
+	for to < len(runes) && to > 0 && isWordRune(runes[to-1]) && isWordRune(runes[to]) {
+		to++
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString(ellipsis)
+	}
+
+	pos := from
+	for _, m := range matches {
+		if m.end <= from || m.start >= to {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:m.start])))
+		b.WriteString(highlightOpen)
+		b.WriteString(html.EscapeString(string(runes[m.start:m.end])))
+		b.WriteString(highlightClose)
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:to])))
+
+	if to < len(runes) {
+		b.WriteString(ellipsis)
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// matches reports whether a lowercase word is one of the query's search terms.
+func (q *Query) matches(word string) bool {
+	for _, w := range q.Words {
+		if word == w {
+			return true
+		}
+	}
+	for _, p := range q.Prefixes {
+		if strings.HasPrefix(word, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// wordSpans finds the words of a text the same way Tokenize does.
+func wordSpans(runes []rune) []span {
+	var spans []span
+	start := -1
+
+	for i, r := range runes {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(runes)})
+	}
+
+	return spans
+}
+
+func lowerRunes(runes []rune) string {
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	return string(lower)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,408 @@
+package repository
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/config"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/pkg/encryption"
+)
+
+// ErrQuotaExceeded is returned by Create when the upload would take the user
+// over their attachment quota.
+var ErrQuotaExceeded = errors.New("attachment quota exceeded")
+
+// blobMu serializes writing and collecting blob files, so a blob that is
+// being collected can't be reused by an upload at the same time.
+var blobMu sync.Mutex
+
+// AttachmentRepository stores note attachments. Metadata lives in the
+// attachments table, the content in encrypted, content-addressed files
+// under DATA_DIR/attachments (see attachment_blobs).
+type AttachmentRepository struct {
+	DB        *sql.DB
+	AppConfig *config.Config
+}
+
+func NewAttachmentRepository(db *sql.DB, cfg *config.Config) *AttachmentRepository {
+	return &AttachmentRepository{DB: db, AppConfig: cfg}
+}
+
+// Create stores data as an attachment of a note. An identical file stored
+// before is reused, but every attachment counts towards the quota of its
+// owner. A quota of 0 means unlimited.
+func (r *AttachmentRepository) Create(userID, noteID int64, filename, mimeType string, data []byte, quota int64) (*model.Attachment, error) {
+	hash := r.hash(data)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	blobMu.Lock()
+	defer blobMu.Unlock()
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if quota > 0 {
+		used, err := storageUsed(tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if used+int64(len(data)) > quota {
+			return nil, ErrQuotaExceeded
+		}
+	}
+
+	res, err := tx.Exec(`
+		INSERT OR IGNORE INTO attachment_blobs (hash, size, created_at, key_id)
+		VALUES (?, ?, ?, ?)
+	`, hash, len(data), now, r.AppConfig.Keyring.ActiveID())
+	if err != nil {
+		return nil, err
+	}
+
+	// Also rewrite the file of a known blob if it went missing
+	committed := false
+	if added, _ := res.RowsAffected(); added > 0 || !fileExists(r.blobPath(hash)) {
+		if err := r.writeBlob(hash, data); err != nil {
+			return nil, err
+		}
+		if added > 0 {
+			defer func() {
+				if !committed {
+					os.Remove(r.blobPath(hash))
+				}
+			}()
+		}
+	}
+
+	res, err = tx.Exec(`
+		INSERT INTO attachments (user_id, note_id, blob_hash, filename, mime_type, size, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, userID, noteID, hash, filename, mimeType, len(data), now)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	committed = true
+
+	return &model.Attachment{
+		ID:        id,
+		NoteID:    noteID,
+		Filename:  filename,
+		MimeType:  mimeType,
+		Size:      int64(len(data)),
+		CreatedAt: now,
+	}, nil
+}
+
+// GetByID returns an attachment of a note the user can read together with
+// its blob hash.
+func (r *AttachmentRepository) GetByID(userID, attachmentID int64) (*model.Attachment, string, error) {
+	return r.get(`a.id = ? AND a.note_id IN (SELECT notes.id FROM notes WHERE `+noteReadable+`)`, attachmentID, userID, userID)
+}
+
+// GetForNote returns an attachment of a note regardless of its owner, for
+// public share links. The caller checks access to the note.
+func (r *AttachmentRepository) GetForNote(noteID, attachmentID int64) (*model.Attachment, string, error) {
+	return r.get(`a.id = ? AND a.note_id = ?`, attachmentID, noteID)
+}
+
+func (r *AttachmentRepository) get(where string, args ...interface{}) (*model.Attachment, string, error) {
+	var a model.Attachment
+	var hash string
+
+	err := r.DB.QueryRow(`
+		SELECT a.id, a.note_id, a.blob_hash, a.filename, a.mime_type, a.size, a.created_at
+		FROM attachments a
+		WHERE `+where, args...).Scan(&a.ID, &a.NoteID, &hash, &a.Filename, &a.MimeType, &a.Size, &a.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &a, hash, nil
+}
+
+// ListForNote returns the attachments of a note, oldest first.
+func (r *AttachmentRepository) ListForNote(noteID int64) ([]model.Attachment, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, note_id, filename, mime_type, size, created_at
+		FROM attachments
+		WHERE note_id = ?
+		ORDER BY id
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []model.Attachment{}
+	for rows.Next() {
+		var a model.Attachment
+		if err := rows.Scan(&a.ID, &a.NoteID, &a.Filename, &a.MimeType, &a.Size, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// Read returns the decrypted content of a blob.
+func (r *AttachmentRepository) Read(hash string) ([]byte, error) {
+	raw, err := os.ReadFile(r.blobPath(hash))
+	if err != nil {
+		return nil, err
+	}
+	return encryption.DecryptBytes(r.AppConfig.Keyring, raw)
+}
+
+// Usage returns the bytes of attachments stored by a user.
+func (r *AttachmentRepository) Usage(userID int64) (int64, error) {
+	return storageUsed(r.DB, userID)
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// storageUsed returns the bytes a user stores in attachments of plain and
+// encrypted notes. Both count towards the same quota.
+func storageUsed(db queryRower, userID int64) (int64, error) {
+	var used int64
+	err := db.QueryRow(`
+		SELECT
+			(SELECT COALESCE(SUM(size), 0) FROM attachments WHERE user_id = ?) +
+			(SELECT COALESCE(SUM(c.size), 0)
+			 FROM encrypted_attachment_chunks c
+			 JOIN encrypted_attachments a ON a.id = c.attachment_id
+			 WHERE a.user_id = ?)
+	`, userID, userID).Scan(&used)
+	return used, err
+}
+
+// Delete removes an attachment of the user. Its blob stays until the next
+// CollectGarbage.
+func (r *AttachmentRepository) Delete(userID, attachmentID int64) error {
+	res, err := r.DB.Exec(`DELETE FROM attachments WHERE id = ? AND user_id = ?`, attachmentID, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// CollectGarbage deletes the blobs no attachment refers to anymore, e.g.
+// after their notes were deleted permanently, and their files.
+func (r *AttachmentRepository) CollectGarbage() error {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+
+	rows, err := r.DB.Query(`
+		DELETE FROM attachment_blobs
+		WHERE NOT EXISTS (SELECT 1 FROM attachments a WHERE a.blob_hash = attachment_blobs.hash)
+		RETURNING hash
+	`)
+	if err != nil {
+		return err
+	}
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			rows.Close()
+			return err
+		}
+		hashes = append(hashes, hash)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if err := os.Remove(r.blobPath(hash)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RotateKeys re-encrypts up to limit blob files after the given row ID
+// whose key_id is not the active key. Blob addresses are keyed with the
+// active key too, so each blob moves to its new address, merging with an
+// identical blob already there. Blobs stored before key IDs were recorded
+// have none; those already on the active key only get it recorded.
+func (r *AttachmentRepository) RotateKeys(after int64, limit int) (RotationBatch, error) {
+	blobs, err := staleRows(r.DB, `
+		SELECT rowid, hash FROM attachment_blobs
+		WHERE rowid > ? AND key_id IS NOT ?
+		ORDER BY rowid LIMIT ?
+	`, after, r.AppConfig.Keyring.ActiveID(), limit)
+	if err != nil {
+		return RotationBatch{}, err
+	}
+
+	var batch RotationBatch
+	for _, b := range blobs {
+		batch.Last = b.id
+
+		ok, err := r.rotateBlob(b.value)
+		if err != nil {
+			batch.Failed = append(batch.Failed, fmt.Errorf("attachment blob %s: %w", b.value, err))
+			continue
+		}
+		if ok {
+			batch.Rotated++
+		}
+	}
+
+	return batch, nil
+}
+
+func (r *AttachmentRepository) rotateBlob(oldHash string) (bool, error) {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+
+	raw, err := os.ReadFile(r.blobPath(oldHash))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	keyID := r.AppConfig.Keyring.ActiveID()
+	if r.AppConfig.Keyring.IsActive(raw) {
+		_, err := r.DB.Exec(`UPDATE attachment_blobs SET key_id = ? WHERE hash = ?`, keyID, oldHash)
+		return false, err
+	}
+
+	data, err := encryption.DecryptBytes(r.AppConfig.Keyring, raw)
+	if err != nil {
+		return false, err
+	}
+
+	hash := r.hash(data)
+	if hash == oldHash {
+		if err := r.writeBlob(hash, data); err != nil {
+			return false, err
+		}
+		_, err := r.DB.Exec(`UPDATE attachment_blobs SET key_id = ? WHERE hash = ?`, keyID, hash)
+		return true, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT OR IGNORE INTO attachment_blobs (hash, size, created_at, key_id)
+		SELECT ?, size, created_at, ? FROM attachment_blobs WHERE hash = ?
+	`, hash, keyID, oldHash)
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE attachments SET blob_hash = ? WHERE blob_hash = ?`, hash, oldHash); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM attachment_blobs WHERE hash = ?`, oldHash); err != nil {
+		return false, err
+	}
+
+	written := false
+	if !fileExists(r.blobPath(hash)) {
+		if err := r.writeBlob(hash, data); err != nil {
+			return false, err
+		}
+		written = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		if written {
+			os.Remove(r.blobPath(hash))
+		}
+		return false, err
+	}
+
+	return true, os.Remove(r.blobPath(oldHash))
+}
+
+// hash addresses a blob by a keyed hash of its plaintext, so the file
+// names don't reveal which known files are stored.
+func (r *AttachmentRepository) hash(data []byte) string {
+	mac := hmac.New(sha256.New, r.AppConfig.Keyring.ActiveKey())
+	mac.Write([]byte("attachment:"))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (r *AttachmentRepository) blobPath(hash string) string {
+	return filepath.Join(r.AppConfig.DataDir, "attachments", hash[:2], hash)
+}
+
+// writeBlob encrypts data into the blob file.
+func (r *AttachmentRepository) writeBlob(hash string, data []byte) error {
+	sealed, err := encryption.EncryptBytes(r.AppConfig.Keyring, data)
+	if err != nil {
+		return err
+	}
+	return writeFile(r.blobPath(hash), sealed)
+}
+
+// writeFile writes data through a temporary file in the same directory, so
+// a crash never leaves a truncated file behind.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+// CollaboratorRepository stores which users a note is shared with. Callers
+// check that the acting user owns the note.
+type CollaboratorRepository struct {
+	DB *sql.DB
+}
+
+func NewCollaboratorRepository(db *sql.DB) *CollaboratorRepository {
+	return &CollaboratorRepository{DB: db}
+}
+
+// Put shares a note with a user, or changes the role of an existing
+// collaborator.
+func (r *CollaboratorRepository) Put(noteID, userID int64, role string, invitedBy int64) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO note_collaborators (note_id, user_id, role, invited_by, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (note_id, user_id) DO UPDATE SET role = excluded.role
+	`, noteID, userID, role, invitedBy, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func (r *CollaboratorRepository) Get(noteID, userID int64) (*model.Collaborator, error) {
+	var c model.Collaborator
+
+	err := r.DB.QueryRow(`
+		SELECT u.id, u.email, COALESCE(u.name, ''), c.role, c.created_at
+		FROM note_collaborators c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.note_id = ? AND c.user_id = ?
+	`, noteID, userID).Scan(&c.UserID, &c.Email, &c.Name, &c.Role, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// List returns the collaborators of a note in the order they were added.
+func (r *CollaboratorRepository) List(noteID int64) ([]model.Collaborator, error) {
+	rows, err := r.DB.Query(`
+		SELECT u.id, u.email, COALESCE(u.name, ''), c.role, c.created_at
+		FROM note_collaborators c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.note_id = ?
+		ORDER BY c.created_at, u.id
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collaborators := []model.Collaborator{}
+	for rows.Next() {
+		var c model.Collaborator
+		if err := rows.Scan(&c.UserID, &c.Email, &c.Name, &c.Role, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		collaborators = append(collaborators, c)
+	}
+
+	return collaborators, rows.Err()
+}
+
+// Delete removes a user's access to a note.
+func (r *CollaboratorRepository) Delete(noteID, userID int64) error {
+	res, err := r.DB.Exec(`DELETE FROM note_collaborators WHERE note_id = ? AND user_id = ?`, noteID, userID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// SharedWith returns the notes other users shared with a user, most
+// recently updated first. Notes in their owner's trash are left out.
+func (r *CollaboratorRepository) SharedWith(userID int64) ([]model.SharedWithMeNote, error) {
+	rows, err := r.DB.Query(`
+		SELECT n.id, n.title, c.role, u.email, COALESCE(u.name, ''), c.created_at, n.updated_at
+		FROM note_collaborators c
+		JOIN notes n ON n.id = c.note_id
+		JOIN users u ON u.id = n.user_id
+		WHERE c.user_id = ? AND n.is_deleted = 0
+		ORDER BY n.updated_at DESC, n.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []model.SharedWithMeNote{}
+	for rows.Next() {
+		var n model.SharedWithMeNote
+		if err := rows.Scan(&n.ID, &n.Title, &n.Role, &n.OwnerEmail, &n.OwnerName, &n.SharedAt, &n.UpdatedAt); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+
+	return notes, rows.Err()
+}
+
+// SharedBy returns the notes of a user that are shared with someone, most
+// recently updated first.
+func (r *CollaboratorRepository) SharedBy(userID int64) ([]model.SharedByMeNote, error) {
+	rows, err := r.DB.Query(`
+		SELECT n.id, n.title, COUNT(1), n.updated_at
+		FROM notes n
+		JOIN note_collaborators c ON c.note_id = n.id
+		WHERE n.user_id = ?
+		GROUP BY n.id
+		ORDER BY n.updated_at DESC, n.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []model.SharedByMeNote{}
+	for rows.Next() {
+		var n model.SharedByMeNote
+		if err := rows.Scan(&n.ID, &n.Title, &n.Collaborators, &n.UpdatedAt); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+
+	return notes, rows.Err()
+}
@@ -0,0 +1,65 @@
+//go:build sqlite_fts5
+
+package repository
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/shamal-iroshan/notora/internal/config"
+	"github.com/shamal-iroshan/notora/internal/db"
+	"github.com/shamal-iroshan/notora/internal/pkg/encryption"
+)
+
+// Repository tests run against a migrated SQLite database in a temporary
+// directory. The schema needs FTS5, so they only build with
+// -tags sqlite_fts5, like the server.
+
+const testKey = "0123456789abcdef0123456789abcdef"
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_fk=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if err := db.Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func newTestConfig(t *testing.T, keys, activeID string) *config.Config {
+	t.Helper()
+
+	keyring, err := encryption.NewKeyring(keys, activeID, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &config.Config{DataDir: t.TempDir(), Keyring: keyring, SearchIndexKey: testKey}
+}
+
+// newTestUser inserts an approved user and returns its ID.
+func newTestUser(t *testing.T, conn *sql.DB, email string) int64 {
+	t.Helper()
+
+	res, err := conn.Exec(`
+		INSERT INTO users (email, password_hash, name, user_salt, status, created_at)
+		VALUES (?, 'x', 'Test', 'salt', 'APPROVED', '2026-01-01T00:00:00Z')
+	`, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
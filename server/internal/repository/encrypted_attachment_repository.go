@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/config"
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+var (
+	ErrUploadComplete   = errors.New("upload already complete")
+	ErrUploadIncomplete = errors.New("not all chunks have been uploaded")
+	ErrChunkOutOfRange  = errors.New("chunk index out of range")
+	ErrNonceReused      = errors.New("nonce already used by another chunk")
+)
+
+// EncryptedAttachmentRepository stores the files of encrypted notes. The
+// server never decrypts them: chunks are written to disk as received.
+type EncryptedAttachmentRepository struct {
+	DB        *sql.DB
+	AppConfig *config.Config
+}
+
+func NewEncryptedAttachmentRepository(db *sql.DB, cfg *config.Config) *EncryptedAttachmentRepository {
+	return &EncryptedAttachmentRepository{DB: db, AppConfig: cfg}
+}
+
+// Create starts an upload for an encrypted note of the user. It returns
+// sql.ErrNoRows when the note doesn't exist or belongs to someone else.
+func (r *EncryptedAttachmentRepository) Create(userID, noteID int64, input model.CreateEncryptedAttachmentInput) (int64, error) {
+	res, err := r.DB.Exec(`
+		INSERT INTO encrypted_attachments
+		(user_id, note_id, metadata_ciphertext, metadata_nonce, file_salt, wrapped_file_key, file_key_nonce,
+		 chunk_count, status, created_at)
+		SELECT ?, id, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?
+		FROM encrypted_notes
+		WHERE id = ? AND user_id = ?
+	`, userID, input.MetadataCiphertext, input.MetadataNonce, input.FileSalt, input.WrappedFileKey, input.FileKeyNonce,
+		input.ChunkCount, model.EncryptedAttachmentPending, time.Now().UTC().Format(time.RFC3339), noteID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	return res.LastInsertId()
+}
+
+// Get returns an attachment of an encrypted note with its chunk list.
+func (r *EncryptedAttachmentRepository) Get(userID, noteID, attachmentID int64) (*model.EncryptedAttachment, error) {
+	rows, err := r.DB.Query(encryptedAttachmentSelect+`
+		WHERE a.id = ? AND a.note_id = ? AND a.user_id = ?
+		GROUP BY a.id
+	`, attachmentID, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, err := scanEncryptedAttachments(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(attachments) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	a := &attachments[0]
+
+	chunks, err := r.DB.Query(`
+		SELECT idx, nonce, size
+		FROM encrypted_attachment_chunks
+		WHERE attachment_id = ?
+		ORDER BY idx
+	`, a.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer chunks.Close()
+
+	a.Chunks = []model.EncryptedChunk{}
+	for chunks.Next() {
+		var c model.EncryptedChunk
+		if err := chunks.Scan(&c.Index, &c.Nonce, &c.Size); err != nil {
+			return nil, err
+		}
+		a.Chunks = append(a.Chunks, c)
+	}
+
+	return a, chunks.Err()
+}
+
+// List returns the attachments of an encrypted note of the user, oldest first.
+func (r *EncryptedAttachmentRepository) List(userID, noteID int64) ([]model.EncryptedAttachment, error) {
+	rows, err := r.DB.Query(encryptedAttachmentSelect+`
+		WHERE a.note_id = ? AND a.user_id = ?
+		GROUP BY a.id
+		ORDER BY a.id
+	`, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEncryptedAttachments(rows)
+}
+
+// PutChunk stores one chunk of a pending upload. Uploading the same index
+// again replaces it, so failed chunks can be retried. A quota of 0 means
+// unlimited.
+func (r *EncryptedAttachmentRepository) PutChunk(userID, noteID, attachmentID int64, index int, nonce string, data []byte, quota int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var chunkCount int
+	var status string
+	err = tx.QueryRow(`
+		SELECT chunk_count, status FROM encrypted_attachments
+		WHERE id = ? AND note_id = ? AND user_id = ?
+	`, attachmentID, noteID, userID).Scan(&chunkCount, &status)
+	if err != nil {
+		return err
+	}
+
+	if status != model.EncryptedAttachmentPending {
+		return ErrUploadComplete
+	}
+	if index < 0 || index >= chunkCount {
+		return ErrChunkOutOfRange
+	}
+
+	// AES-GCM breaks when a nonce is reused under the same key
+	var reused int
+	err = tx.QueryRow(`
+		SELECT COUNT(1) FROM encrypted_attachment_chunks
+		WHERE attachment_id = ? AND nonce = ? AND idx <> ?
+	`, attachmentID, nonce, index).Scan(&reused)
+	if err != nil {
+		return err
+	}
+	if reused > 0 {
+		return ErrNonceReused
+	}
+
+	if quota > 0 {
+		used, err := storageUsed(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		var replaced int64
+		err = tx.QueryRow(`
+			SELECT COALESCE(SUM(size), 0) FROM encrypted_attachment_chunks
+			WHERE attachment_id = ? AND idx = ?
+		`, attachmentID, index).Scan(&replaced)
+		if err != nil {
+			return err
+		}
+
+		if used-replaced+int64(len(data)) > quota {
+			return ErrQuotaExceeded
+		}
+	}
+
+	if err := writeFile(r.chunkPath(attachmentID, index), data); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO encrypted_attachment_chunks (attachment_id, idx, nonce, size)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (attachment_id, idx) DO UPDATE SET nonce = excluded.nonce, size = excluded.size
+	`, attachmentID, index, nonce, len(data))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Complete marks an upload as complete once every chunk is there. After
+// that the attachment can no longer change.
+func (r *EncryptedAttachmentRepository) Complete(userID, noteID, attachmentID int64) error {
+	res, err := r.DB.Exec(`
+		UPDATE encrypted_attachments SET status = ?
+		WHERE id = ? AND note_id = ? AND user_id = ? AND status = ?
+		  AND chunk_count = (SELECT COUNT(1) FROM encrypted_attachment_chunks WHERE attachment_id = ?)
+	`, model.EncryptedAttachmentComplete, attachmentID, noteID, userID, model.EncryptedAttachmentPending, attachmentID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected > 0 {
+		return nil
+	}
+
+	// Tell apart why nothing was updated
+	a, err := r.Get(userID, noteID, attachmentID)
+	if err != nil {
+		return err
+	}
+	if a.Status == model.EncryptedAttachmentComplete {
+		return ErrUploadComplete
+	}
+	return ErrUploadIncomplete
+}
+
+// Chunk returns one stored chunk with its nonce.
+func (r *EncryptedAttachmentRepository) Chunk(userID, noteID, attachmentID int64, index int) (*model.EncryptedChunk, []byte, error) {
+	c := model.EncryptedChunk{Index: index}
+
+	err := r.DB.QueryRow(`
+		SELECT c.nonce, c.size
+		FROM encrypted_attachment_chunks c
+		JOIN encrypted_attachments a ON a.id = c.attachment_id
+		WHERE c.attachment_id = ? AND c.idx = ? AND a.note_id = ? AND a.user_id = ?
+	`, attachmentID, index, noteID, userID).Scan(&c.Nonce, &c.Size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := os.ReadFile(r.chunkPath(attachmentID, index))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &c, data, nil
+}
+
+// Delete removes an attachment and its chunk files.
+func (r *EncryptedAttachmentRepository) Delete(userID, noteID, attachmentID int64) error {
+	res, err := r.DB.Exec(`
+		DELETE FROM encrypted_attachments
+		WHERE id = ? AND note_id = ? AND user_id = ?
+	`, attachmentID, noteID, userID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return removeEncryptedAttachmentFiles(r.AppConfig, attachmentID)
+}
+
+func (r *EncryptedAttachmentRepository) chunkPath(attachmentID int64, index int) string {
+	return filepath.Join(encryptedAttachmentDir(r.AppConfig, attachmentID), strconv.Itoa(index))
+}
+
+// encryptedAttachmentSelect selects attachments with the ciphertext size
+// uploaded so far. Callers add WHERE and GROUP BY a.id.
+const encryptedAttachmentSelect = `
+	SELECT a.id, a.note_id, a.metadata_ciphertext, a.metadata_nonce, a.file_salt,
+	       COALESCE(a.wrapped_file_key, ''), COALESCE(a.file_key_nonce, ''),
+	       a.chunk_count, COALESCE(SUM(c.size), 0), a.status, a.created_at
+	FROM encrypted_attachments a
+	LEFT JOIN encrypted_attachment_chunks c ON c.attachment_id = a.id`
+
+func scanEncryptedAttachments(rows *sql.Rows) ([]model.EncryptedAttachment, error) {
+	defer rows.Close()
+
+	attachments := []model.EncryptedAttachment{}
+	for rows.Next() {
+		var a model.EncryptedAttachment
+		err := rows.Scan(&a.ID, &a.NoteID, &a.MetadataCiphertext, &a.MetadataNonce, &a.FileSalt,
+			&a.WrappedFileKey, &a.FileKeyNonce, &a.ChunkCount, &a.Size, &a.Status, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+func encryptedAttachmentDir(cfg *config.Config, attachmentID int64) string {
+	return filepath.Join(cfg.DataDir, "encrypted-attachments", strconv.FormatInt(attachmentID, 10))
+}
+
+func removeEncryptedAttachmentFiles(cfg *config.Config, attachmentIDs ...int64) error {
+	for _, id := range attachmentIDs {
+		if err := os.RemoveAll(encryptedAttachmentDir(cfg, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+// EncryptedNoteKeyRepository stores the wrapped keys of encrypted notes
+// shared with other users. Callers check that the acting user owns the note.
+type EncryptedNoteKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewEncryptedNoteKeyRepository(db *sql.DB) *EncryptedNoteKeyRepository {
+	return &EncryptedNoteKeyRepository{DB: db}
+}
+
+// Put shares an encrypted note with a user who has a key pair, or replaces
+// their wrapped key and role. The key is recorded against the note's
+// current salt. It returns sql.ErrNoRows when the recipient has no key pair.
+func (r *EncryptedNoteKeyRepository) Put(noteID int64, input model.ShareEncryptedNoteInput) error {
+	res, err := r.DB.Exec(`
+		INSERT INTO encrypted_note_keys
+		(note_id, recipient_id, role, wrapped_key, ephemeral_public_key, wrap_nonce, note_salt, created_at)
+		SELECT n.id, u.id, ?, ?, ?, ?, n.note_salt, ?
+		FROM encrypted_notes n, users u
+		WHERE n.id = ? AND u.id = ? AND u.public_key IS NOT NULL
+		ON CONFLICT (note_id, recipient_id) DO UPDATE SET
+			role = excluded.role,
+			wrapped_key = excluded.wrapped_key,
+			ephemeral_public_key = excluded.ephemeral_public_key,
+			wrap_nonce = excluded.wrap_nonce,
+			note_salt = excluded.note_salt
+	`, input.Role, input.WrappedKey, input.EphemeralPublicKey, input.WrapNonce,
+		time.Now().UTC().Format(time.RFC3339), noteID, input.RecipientID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Get returns the key of a note wrapped for a recipient.
+func (r *EncryptedNoteKeyRepository) Get(noteID, recipientID int64) (*model.WrappedNoteKey, error) {
+	var k model.WrappedNoteKey
+
+	err := r.DB.QueryRow(`
+		SELECT wrapped_key, ephemeral_public_key, wrap_nonce, note_salt, role
+		FROM encrypted_note_keys
+		WHERE note_id = ? AND recipient_id = ?
+	`, noteID, recipientID).Scan(&k.WrappedKey, &k.EphemeralPublicKey, &k.WrapNonce, &k.NoteSalt, &k.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+// List returns the recipients of an encrypted note in the order it was
+// shared with them.
+func (r *EncryptedNoteKeyRepository) List(noteID int64) ([]model.EncryptedNoteRecipient, error) {
+	rows, err := r.DB.Query(`
+		SELECT u.id, u.email, COALESCE(u.name, ''), k.role, k.note_salt <> n.note_salt, k.created_at
+		FROM encrypted_note_keys k
+		JOIN users u ON u.id = k.recipient_id
+		JOIN encrypted_notes n ON n.id = k.note_id
+		WHERE k.note_id = ?
+		ORDER BY k.created_at, u.id
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipients := []model.EncryptedNoteRecipient{}
+	for rows.Next() {
+		var rc model.EncryptedNoteRecipient
+		if err := rows.Scan(&rc.UserID, &rc.Email, &rc.Name, &rc.Role, &rc.Stale, &rc.CreatedAt); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, rc)
+	}
+
+	return recipients, rows.Err()
+}
+
+// Delete removes a recipient's wrapped key and with it their access.
+func (r *EncryptedNoteKeyRepository) Delete(noteID, recipientID int64) error {
+	res, err := r.DB.Exec(`DELETE FROM encrypted_note_keys WHERE note_id = ? AND recipient_id = ?`, noteID, recipientID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// SharedWith returns the encrypted notes shared with a user with their
+// wrapped keys, most recently updated first.
+func (r *EncryptedNoteKeyRepository) SharedWith(userID int64) ([]model.SharedEncryptedNote, error) {
+	rows, err := r.DB.Query(`
+		SELECT n.id, n.title_ciphertext, n.title_nonce, n.note_salt, n.format_version, n.created_at, n.updated_at, u.email,
+		       k.wrapped_key, k.ephemeral_public_key, k.wrap_nonce, k.note_salt, k.role
+		FROM encrypted_note_keys k
+		JOIN encrypted_notes n ON n.id = k.note_id
+		JOIN users u ON u.id = n.user_id
+		WHERE k.recipient_id = ?
+		ORDER BY n.updated_at DESC, n.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []model.SharedEncryptedNote{}
+	for rows.Next() {
+		var n model.SharedEncryptedNote
+		if err := rows.Scan(
+			&n.ID, &n.TitleCiphertext, &n.TitleNonce, &n.NoteSalt, &n.FormatVersion, &n.CreatedAt, &n.UpdatedAt, &n.OwnerEmail,
+			&n.Key.WrappedKey, &n.Key.EphemeralPublicKey, &n.Key.WrapNonce, &n.Key.NoteSalt, &n.Key.Role,
+		); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+
+	return notes, rows.Err()
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+// EncryptedNoteRevisionRepository reads the revisions written by
+// EncryptedNotesRepository.Update. They are ciphertext like the notes.
+type EncryptedNoteRevisionRepository struct {
+	DB *sql.DB
+}
+
+func NewEncryptedNoteRevisionRepository(db *sql.DB) *EncryptedNoteRevisionRepository {
+	return &EncryptedNoteRevisionRepository{DB: db}
+}
+
+// List returns the revisions (without content) of an encrypted note the
+// user can read, newest first.
+func (r *EncryptedNoteRevisionRepository) List(userID, noteID int64) ([]model.EncryptedNoteRevision, error) {
+	rows, err := r.DB.Query(`
+		SELECT rv.id, rv.note_id, rv.revision, rv.version, rv.title_ciphertext, rv.title_nonce, rv.note_salt,
+		       rv.format_version, rv.created_at
+		FROM encrypted_note_revisions rv
+		JOIN encrypted_notes ON encrypted_notes.id = rv.note_id
+		WHERE rv.note_id = ? AND `+encryptedNoteReadable+`
+		ORDER BY rv.revision DESC
+	`, noteID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []model.EncryptedNoteRevision{}
+	for rows.Next() {
+		var rv model.EncryptedNoteRevision
+		if err := rows.Scan(
+			&rv.ID, &rv.NoteID, &rv.Revision, &rv.Version, &rv.TitleCiphertext, &rv.TitleNonce, &rv.NoteSalt,
+			&rv.FormatVersion, &rv.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rv)
+	}
+
+	return revisions, rows.Err()
+}
+
+// Get returns a single revision of an encrypted note the user can read.
+func (r *EncryptedNoteRevisionRepository) Get(userID, noteID, revision int64) (*model.EncryptedNoteRevision, error) {
+	var rv model.EncryptedNoteRevision
+
+	err := r.DB.QueryRow(`
+		SELECT rv.id, rv.note_id, rv.revision, rv.version, rv.title_ciphertext, rv.content_ciphertext,
+		       rv.title_nonce, rv.content_nonce, rv.note_salt, rv.format_version, rv.created_at
+		FROM encrypted_note_revisions rv
+		JOIN encrypted_notes ON encrypted_notes.id = rv.note_id
+		WHERE rv.note_id = ? AND rv.revision = ? AND `+encryptedNoteReadable+`
+	`, noteID, revision, userID, userID).Scan(
+		&rv.ID, &rv.NoteID, &rv.Revision, &rv.Version, &rv.TitleCiphertext, &rv.ContentCiphertext,
+		&rv.TitleNonce, &rv.ContentNonce, &rv.NoteSalt, &rv.FormatVersion, &rv.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rv, nil
+}
@@ -2,44 +2,95 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
 	"time"
 
+	"github.com/shamal-iroshan/notora/internal/config"
 	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/pkg/pagination"
+)
+
+// encryptedNoteReadable restricts a query on encrypted_notes to notes the
+// user owns or that were shared with them. It takes the user ID twice.
+const encryptedNoteReadable = `(encrypted_notes.user_id = ? OR encrypted_notes.id IN (
+			SELECT note_id FROM encrypted_note_keys WHERE recipient_id = ?
+		))`
+
+// encryptedNoteEditable restricts an update to notes the user owns, or was
+// made an editor of. Editors must keep the note's salt: the keys wrapped for
+// the other recipients are only valid for it. It takes the user ID, the
+// new salt and the user ID again.
+const encryptedNoteEditable = `(user_id = ? OR (note_salt = ? AND id IN (
+			SELECT note_id FROM encrypted_note_keys WHERE recipient_id = ? AND role = 'editor'
+		)))`
+
+var (
+	ErrRekeyIncomplete = errors.New("notes or attachments do not match the user's encrypted notes and attachments")
+	ErrNoteSaltReused  = errors.New("note_salt must change when re-encrypting")
+	ErrNoteNonceReused = errors.New("nonce already used for this note")
 )
 
 type EncryptedNotesRepository struct {
-	DB *sql.DB
+	DB        *sql.DB
+	AppConfig *config.Config
 }
 
-func NewEncryptedNotesRepository(db *sql.DB) *EncryptedNotesRepository {
-	return &EncryptedNotesRepository{DB: db}
+func NewEncryptedNotesRepository(db *sql.DB, cfg *config.Config) *EncryptedNotesRepository {
+	return &EncryptedNotesRepository{DB: db, AppConfig: cfg}
 }
 
-// Create a new encrypted note
-func (r *EncryptedNotesRepository) Create(userID int64, title, content, tnonce, cnonce, salt string) (int64, error) {
+// Create a new encrypted note and record its nonces
+func (r *EncryptedNotesRepository) Create(userID int64, input model.CreateEncryptedNoteInput) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	res, err := r.DB.Exec(`
+	res, err := tx.Exec(`
         INSERT INTO encrypted_notes 
-        (user_id, title_ciphertext, content_ciphertext, title_nonce, content_nonce, note_salt, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-    `, userID, title, content, tnonce, cnonce, salt, now, now)
+        (user_id, title_ciphertext, content_ciphertext, title_nonce, content_nonce, note_salt, format_version, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, userID, input.TitleCiphertext, input.ContentCiphertext, input.TitleNonce, input.ContentNonce,
+		input.NoteSalt, input.FormatVersion, now, now)
+	if err != nil {
+		return 0, err
+	}
 
+	id, err := res.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
 
-	return res.LastInsertId()
+	if err := recordNonces(tx, id, input.TitleNonce, input.ContentNonce); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
 }
 
-// List encrypted notes metadata (includes encrypted title)
-func (r *EncryptedNotesRepository) List(userID int64) ([]model.EncryptedNoteMetadata, error) {
+// List encrypted notes metadata (includes encrypted title), one page at a time.
+// Only SortUpdated and SortCreated apply, titles are ciphertext.
+func (r *EncryptedNotesRepository) List(userID int64, sort pagination.Sort, after *pagination.Cursor, limit int) ([]model.EncryptedNoteMetadata, error) {
+	where := "WHERE user_id = ?"
+	args := []interface{}{userID}
+
+	if after != nil {
+		cond, condArgs := sort.After(after)
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+
 	rows, err := r.DB.Query(`
-        SELECT id, title_ciphertext, title_nonce, note_salt, created_at, updated_at
+        SELECT id, title_ciphertext, title_nonce, note_salt, format_version, created_at, updated_at
         FROM encrypted_notes
-        WHERE user_id = ?
-        ORDER BY updated_at DESC
-    `, userID)
+        `+where+`
+        ORDER BY `+sort.OrderBy()+`
+        LIMIT ?
+    `, append(args, limit)...)
 
 	if err != nil {
 		return nil, err
@@ -49,13 +100,53 @@ func (r *EncryptedNotesRepository) List(userID int64) ([]model.EncryptedNoteMeta
 	notes := []model.EncryptedNoteMetadata{}
 	for rows.Next() {
 		var n model.EncryptedNoteMetadata
-		if err := rows.Scan(&n.ID, &n.TitleCiphertext, &n.TitleNonce, &n.NoteSalt, &n.CreatedAt, &n.UpdatedAt); err != nil {
+		if err := rows.Scan(&n.ID, &n.TitleCiphertext, &n.TitleNonce, &n.NoteSalt, &n.FormatVersion, &n.CreatedAt, &n.UpdatedAt); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+
+	return notes, rows.Err()
+}
+
+// Page returns full encrypted notes (including content ciphertext) in the
+// given order, starting after the cursor. Used for exports.
+func (r *EncryptedNotesRepository) Page(userID int64, sort pagination.Sort, after *pagination.Cursor, limit int) ([]model.EncryptedNoteResponse, error) {
+	where := "WHERE user_id = ?"
+	args := []interface{}{userID}
+
+	if after != nil {
+		cond, condArgs := sort.After(after)
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+
+	rows, err := r.DB.Query(`
+        SELECT id, title_ciphertext, content_ciphertext, title_nonce, content_nonce, note_salt, format_version, version, created_at, updated_at
+        FROM encrypted_notes
+        `+where+`
+        ORDER BY `+sort.OrderBy()+`
+        LIMIT ?
+    `, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []model.EncryptedNoteResponse{}
+	for rows.Next() {
+		var n model.EncryptedNoteResponse
+		if err := rows.Scan(
+			&n.ID, &n.TitleCiphertext, &n.ContentCiphertext,
+			&n.TitleNonce, &n.ContentNonce, &n.NoteSalt, &n.FormatVersion,
+			&n.Version, &n.CreatedAt, &n.UpdatedAt,
+		); err != nil {
 			return nil, err
 		}
 		notes = append(notes, n)
 	}
 
-	return notes, nil
+	return notes, rows.Err()
 }
 
 // Get full encrypted note
@@ -63,13 +154,13 @@ func (r *EncryptedNotesRepository) GetByID(userID, noteID int64) (*model.Encrypt
 	var n model.EncryptedNoteResponse
 
 	err := r.DB.QueryRow(`
-        SELECT id, title_ciphertext, content_ciphertext, title_nonce, content_nonce, note_salt, created_at, updated_at
+        SELECT id, title_ciphertext, content_ciphertext, title_nonce, content_nonce, note_salt, format_version, version, created_at, updated_at
         FROM encrypted_notes
-        WHERE id = ? AND user_id = ?
-    `, noteID, userID).Scan(
+        WHERE id = ? AND `+encryptedNoteReadable+`
+    `, noteID, userID, userID).Scan(
 		&n.ID, &n.TitleCiphertext, &n.ContentCiphertext,
-		&n.TitleNonce, &n.ContentNonce, &n.NoteSalt,
-		&n.CreatedAt, &n.UpdatedAt,
+		&n.TitleNonce, &n.ContentNonce, &n.NoteSalt, &n.FormatVersion,
+		&n.Version, &n.CreatedAt, &n.UpdatedAt,
 	)
 
 	if err != nil {
@@ -79,24 +170,380 @@ func (r *EncryptedNotesRepository) GetByID(userID, noteID int64) (*model.Encrypt
 	return &n, nil
 }
 
-// Update encrypted note
-func (r *EncryptedNotesRepository) Update(userID, noteID int64, title, content, tnonce, cnonce, salt string) error {
+// Update replaces the ciphertexts and returns the new version. The tuple
+// being replaced is kept as a revision. Editors of a shared note may update
+// it without changing its salt. With ifMatch the update only happens if the
+// current version is one of those versions, otherwise ErrVersionMismatch is
+// returned. A missing note is sql.ErrNoRows, a nonce the note already used
+// is ErrNoteNonceReused.
+func (r *EncryptedNotesRepository) Update(userID, noteID int64, input model.UpdateEncryptedNoteInput) (int64, error) {
+	return r.update(userID, noteID, input, true)
+}
+
+// Restore is Update for a tuple copied from one of the note's revisions.
+// Its nonces were used before, with the same key and plaintext, so the
+// nonce check is skipped.
+func (r *EncryptedNotesRepository) Restore(userID, noteID int64, input model.UpdateEncryptedNoteInput) (int64, error) {
+	return r.update(userID, noteID, input, false)
+}
+
+func (r *EncryptedNotesRepository) update(userID, noteID int64, input model.UpdateEncryptedNoteInput, checkNonces bool) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var version int64
+	err = tx.QueryRow(`
+		SELECT version FROM encrypted_notes WHERE id = ? AND `+encryptedNoteEditable,
+		noteID, userID, input.NoteSalt, userID).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	if !versionMatches(version, input.IfMatch) {
+		return 0, ErrVersionMismatch
+	}
+
+	if checkNonces {
+		used, err := usedNonces(tx, noteID, input.TitleNonce, input.ContentNonce)
+		if err != nil {
+			return 0, err
+		}
+		if len(used) > 0 {
+			return 0, ErrNoteNonceReused
+		}
+	}
+
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	_, err := r.DB.Exec(`
+	if err := r.snapshot(tx, noteID, now); err != nil {
+		return 0, err
+	}
+
+	// Access was checked by the select above
+	_, err = tx.Exec(`
         UPDATE encrypted_notes
-        SET title_ciphertext = ?, content_ciphertext = ?, title_nonce = ?, content_nonce = ?, 
-            note_salt = ?, updated_at = ?
-        WHERE id = ? AND user_id = ?
-    `, title, content, tnonce, cnonce, salt, now, noteID, userID)
+        SET title_ciphertext = ?, content_ciphertext = ?, title_nonce = ?, content_nonce = ?,
+            note_salt = ?, format_version = ?, version = version + 1, updated_at = ?
+        WHERE id = ?
+    `, input.TitleCiphertext, input.ContentCiphertext, input.TitleNonce, input.ContentNonce,
+		input.NoteSalt, input.FormatVersion, now, noteID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := recordNonces(tx, noteID, input.TitleNonce, input.ContentNonce); err != nil {
+		return 0, err
+	}
+
+	return version + 1, tx.Commit()
+}
+
+// Rekey replaces the user_salt, the ciphertexts of all of a user's
+// encrypted notes and the wrapped file keys of all their encrypted
+// attachments in one transaction, after a master password change.
+// input.Notes and input.Attachments must list every note and attachment
+// the user owns exactly once, otherwise ErrRekeyIncomplete is returned;
+// each note needs a new salt (ErrNoteSaltReused), and a note whose version
+// doesn't match gives ErrVersionMismatch. The recovery key is replaced, or
+// removed when none is given; the KDF parameters, key check and private
+// key are replaced when given. Nothing is written unless everything is.
+func (r *EncryptedNotesRepository) Rekey(userID int64, input model.RekeyEncryptedNotesInput) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	type current struct {
+		salt    string
+		version int64
+	}
+	notes := map[int64]current{}
+
+	rows, err := tx.Query(`SELECT id, note_salt, version FROM encrypted_notes WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int64
+		var c current
+		if err := rows.Scan(&id, &c.salt, &c.version); err != nil {
+			rows.Close()
+			return err
+		}
+		notes[id] = c
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(input.Notes) != len(notes) {
+		return ErrRekeyIncomplete
+	}
+
+	for _, n := range input.Notes {
+		c, ok := notes[n.ID]
+		switch {
+		case !ok:
+			return ErrRekeyIncomplete
+		case n.Version != nil && *n.Version != c.version:
+			return ErrVersionMismatch
+		case n.NoteSalt == c.salt:
+			return ErrNoteSaltReused
+		}
+	}
+
+	attachments, err := userAttachmentIDs(tx, userID)
+	if err != nil {
+		return err
+	}
+	if len(input.Attachments) != len(attachments) {
+		return ErrRekeyIncomplete
+	}
+	for _, a := range input.Attachments {
+		if !attachments[a.ID] {
+			return ErrRekeyIncomplete
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	for _, a := range input.Attachments {
+		_, err = tx.Exec(`
+			UPDATE encrypted_attachments SET file_salt = ?, wrapped_file_key = ?, file_key_nonce = ?
+			WHERE id = ?
+		`, a.FileSalt, a.WrappedFileKey, a.FileKeyNonce, a.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, n := range input.Notes {
+		if err := r.snapshot(tx, n.ID, now); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE encrypted_notes
+			SET title_ciphertext = ?, content_ciphertext = ?, title_nonce = ?, content_nonce = ?,
+			    note_salt = ?, format_version = ?, version = version + 1, updated_at = ?
+			WHERE id = ?
+		`, n.TitleCiphertext, n.ContentCiphertext, n.TitleNonce, n.ContentNonce, n.NoteSalt, n.FormatVersion, now, n.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := recordNonces(tx, n.ID, n.TitleNonce, n.ContentNonce); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET user_salt = ? WHERE id = ?`, input.UserSalt, userID); err != nil {
+		return err
+	}
+
+	if kdf := input.KDF; kdf != nil {
+		_, err = tx.Exec(`
+			UPDATE users SET kdf_algorithm = ?, kdf_iterations = ?, kdf_memory_kib = ?, kdf_parallelism = ?
+			WHERE id = ?
+		`, kdf.Algorithm, kdf.Iterations, kdf.MemoryKiB, kdf.Parallelism, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if kc := input.KeyCheck; kc != nil {
+		_, err = tx.Exec(`
+			UPDATE users SET key_check_ciphertext = ?, key_check_nonce = ?, key_check_updated_at = ?
+			WHERE id = ?
+		`, kc.Ciphertext, kc.Nonce, now, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if input.PrivateKeyCiphertext != "" {
+		_, err = tx.Exec(`
+			UPDATE users
+			SET private_key_ciphertext = ?, private_key_nonce = ?, keys_updated_at = ?
+			WHERE id = ? AND public_key IS NOT NULL
+		`, input.PrivateKeyCiphertext, input.PrivateKeyNonce, now, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rk := input.RecoveryKey; rk != nil {
+		_, err = tx.Exec(`
+			UPDATE users
+			SET recovery_key_ciphertext = ?, recovery_key_nonce = ?, recovery_key_updated_at = ?
+			WHERE id = ?
+		`, rk.WrappedMasterKey, rk.Nonce, now, userID)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE users
+			SET recovery_key_ciphertext = NULL, recovery_key_nonce = NULL, recovery_key_updated_at = NULL
+			WHERE id = ?
+		`, userID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Tokens would release the new recovery key
+	if _, err := tx.Exec(`DELETE FROM recovery_tokens WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// userAttachmentIDs returns the IDs of the user's encrypted attachments.
+func userAttachmentIDs(q querier, userID int64) (map[int64]bool, error) {
+	rows, err := q.Query(`SELECT id FROM encrypted_attachments WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+
+	return ids, rows.Err()
+}
+
+// snapshot keeps the current ciphertext tuple of a note as its next
+// revision and drops revisions beyond ENCRYPTED_REVISIONS_KEPT.
+func (r *EncryptedNotesRepository) snapshot(tx *sql.Tx, noteID int64, now string) error {
+	_, err := tx.Exec(`
+		INSERT INTO encrypted_note_revisions
+		(note_id, revision, version, title_ciphertext, content_ciphertext, title_nonce, content_nonce, note_salt, format_version, created_at)
+		SELECT id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM encrypted_note_revisions WHERE note_id = ?),
+		       version, title_ciphertext, content_ciphertext, title_nonce, content_nonce, note_salt, format_version, ?
+		FROM encrypted_notes
+		WHERE id = ?
+	`, noteID, now, noteID)
+	if err != nil {
+		return err
+	}
+
+	if kept := r.AppConfig.EncryptedRevisionsKept; kept > 0 {
+		_, err = tx.Exec(`
+			DELETE FROM encrypted_note_revisions
+			WHERE note_id = ? AND revision <= (SELECT MAX(revision) FROM encrypted_note_revisions WHERE note_id = ?) - ?
+		`, noteID, noteID, kept)
+	}
 
 	return err
 }
 
-// Delete encrypted note
+// UsedNonces returns which of the given nonces the note already used: ones
+// recorded in encrypted_note_nonces, plus the nonces of the current tuple
+// and the kept revisions, which covers notes stored before nonces were
+// recorded.
+func (r *EncryptedNotesRepository) UsedNonces(noteID int64, nonces ...string) ([]string, error) {
+	return usedNonces(r.DB, noteID, nonces...)
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func usedNonces(q querier, noteID int64, nonces ...string) ([]string, error) {
+	if len(nonces) == 0 {
+		return nil, nil
+	}
+
+	args := []interface{}{noteID, noteID, noteID, noteID, noteID}
+	for _, n := range nonces {
+		args = append(args, n)
+	}
+
+	rows, err := q.Query(`
+		WITH used(nonce) AS (
+			SELECT nonce FROM encrypted_note_nonces WHERE note_id = ?
+			UNION SELECT title_nonce FROM encrypted_notes WHERE id = ?
+			UNION SELECT content_nonce FROM encrypted_notes WHERE id = ?
+			UNION SELECT title_nonce FROM encrypted_note_revisions WHERE note_id = ?
+			UNION SELECT content_nonce FROM encrypted_note_revisions WHERE note_id = ?
+		)
+		SELECT nonce FROM used WHERE nonce IN (?`+strings.Repeat(", ?", len(nonces)-1)+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var used []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		used = append(used, n)
+	}
+
+	return used, rows.Err()
+}
+
+// recordNonces remembers nonces stored for a note. Nonces it already has
+// are ignored; callers that must not reuse one check with usedNonces first.
+func recordNonces(tx *sql.Tx, noteID int64, nonces ...string) error {
+	for _, n := range nonces {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO encrypted_note_nonces (note_id, nonce) VALUES (?, ?)`, noteID, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes an encrypted note together with its attachments. Their rows
+// go through the foreign keys, the chunk files are removed afterwards.
 func (r *EncryptedNotesRepository) Delete(userID, noteID int64) error {
-	_, err := r.DB.Exec(`DELETE FROM encrypted_notes WHERE id = ? AND user_id = ?`, noteID, userID)
-	return err
+	rows, err := r.DB.Query(`
+		SELECT id FROM encrypted_attachments WHERE note_id = ? AND user_id = ?
+	`, noteID, userID)
+	if err != nil {
+		return err
+	}
+
+	var attachmentIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		attachmentIDs = append(attachmentIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := r.DB.Exec(`DELETE FROM encrypted_notes WHERE id = ? AND user_id = ?`, noteID, userID); err != nil {
+		return err
+	}
+
+	return removeEncryptedAttachmentFiles(r.AppConfig, attachmentIDs...)
+}
+
+// IsOwner reports whether the user owns the encrypted note.
+func (r *EncryptedNotesRepository) IsOwner(userID, noteID int64) (bool, error) {
+	var count int
+	err := r.DB.QueryRow(`SELECT COUNT(1) FROM encrypted_notes WHERE id = ? AND user_id = ?`, noteID, userID).Scan(&count)
+	return count > 0, err
 }
 
 // Check if a note is encrypted (for blocking sharing)
@@ -0,0 +1,353 @@
+//go:build sqlite_fts5
+
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+// encryptedState is everything a rekey writes for one user.
+type encryptedState struct {
+	UserSalt    string
+	RecoveryKey sql.NullString
+	Notes       []string
+	Attachments []string
+	Revisions   int
+	Nonces      int
+}
+
+func readEncryptedState(t *testing.T, conn *sql.DB, userID int64) encryptedState {
+	t.Helper()
+
+	var s encryptedState
+	err := conn.QueryRow(`SELECT user_salt, recovery_key_ciphertext FROM users WHERE id = ?`, userID).
+		Scan(&s.UserSalt, &s.RecoveryKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Notes = queryStrings(t, conn, `
+		SELECT id || ':' || title_ciphertext || ':' || content_ciphertext || ':' || note_salt || ':' || version
+		FROM encrypted_notes WHERE user_id = ? ORDER BY id
+	`, userID)
+	s.Attachments = queryStrings(t, conn, `
+		SELECT id || ':' || file_salt || ':' || COALESCE(wrapped_file_key, '') || ':' || COALESCE(file_key_nonce, '')
+		FROM encrypted_attachments WHERE user_id = ? ORDER BY id
+	`, userID)
+
+	err = conn.QueryRow(`SELECT COUNT(*) FROM encrypted_note_revisions`).Scan(&s.Revisions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.QueryRow(`SELECT COUNT(*) FROM encrypted_note_nonces`).Scan(&s.Nonces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func queryStrings(t *testing.T, conn *sql.DB, query string, args ...interface{}) []string {
+	t.Helper()
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+// newEncryptedFixture creates a user with two encrypted notes, an
+// attachment on the first one and a recovery key.
+func newEncryptedFixture(t *testing.T) (*EncryptedNotesRepository, int64, []int64, int64) {
+	t.Helper()
+
+	conn := newTestDB(t)
+	cfg := newTestConfig(t, "", "")
+	userID := newTestUser(t, conn, "a@example.com")
+
+	_, err := conn.Exec(`
+		UPDATE users SET recovery_key_ciphertext = 'recovery', recovery_key_nonce = 'nonce' WHERE id = ?
+	`, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewEncryptedNotesRepository(conn, cfg)
+	var noteIDs []int64
+	for i := 0; i < 2; i++ {
+		id, err := r.Create(userID, model.CreateEncryptedNoteInput{
+			TitleCiphertext:   fmt.Sprintf("title%d", i),
+			ContentCiphertext: fmt.Sprintf("content%d", i),
+			TitleNonce:        fmt.Sprintf("title-nonce%d", i),
+			ContentNonce:      fmt.Sprintf("content-nonce%d", i),
+			NoteSalt:          fmt.Sprintf("salt%d", i),
+			FormatVersion:     1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		noteIDs = append(noteIDs, id)
+	}
+
+	attachments := NewEncryptedAttachmentRepository(conn, cfg)
+	attachmentID, err := attachments.Create(userID, noteIDs[0], model.CreateEncryptedAttachmentInput{
+		MetadataCiphertext: "metadata",
+		MetadataNonce:      "metadata-nonce",
+		FileSalt:           "file-salt",
+		WrappedFileKey:     "wrapped",
+		FileKeyNonce:       "key-nonce",
+		ChunkCount:         1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r, userID, noteIDs, attachmentID
+}
+
+// rekeyInput re-encrypts every note and attachment of the fixture.
+func rekeyInput(noteIDs []int64, attachmentID int64) model.RekeyEncryptedNotesInput {
+	input := model.RekeyEncryptedNotesInput{
+		UserSalt: "new-user-salt",
+		Attachments: []model.RekeyEncryptedAttachment{{
+			ID:             attachmentID,
+			FileSalt:       "new-file-salt",
+			WrappedFileKey: "rewrapped",
+			FileKeyNonce:   "new-key-nonce",
+		}},
+	}
+	for i, id := range noteIDs {
+		input.Notes = append(input.Notes, model.RekeyEncryptedNote{
+			ID:                id,
+			TitleCiphertext:   fmt.Sprintf("new-title%d", i),
+			ContentCiphertext: fmt.Sprintf("new-content%d", i),
+			TitleNonce:        fmt.Sprintf("new-title-nonce%d", i),
+			ContentNonce:      fmt.Sprintf("new-content-nonce%d", i),
+			NoteSalt:          fmt.Sprintf("new-salt%d", i),
+			FormatVersion:     1,
+		})
+	}
+	return input
+}
+
+func TestEncryptedRekey(t *testing.T) {
+	r, userID, noteIDs, attachmentID := newEncryptedFixture(t)
+
+	if err := r.Rekey(userID, rekeyInput(noteIDs, attachmentID)); err != nil {
+		t.Fatal(err)
+	}
+
+	s := readEncryptedState(t, r.DB, userID)
+	want := encryptedState{
+		UserSalt: "new-user-salt",
+		Notes: []string{
+			fmt.Sprintf("%d:new-title0:new-content0:new-salt0:2", noteIDs[0]),
+			fmt.Sprintf("%d:new-title1:new-content1:new-salt1:2", noteIDs[1]),
+		},
+		Attachments: []string{fmt.Sprintf("%d:new-file-salt:rewrapped:new-key-nonce", attachmentID)},
+		Revisions:   2,
+		Nonces:      8,
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("state after rekey = %+v, want %+v", s, want)
+	}
+}
+
+func TestEncryptedRekeyWritesNothingOnError(t *testing.T) {
+	stale := int64(1)
+
+	tests := []struct {
+		name   string
+		change func(input *model.RekeyEncryptedNotesInput, noteIDs []int64)
+		want   error
+	}{
+		{
+			name: "missing note",
+			change: func(input *model.RekeyEncryptedNotesInput, noteIDs []int64) {
+				input.Notes = input.Notes[:1]
+			},
+			want: ErrRekeyIncomplete,
+		},
+		{
+			name: "unknown note",
+			change: func(input *model.RekeyEncryptedNotesInput, noteIDs []int64) {
+				input.Notes[1].ID = noteIDs[1] + 100
+			},
+			want: ErrRekeyIncomplete,
+		},
+		{
+			name: "missing attachment",
+			change: func(input *model.RekeyEncryptedNotesInput, noteIDs []int64) {
+				input.Attachments = nil
+			},
+			want: ErrRekeyIncomplete,
+		},
+		{
+			name: "stale version",
+			change: func(input *model.RekeyEncryptedNotesInput, noteIDs []int64) {
+				input.Notes[1].Version = &stale
+			},
+			want: ErrVersionMismatch,
+		},
+		{
+			name: "reused salt",
+			change: func(input *model.RekeyEncryptedNotesInput, noteIDs []int64) {
+				input.Notes[1].NoteSalt = "salt1"
+			},
+			want: ErrNoteSaltReused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, userID, noteIDs, attachmentID := newEncryptedFixture(t)
+
+			// Make the second note version 2, so version 1 is stale
+			_, err := r.Update(userID, noteIDs[1], model.UpdateEncryptedNoteInput{
+				TitleCiphertext:   "title1b",
+				ContentCiphertext: "content1b",
+				TitleNonce:        "title-nonce1b",
+				ContentNonce:      "content-nonce1b",
+				NoteSalt:          "salt1",
+				FormatVersion:     1,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := readEncryptedState(t, r.DB, userID)
+
+			input := rekeyInput(noteIDs, attachmentID)
+			tt.change(&input, noteIDs)
+			if err := r.Rekey(userID, input); !errors.Is(err, tt.want) {
+				t.Fatalf("Rekey = %v, want %v", err, tt.want)
+			}
+
+			if after := readEncryptedState(t, r.DB, userID); !reflect.DeepEqual(after, before) {
+				t.Errorf("state after failed rekey = %+v, want %+v", after, before)
+			}
+		})
+	}
+}
+
+func TestEncryptedUpdateIfMatch(t *testing.T) {
+	r, userID, noteIDs, _ := newEncryptedFixture(t)
+
+	update := func(nonce string, ifMatch ...int64) (int64, error) {
+		return r.Update(userID, noteIDs[0], model.UpdateEncryptedNoteInput{
+			TitleCiphertext:   "title-" + nonce,
+			ContentCiphertext: "content-" + nonce,
+			TitleNonce:        "title-nonce-" + nonce,
+			ContentNonce:      "content-nonce-" + nonce,
+			NoteSalt:          "salt0",
+			FormatVersion:     1,
+			IfMatch:           ifMatch,
+		})
+	}
+
+	if version, err := update("a", 1); err != nil || version != 2 {
+		t.Fatalf("update at version 1 = %d, %v, want 2", version, err)
+	}
+	before := readEncryptedState(t, r.DB, userID)
+
+	if _, err := update("b", 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("update with a stale version = %v, want ErrVersionMismatch", err)
+	}
+	if after := readEncryptedState(t, r.DB, userID); !reflect.DeepEqual(after, before) {
+		t.Errorf("state after stale update = %+v, want %+v", after, before)
+	}
+
+	if version, err := update("c", 1, 2); err != nil || version != 3 {
+		t.Errorf("update matching one of the versions = %d, %v, want 3", version, err)
+	}
+	if version, err := update("d"); err != nil || version != 4 {
+		t.Errorf("unconditional update = %d, %v, want 4", version, err)
+	}
+	if _, err := update("a"); !errors.Is(err, ErrNoteNonceReused) {
+		t.Errorf("update reusing a nonce = %v, want ErrNoteNonceReused", err)
+	}
+}
+
+func TestSyncLatestAfterConcurrentEdit(t *testing.T) {
+	r, userID, noteIDs, _ := newEncryptedFixture(t)
+	sync := NewSyncRepository(r.DB)
+
+	changes, err := sync.Changes(userID, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor := changes[len(changes)-1].Seq
+
+	// Nothing changed since the cursor: a push wouldn't conflict
+	latest, err := sync.Latest(userID, model.SyncEntityEncryptedNote, noteIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Seq > cursor {
+		t.Fatalf("latest change %+v is after cursor %d without an edit", latest, cursor)
+	}
+
+	// Another device edits the note
+	_, err = r.Update(userID, noteIDs[0], model.UpdateEncryptedNoteInput{
+		TitleCiphertext:   "title",
+		ContentCiphertext: "content",
+		TitleNonce:        "other-title-nonce",
+		ContentNonce:      "other-content-nonce",
+		NoteSalt:          "salt0",
+		FormatVersion:     1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err = sync.Latest(userID, model.SyncEntityEncryptedNote, noteIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Seq <= cursor || latest.Op != model.SyncOpUpsert {
+		t.Errorf("latest change %+v isn't an upsert after cursor %d", latest, cursor)
+	}
+
+	// The other note is untouched
+	latest, err = sync.Latest(userID, model.SyncEntityEncryptedNote, noteIDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Seq > cursor {
+		t.Errorf("untouched note has change %+v after cursor %d", latest, cursor)
+	}
+
+	// Another device deletes the note
+	if err := r.Delete(userID, noteIDs[0]); err != nil {
+		t.Fatal(err)
+	}
+	latest, err = sync.Latest(userID, model.SyncEntityEncryptedNote, noteIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Op != model.SyncOpDelete {
+		t.Errorf("latest change after delete = %+v, want a delete", latest)
+	}
+
+	if _, err := sync.Latest(userID, model.SyncEntityEncryptedNote, noteIDs[1]+100); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Latest of a note that never existed = %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+// FolderRepository provides DB operations for hierarchical folders (notebooks).
+// Every query is scoped by user_id.
+type FolderRepository struct {
+	DB *sql.DB
+}
+
+func NewFolderRepository(db *sql.DB) *FolderRepository {
+	return &FolderRepository{DB: db}
+}
+
+// folderSubtreeCTE selects the IDs of a folder and all of its descendants.
+// Parameters: folder ID, user ID.
+const folderSubtreeCTE = `
+	WITH RECURSIVE subtree(id) AS (
+		SELECT id FROM folders WHERE id = ? AND user_id = ?
+		UNION ALL
+		SELECT f.id FROM folders f JOIN subtree s ON f.parent_id = s.id
+	)`
+
+func (r *FolderRepository) Create(userID int64, parentID *int64, name string) (int64, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	res, err := r.DB.Exec(`
+		INSERT INTO folders (user_id, parent_id, name, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, parentID, name, now, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// List returns every folder of the user. Clients build the tree from parent_id.
+func (r *FolderRepository) List(userID int64) ([]model.Folder, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, parent_id, name, created_at, updated_at
+		FROM folders
+		WHERE user_id = ?
+		ORDER BY name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFolders(rows)
+}
+
+// Children returns the direct subfolders of a folder.
+func (r *FolderRepository) Children(userID, folderID int64) ([]model.Folder, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, parent_id, name, created_at, updated_at
+		FROM folders
+		WHERE user_id = ? AND parent_id = ?
+		ORDER BY name
+	`, userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFolders(rows)
+}
+
+func (r *FolderRepository) GetByID(userID, folderID int64) (*model.Folder, error) {
+	var f model.Folder
+	var parentID sql.NullInt64
+
+	err := r.DB.QueryRow(`
+		SELECT id, parent_id, name, created_at, updated_at
+		FROM folders
+		WHERE id = ? AND user_id = ?
+	`, folderID, userID).Scan(&f.ID, &parentID, &f.Name, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	f.ParentID = nullInt64Ptr(parentID)
+	return &f, nil
+}
+
+func (r *FolderRepository) Rename(userID, folderID int64, name string) error {
+	res, err := r.DB.Exec(`
+		UPDATE folders SET name = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?
+	`, name, time.Now().UTC().Format(time.RFC3339), folderID, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Move changes the parent of a folder. A nil parentID moves it to the top level.
+// The caller is responsible for rejecting moves that would create a cycle.
+func (r *FolderRepository) Move(userID, folderID int64, parentID *int64) error {
+	res, err := r.DB.Exec(`
+		UPDATE folders SET parent_id = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?
+	`, parentID, time.Now().UTC().Format(time.RFC3339), folderID, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// IsInSubtree reports whether candidateID is folderID itself or one of its descendants.
+func (r *FolderRepository) IsInSubtree(userID, folderID, candidateID int64) (bool, error) {
+	var count int
+	err := r.DB.QueryRow(folderSubtreeCTE+`
+		SELECT COUNT(1) FROM subtree WHERE id = ?
+	`, folderID, userID, candidateID).Scan(&count)
+	return count > 0, err
+}
+
+// DeleteCascade removes a folder with all of its subfolders. Notes inside the
+// subtree are moved to the trash (is_deleted = 1) and taken out of the folder,
+// so they can still be restored or purged like any other trashed note.
+func (r *FolderRepository) DeleteCascade(userID, folderID int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	_, err = tx.Exec(folderSubtreeCTE+`
+		UPDATE notes
+		SET is_deleted = 1, deleted_at = COALESCE(deleted_at, ?), folder_id = NULL,
+		    version = version + 1, updated_at = ?
+		WHERE user_id = ? AND folder_id IN (SELECT id FROM subtree)
+	`, folderID, userID, now, now, userID)
+	if err != nil {
+		return err
+	}
+
+	// Subfolders go with the parent through ON DELETE CASCADE
+	res, err := tx.Exec(`DELETE FROM folders WHERE id = ? AND user_id = ?`, folderID, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// DeleteMoveToParent removes a folder and hands its notes and direct
+// subfolders over to the folder's parent (or the top level).
+func (r *FolderRepository) DeleteMoveToParent(userID, folderID int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var parentID sql.NullInt64
+	err = tx.QueryRow(`SELECT parent_id FROM folders WHERE id = ? AND user_id = ?`, folderID, userID).Scan(&parentID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	if _, err := tx.Exec(`
+		UPDATE notes SET folder_id = ?, version = version + 1, updated_at = ?
+		WHERE user_id = ? AND folder_id = ?
+	`, parentID, now, userID, folderID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE folders SET parent_id = ?, updated_at = ?
+		WHERE user_id = ? AND parent_id = ?
+	`, parentID, now, userID, folderID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM folders WHERE id = ? AND user_id = ?`, folderID, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListNotes returns a page of note metadata inside a folder, newest first.
+// With recursive set, notes of all descendant folders are included.
+// It also returns the total number of matching notes.
+func (r *FolderRepository) ListNotes(userID, folderID int64, recursive bool, limit, offset int) ([]model.NoteMetadata, int, error) {
+	var where string
+	var args []interface{}
+
+	if recursive {
+		where = `n.folder_id IN (SELECT id FROM subtree)`
+		args = []interface{}{folderID, userID, userID}
+	} else {
+		where = `n.folder_id = ?`
+		args = []interface{}{folderID, userID, userID, folderID}
+	}
+
+	var total int
+	err := r.DB.QueryRow(folderSubtreeCTE+`
+		SELECT COUNT(1) FROM notes n
+		WHERE n.user_id = ? AND n.is_deleted = 0 AND `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.DB.Query(folderSubtreeCTE+`
+		SELECT n.id, n.title, n.is_pinned, n.is_archived, n.is_deleted, n.folder_id, n.created_at, n.updated_at,
+		       COALESCE((
+		           SELECT GROUP_CONCAT(t.name, ',')
+		           FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+		           WHERE nt.note_id = n.id
+		       ), '')
+		FROM notes n
+		WHERE n.user_id = ? AND n.is_deleted = 0 AND `+where+`
+		ORDER BY n.updated_at DESC, n.id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	notes := []model.NoteMetadata{}
+	for rows.Next() {
+		var (
+			n                         model.NoteMetadata
+			pinned, archived, deleted int
+			folder                    sql.NullInt64
+			tags                      string
+		)
+
+		if err := rows.Scan(&n.ID, &n.Title, &pinned, &archived, &deleted, &folder, &n.CreatedAt, &n.UpdatedAt, &tags); err != nil {
+			return nil, 0, err
+		}
+
+		n.IsPinned = pinned == 1
+		n.IsArchived = archived == 1
+		n.IsDeleted = deleted == 1
+		n.FolderID = nullInt64Ptr(folder)
+		n.Tags = splitTags(tags)
+
+		notes = append(notes, n)
+	}
+
+	return notes, total, rows.Err()
+}
+
+func scanFolders(rows *sql.Rows) ([]model.Folder, error) {
+	folders := []model.Folder{}
+	for rows.Next() {
+		var f model.Folder
+		var parentID sql.NullInt64
+
+		if err := rows.Scan(&f.ID, &parentID, &f.Name, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		f.ParentID = nullInt64Ptr(parentID)
+		folders = append(folders, f)
+	}
+
+	return folders, rows.Err()
+}
@@ -0,0 +1,39 @@
+package repository
+
+// RotationBatch is what one RotateKeys call did. Rows that can't be
+// re-encrypted are skipped and reported in Failed, so one bad row doesn't
+// hold up the rest. Last is the ID to continue after, 0 when no row was
+// left.
+type RotationBatch struct {
+	Rotated int
+	Failed  []error
+	Last    int64
+}
+
+// staleRow is a row still sealed with an old key: its ID and ciphertext,
+// or for attachment blobs the hash that names the file.
+type staleRow struct {
+	id    int64
+	value string
+}
+
+// staleRows reads up to limit rows after the given ID. The query selects
+// the ID and the value, in ID order.
+func staleRows(q querier, query string, args ...interface{}) ([]staleRow, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stale []staleRow
+	for rows.Next() {
+		var s staleRow
+		if err := rows.Scan(&s.id, &s.value); err != nil {
+			return nil, err
+		}
+		stale = append(stale, s)
+	}
+
+	return stale, rows.Err()
+}
@@ -0,0 +1,220 @@
+//go:build sqlite_fts5
+
+package repository
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+type syncChange struct {
+	Seq      int64
+	EntityID int64
+	Op       string
+}
+
+func syncChanges(t *testing.T, r *NoteRepository) []syncChange {
+	t.Helper()
+
+	rows, err := r.DB.Query(`SELECT seq, entity_id, op FROM sync_changes ORDER BY seq`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var changes []syncChange
+	for rows.Next() {
+		var c syncChange
+		if err := rows.Scan(&c.Seq, &c.EntityID, &c.Op); err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return changes
+}
+
+func TestNoteRotateKeys(t *testing.T) {
+	conn := newTestDB(t)
+	userID := newTestUser(t, conn, "a@example.com")
+
+	old := NewNoteRepository(conn, newTestConfig(t, "", ""))
+	var ids []int64
+	for _, content := range []string{"first", "second", "third"} {
+		id, err := old.Create(userID, model.CreateNoteInput{Title: content, Content: content})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := old.Update(ids[0], userID, "first", "first, edited", nil); err != nil {
+		t.Fatal(err)
+	}
+	before := syncChanges(t, old)
+
+	r := NewNoteRepository(conn, newTestConfig(t, "1:abcdefghijklmnopqrstuvwxyz012345", "1"))
+
+	batch, err := r.RotateKeys(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Rotated != 2 || len(batch.Failed) != 0 || batch.Last != ids[1] {
+		t.Fatalf("first batch = %+v, want 2 rotated up to note %d", batch, ids[1])
+	}
+
+	batch, err = r.RotateKeys(batch.Last, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Rotated != 1 || batch.Last != ids[2] {
+		t.Fatalf("second batch = %+v, want 1 rotated up to note %d", batch, ids[2])
+	}
+
+	batch, err = r.RotateKeys(batch.Last, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Rotated != 0 || batch.Last != 0 {
+		t.Fatalf("last batch = %+v, want nothing left", batch)
+	}
+
+	if after := syncChanges(t, r); !reflect.DeepEqual(after, before) {
+		t.Errorf("sync changes after rotation = %v, want %v", after, before)
+	}
+
+	for i, id := range ids {
+		var content string
+		if err := conn.QueryRow(`SELECT content FROM notes WHERE id = ?`, id).Scan(&content); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(content, "v1:1:") {
+			t.Errorf("note %d content %.8q is not sealed with key 1", id, content)
+		}
+
+		note, err := r.GetByID(userID, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantVersion := int64(1)
+		if i == 0 {
+			wantVersion = 2
+		}
+		if note.Version != wantVersion {
+			t.Errorf("note %d version = %d after rotation, want %d", id, note.Version, wantVersion)
+		}
+	}
+
+	// A real edit is still a change
+	if _, err := r.Update(ids[1], userID, "second", "second, edited", nil); err != nil {
+		t.Fatal(err)
+	}
+	if after := syncChanges(t, r); after[len(after)-1].EntityID != ids[1] {
+		t.Errorf("edit after rotation wasn't recorded: %v", after)
+	}
+}
+
+func TestNoteRotateKeysSkipsFailures(t *testing.T) {
+	conn := newTestDB(t)
+	userID := newTestUser(t, conn, "a@example.com")
+
+	old := NewNoteRepository(conn, newTestConfig(t, "", ""))
+	var ids []int64
+	for _, content := range []string{"first", "second"} {
+		id, err := old.Create(userID, model.CreateNoteInput{Title: content, Content: content})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := conn.Exec(`UPDATE notes SET content = 'v1:9:AAAA' WHERE id = ?`, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewNoteRepository(conn, newTestConfig(t, "1:abcdefghijklmnopqrstuvwxyz012345", "1"))
+	batch, err := r.RotateKeys(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Rotated != 1 || len(batch.Failed) != 1 || batch.Last != ids[1] {
+		t.Fatalf("batch = %+v, want note %d rotated and note %d failed", batch, ids[1], ids[0])
+	}
+	if !strings.Contains(batch.Failed[0].Error(), "note 1:") {
+		t.Errorf("failure %q doesn't name the note", batch.Failed[0])
+	}
+}
+
+func TestSearchAfterKeyChange(t *testing.T) {
+	conn := newTestDB(t)
+	userID := newTestUser(t, conn, "a@example.com")
+
+	old := NewNoteRepository(conn, newTestConfig(t, "", ""))
+	if _, err := old.Create(userID, model.CreateNoteInput{Title: "t", Content: "zebra"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is rotated yet, the note is still found
+	r := NewNoteRepository(conn, newTestConfig(t, "1:abcdefghijklmnopqrstuvwxyz012345", "1"))
+	search := func() int {
+		t.Helper()
+		query, err := r.Indexer.ParseQuery("zebra")
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := r.Search(userID, query.Expr, model.SearchNotesInput{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(results)
+	}
+	if n := search(); n != 1 {
+		t.Fatalf("search before rotation found %d notes, want 1", n)
+	}
+
+	if _, err := r.RotateKeys(0, 10); err != nil {
+		t.Fatal(err)
+	}
+	if n := search(); n != 1 {
+		t.Errorf("search after rotation found %d notes, want 1", n)
+	}
+}
+
+func TestIndexMissingRebuildsForNewKey(t *testing.T) {
+	conn := newTestDB(t)
+	userID := newTestUser(t, conn, "a@example.com")
+
+	cfg := newTestConfig(t, "", "")
+	r := NewNoteRepository(conn, cfg)
+	if _, err := r.IndexMissing(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(userID, model.CreateNoteInput{Title: "t", Content: "zebra"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := r.IndexMissing(); err != nil || n != 0 {
+		t.Errorf("IndexMissing with the same key = %d, %v, want 0", n, err)
+	}
+
+	cfg.SearchIndexKey = "another search index secret"
+	r = NewNoteRepository(conn, cfg)
+	if n, err := r.IndexMissing(); err != nil || n != 1 {
+		t.Fatalf("IndexMissing with a new key = %d, %v, want 1", n, err)
+	}
+
+	query, err := r.Indexer.ParseQuery("zebra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := r.Search(userID, query.Expr, model.SearchNotesInput{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("search with the new key found %d notes, want 1", len(results))
+	}
+}
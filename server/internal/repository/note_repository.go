@@ -2,41 +2,116 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/shamal-iroshan/notora/internal/config"
 	"github.com/shamal-iroshan/notora/internal/model"
 	"github.com/shamal-iroshan/notora/internal/pkg/encryption"
+	"github.com/shamal-iroshan/notora/internal/pkg/search"
 )
 
+// ErrVersionMismatch is returned by conditional updates when the row exists
+// but its version is not one of the expected versions.
+var ErrVersionMismatch = errors.New("version mismatch")
+
 type NoteRepository struct {
 	DB        *sql.DB
 	AppConfig *config.Config
+	Indexer   *search.Indexer
 }
 
 func NewNoteRepository(db *sql.DB, cfg *config.Config) *NoteRepository {
-	return &NoteRepository{DB: db, AppConfig: cfg}
+	return &NoteRepository{
+		DB:        db,
+		AppConfig: cfg,
+		Indexer:   search.NewIndexer([]byte(cfg.SearchIndexKey)),
+	}
 }
 
-func (r *NoteRepository) Create(userID int64, title, content string) (int64, error) {
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// noteReadable and noteEditable restrict a query on notes to the notes a
+// user may read or edit: their own, and the ones shared with them through
+// note_collaborators. Shared notes in their owner's trash are left out.
+// Both take the user ID twice. Everything else (flags, folders, tags,
+// deleting, sharing) stays with the owner and filters on user_id.
+const (
+	noteReadable = `(notes.user_id = ? OR (notes.is_deleted = 0 AND notes.id IN (
+			SELECT note_id FROM note_collaborators WHERE user_id = ?
+		)))`
+	noteEditable = `(notes.user_id = ? OR (notes.is_deleted = 0 AND notes.id IN (
+			SELECT note_id FROM note_collaborators WHERE user_id = ? AND role = 'editor'
+		)))`
+)
+
+// Create inserts a note with its flags, folder and tags in one transaction.
+// The caller must make sure the folder belongs to the user and normalize
+// the tag names; missing tags are created.
+func (r *NoteRepository) Create(userID int64, input model.CreateNoteInput) (int64, error) {
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	encContent, err := encryption.EncryptAES([]byte(r.AppConfig.EncryptionKey), content)
+	createdAt, updatedAt := input.CreatedAt, input.UpdatedAt
+	if createdAt == "" {
+		createdAt = now
+	}
+	if updatedAt == "" {
+		updatedAt = createdAt
+	}
+
+	var deletedAt *string
+	if input.IsDeleted {
+		deletedAt = &now
+	}
+
+	encContent, err := encryption.EncryptAES(r.AppConfig.Keyring, input.Content)
 	if err != nil {
 		return 0, err
 	}
 
-	result, err := r.DB.Exec(`
-		INSERT INTO notes (user_id, title, content, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?)
-	`, userID, title, encContent, now, now)
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO notes (
+			user_id, title, content, folder_id,
+			is_pinned, is_archived, is_deleted, deleted_at,
+			created_at, updated_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, input.Title, encContent, input.FolderID,
+		boolToInt(input.IsPinned), boolToInt(input.IsArchived), boolToInt(input.IsDeleted), deletedAt,
+		createdAt, updatedAt,
+	)
 
 	if err != nil {
 		return 0, err
 	}
 
-	return result.LastInsertId()
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.indexNote(tx, id, input.Title, input.Content); err != nil {
+		return 0, err
+	}
+
+	if len(input.Tags) > 0 {
+		if err := setNoteTags(tx, userID, id, input.Tags); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, tx.Commit()
 }
 
 func (r *NoteRepository) GetByID(userID, noteID int64) (*model.Note, error) {
@@ -47,23 +122,26 @@ func (r *NoteRepository) GetByID(userID, noteID int64) (*model.Note, error) {
 		pinned    int
 		archived  int
 		deleted   int
+		folderID  sql.NullInt64
+		version   int64
+		deletedAt sql.NullString
 		createdAt string
 		updatedAt string
 	)
 
 	err := r.DB.QueryRow(`
-		SELECT id, title, content, is_pinned, is_archived, is_deleted, created_at, updated_at
+		SELECT id, title, content, is_pinned, is_archived, is_deleted, folder_id, version, deleted_at, created_at, updated_at
 		FROM notes
-		WHERE user_id = ? AND id = ?
-	`, userID, noteID).Scan(
-		&id, &title, &content, &pinned, &archived, &deleted, &createdAt, &updatedAt,
+		WHERE id = ? AND `+noteReadable+`
+	`, noteID, userID, userID).Scan(
+		&id, &title, &content, &pinned, &archived, &deleted, &folderID, &version, &deletedAt, &createdAt, &updatedAt,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	plaintext, err := encryption.DecryptAES([]byte(r.AppConfig.EncryptionKey), content)
+	plaintext, err := encryption.DecryptAES(r.AppConfig.Keyring, content)
 	if err != nil {
 		return nil, err
 	}
@@ -75,38 +153,183 @@ func (r *NoteRepository) GetByID(userID, noteID int64) (*model.Note, error) {
 		IsPinned:   pinned == 1,
 		IsArchived: archived == 1,
 		IsDeleted:  deleted == 1,
+		FolderID:   nullInt64Ptr(folderID),
+		Version:    version,
+		DeletedAt:  nullStringPtr(deletedAt),
 		CreatedAt:  createdAt,
 		UpdatedAt:  updatedAt,
 	}, nil
 }
 
-func (r *NoteRepository) GetAll(userID int64) (*sql.Rows, error) {
-	return r.DB.Query(`
-		SELECT id, title, content, is_pinned, is_archived, is_deleted, created_at, updated_at
-		FROM notes
-		WHERE user_id = ?
-		ORDER BY updated_at DESC
-	`, userID)
+// noteTagsColumn selects a note's tag names as a comma separated list.
+// Tag names never contain commas (enforced by the tag service).
+const noteTagsColumn = `COALESCE((
+			SELECT GROUP_CONCAT(t.name, ',')
+			FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+			WHERE nt.note_id = notes.id
+		), '')`
+
+// noteTagFilter restricts a notes query to notes carrying the given tag name.
+const noteTagFilter = ` AND id IN (
+			SELECT nt.note_id
+			FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+			WHERE t.user_id = ? AND t.name = ?
+		)`
+
+// listFilter builds the WHERE clause shared by GetAll and GetMetadata:
+// user scope, optional flag and tag filters, and the page cursor.
+func listFilter(userID int64, input model.ListNotesInput) (string, []interface{}) {
+	where := " WHERE user_id = ?"
+	args := []interface{}{userID}
+
+	if input.Archived != nil {
+		where += " AND is_archived = ?"
+		args = append(args, boolToInt(*input.Archived))
+	}
+	if input.Trashed != nil {
+		where += " AND is_deleted = ?"
+		args = append(args, boolToInt(*input.Trashed))
+	}
+	if input.Pinned != nil {
+		where += " AND is_pinned = ?"
+		args = append(args, boolToInt(*input.Pinned))
+	}
+
+	if input.Tag != "" {
+		where += noteTagFilter
+		args = append(args, userID, input.Tag)
+	}
+
+	if input.After != nil {
+		cond, condArgs := input.Sort.After(input.After)
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+
+	return where, args
 }
 
-func (r *NoteRepository) Update(noteID, userID int64, title, content string) error {
+// GetAll returns one page of a user's notes with decrypted content.
+// It fetches up to input.Limit rows; callers ask for one extra row to
+// find out whether another page exists.
+func (r *NoteRepository) GetAll(userID int64, input model.ListNotesInput) ([]model.Note, error) {
+	where, args := listFilter(userID, input)
+
+	rows, err := r.DB.Query(`
+		SELECT id, title, content, is_pinned, is_archived, is_deleted, folder_id, version, deleted_at, created_at, updated_at, `+noteTagsColumn+`
+		FROM notes`+where+`
+		ORDER BY `+input.Sort.OrderBy()+`
+		LIMIT ?`, append(args, input.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []model.Note{}
+	for rows.Next() {
+		var (
+			n                         model.Note
+			encContent, tags          string
+			pinned, archived, deleted int
+			folderID                  sql.NullInt64
+			deletedAt                 sql.NullString
+		)
+
+		if err := rows.Scan(
+			&n.ID, &n.Title, &encContent, &pinned, &archived, &deleted,
+			&folderID, &n.Version, &deletedAt, &n.CreatedAt, &n.UpdatedAt, &tags,
+		); err != nil {
+			return nil, err
+		}
+
+		n.Content, err = encryption.DecryptAES(r.AppConfig.Keyring, encContent)
+		if err != nil {
+			return nil, err
+		}
+
+		n.IsPinned = pinned == 1
+		n.IsArchived = archived == 1
+		n.IsDeleted = deleted == 1
+		n.FolderID = nullInt64Ptr(folderID)
+		n.DeletedAt = nullStringPtr(deletedAt)
+		n.Tags = splitTags(tags)
+
+		notes = append(notes, n)
+	}
+
+	return notes, rows.Err()
+}
+
+// Update replaces title and content and returns the new version. Editors
+// of a shared note may update it too. Unless tags is nil, the note's tags
+// are replaced in the same transaction; only pass them for the owner.
+// When ifMatch is given, the update only happens if the current version
+// is one of those versions, otherwise ErrVersionMismatch is returned.
+func (r *NoteRepository) Update(noteID, userID int64, title, content string, tags []string, ifMatch ...int64) (int64, error) {
 	// Encrypt content
-	encContent, err := encryption.EncryptAES([]byte(r.AppConfig.EncryptionKey), content)
+	encContent, err := encryption.EncryptAES(r.AppConfig.Keyring, content)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Snapshot the current (still encrypted) title/content before overwriting it
+	var oldTitle, oldContent string
+	var version int64
+	err = tx.QueryRow(`
+		SELECT title, content, version
+		FROM notes
+		WHERE id = ? AND `+noteEditable+`
+	`, noteID, userID, userID).Scan(&oldTitle, &oldContent, &version)
+	if err != nil {
+		return 0, err
+	}
+
+	if !versionMatches(version, ifMatch) {
+		return 0, ErrVersionMismatch
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	_, err = tx.Exec(`
+		INSERT INTO note_revisions (note_id, revision, title, content, created_at)
+		VALUES (?, (SELECT COALESCE(MAX(revision), 0) + 1 FROM note_revisions WHERE note_id = ?), ?, ?, ?)
+	`, noteID, noteID, oldTitle, oldContent, now)
+	if err != nil {
+		return 0, err
 	}
 
-	// Execute update query
-	_, err = r.DB.Exec(`
+	// Execute update query (access was checked by the snapshot query)
+	_, err = tx.Exec(`
         UPDATE notes
-        SET title = ?, content = ?, updated_at = ?
-        WHERE id = ? AND user_id = ?
-    `, title, encContent, time.Now().UTC().Format(time.RFC3339), noteID, userID)
+        SET title = ?, content = ?, version = version + 1, updated_at = ?
+        WHERE id = ?
+    `, title, encContent, now, noteID)
+	if err != nil {
+		return 0, err
+	}
 
-	return err
+	if err := r.indexNote(tx, noteID, title, content); err != nil {
+		return 0, err
+	}
+
+	if tags != nil {
+		if err := setNoteTags(tx, userID, noteID, tags); err != nil {
+			return 0, err
+		}
+	}
+
+	return version + 1, tx.Commit()
 }
 
-func (r *NoteRepository) UpdateFlags(noteID, userID int64, pinned, archived, deleted *bool) error {
+// UpdateFlags changes the given flags and returns the new version.
+// ifMatch works like in Update.
+func (r *NoteRepository) UpdateFlags(noteID, userID int64, pinned, archived, deleted *bool, ifMatch ...int64) (int64, error) {
 	query := `UPDATE notes SET `
 	args := []interface{}{}
 
@@ -120,102 +343,452 @@ func (r *NoteRepository) UpdateFlags(noteID, userID int64, pinned, archived, del
 		args = append(args, boolToInt(*archived))
 	}
 
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	// deleted_at keeps the time the note first went to the trash
 	if deleted != nil {
 		query += "is_deleted = ?, "
 		args = append(args, boolToInt(*deleted))
+
+		if *deleted {
+			query += "deleted_at = COALESCE(deleted_at, ?), "
+			args = append(args, now)
+		} else {
+			query += "deleted_at = NULL, "
+		}
 	}
 
-	query += "updated_at = ? WHERE id = ? AND user_id = ?"
-	args = append(args, time.Now().UTC().Format(time.RFC3339), noteID, userID)
+	query += "version = version + 1, updated_at = ? WHERE id = ? AND user_id = ?"
+	args = append(args, now, noteID, userID)
 
-	_, err := r.DB.Exec(query, args...)
-	return err
+	filter, filterArgs := versionFilter(ifMatch)
+	query += filter + " RETURNING version"
+	args = append(args, filterArgs...)
+
+	var version int64
+	err := r.DB.QueryRow(query, args...).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) && len(ifMatch) > 0 {
+		return 0, r.missingOrStale(userID, noteID)
+	}
+
+	return version, err
+}
+
+// MoveToFolder places a note in a folder. A nil folderID moves it to the top level.
+// The caller must make sure the folder belongs to the same user.
+func (r *NoteRepository) MoveToFolder(userID, noteID int64, folderID *int64) error {
+	res, err := r.DB.Exec(`
+		UPDATE notes
+		SET folder_id = ?, version = version + 1, updated_at = ?
+		WHERE id = ? AND user_id = ?
+	`, folderID, time.Now().UTC().Format(time.RFC3339), noteID, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
 }
 
 func (r *NoteRepository) DeletePermanently(noteID, userID int64) error {
-	_, err := r.DB.Exec(`DELETE FROM notes WHERE id = ? AND user_id = ?`, noteID, userID)
+	_, err := r.purge(`id = ? AND user_id = ?`, noteID, userID)
 	return err
 }
 
+// EmptyTrash permanently deletes all trashed notes of a user.
+func (r *NoteRepository) EmptyTrash(userID int64) (int64, error) {
+	return r.purge(`user_id = ? AND is_deleted = 1`, userID)
+}
+
+// PurgeExpiredTrash permanently deletes the trashed notes of all users that
+// were moved to the trash at or before the given RFC 3339 time.
+func (r *NoteRepository) PurgeExpiredTrash(before string) (int64, error) {
+	return r.purge(`is_deleted = 1 AND deleted_at <= ?`, before)
+}
+
+// RestoreFromTrash takes trashed notes out of the trash. An empty noteIDs
+// restores the whole trash. It returns the number of restored notes.
+func (r *NoteRepository) RestoreFromTrash(userID int64, noteIDs []int64) (int64, error) {
+	query := `
+		UPDATE notes
+		SET is_deleted = 0, deleted_at = NULL, version = version + 1, updated_at = ?
+		WHERE user_id = ? AND is_deleted = 1`
+	args := []interface{}{time.Now().UTC().Format(time.RFC3339), userID}
+
+	if len(noteIDs) > 0 {
+		query += ` AND id IN (?` + strings.Repeat(", ?", len(noteIDs)-1) + `)`
+		for _, id := range noteIDs {
+			args = append(args, id)
+		}
+	}
+
+	res, err := r.DB.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// purge hard-deletes the notes matching a WHERE condition. Share links of
+// those notes are removed first (shared_notes has no ON DELETE CASCADE);
+// revisions, tags and attachments go with the note through their foreign
+// keys. Attachment blobs are collected by the caller.
+func (r *NoteRepository) purge(where string, args ...interface{}) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM shared_notes WHERE note_id IN (SELECT id FROM notes WHERE `+where+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`DELETE FROM notes WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, tx.Commit()
+}
+
+// Duplicate copies a note into the user's notes. A copy of a note shared
+// with the user starts at the top level, as the folder is the owner's.
 func (r *NoteRepository) Duplicate(userID, noteID int64) (int64, error) {
 	var title, content string
+	var folderID sql.NullInt64
 	err := r.DB.QueryRow(`
-		SELECT title, content
+		SELECT title, content, CASE WHEN user_id = ? THEN folder_id END
 		FROM notes
-		WHERE id = ? AND user_id = ?
-	`, noteID, userID).Scan(&title, &content)
+		WHERE id = ? AND `+noteReadable+`
+	`, userID, noteID, userID, userID).Scan(&title, &content, &folderID)
 
 	if err != nil {
 		return 0, err
 	}
 
+	plaintext, err := encryption.DecryptAES(r.AppConfig.Keyring, content)
+	if err != nil {
+		return 0, err
+	}
+
 	now := time.Now().UTC().Format(time.RFC3339)
+	title += " (Copy)"
 
-	result, err := r.DB.Exec(`
-		INSERT INTO notes (user_id, title, content, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?)
-	`, userID, title+" (Copy)", content, now, now)
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO notes (user_id, title, content, folder_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, title, content, folderID, now, now)
 
 	if err != nil {
 		return 0, err
 	}
 
-	return result.LastInsertId()
-}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
 
-func (r *NoteRepository) GetMetadata(userID int64) (*sql.Rows, error) {
-	return r.DB.Query(`
-		SELECT id, title, updated_at, is_pinned, is_archived, is_deleted
-		FROM notes
-		WHERE user_id = ?
-		ORDER BY updated_at DESC
-	`, userID)
+	if err := r.indexNote(tx, id, title, plaintext); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
 }
 
-func (r *NoteRepository) Search(userID int64, query string) ([]model.Note, error) {
-	query = "%" + query + "%" // wildcard search
+// GetMetadata returns one page of note metadata (no content, nothing to decrypt).
+func (r *NoteRepository) GetMetadata(userID int64, input model.ListNotesInput) ([]model.NoteMetadata, error) {
+	where, args := listFilter(userID, input)
 
 	rows, err := r.DB.Query(`
-		SELECT id, title, content, is_pinned, is_archived, is_deleted, created_at, updated_at
-		FROM notes
-		WHERE user_id = ?
-		  AND is_deleted = 0
-		  AND (title LIKE ? OR content LIKE ?)
-		ORDER BY updated_at DESC
-	`, userID, query, query)
+		SELECT id, title, is_pinned, is_archived, is_deleted, folder_id, deleted_at, created_at, updated_at, `+noteTagsColumn+`
+		FROM notes`+where+`
+		ORDER BY `+input.Sort.OrderBy()+`
+		LIMIT ?`, append(args, input.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []model.NoteMetadata{}
+	for rows.Next() {
+		var (
+			n                         model.NoteMetadata
+			tags                      string
+			pinned, archived, deleted int
+			folderID                  sql.NullInt64
+			deletedAt                 sql.NullString
+		)
 
+		if err := rows.Scan(
+			&n.ID, &n.Title, &pinned, &archived, &deleted,
+			&folderID, &deletedAt, &n.CreatedAt, &n.UpdatedAt, &tags,
+		); err != nil {
+			return nil, err
+		}
+
+		n.IsPinned = pinned == 1
+		n.IsArchived = archived == 1
+		n.IsDeleted = deleted == 1
+		n.FolderID = nullInt64Ptr(folderID)
+		n.DeletedAt = nullStringPtr(deletedAt)
+		n.Tags = splitTags(tags)
+
+		notes = append(notes, n)
+	}
+
+	return notes, rows.Err()
+}
+
+// Search runs an FTS5 MATCH expression (built by search.Indexer.ParseQuery)
+// against the user's notes and returns the best BM25 matches first.
+// Trashed notes are never returned. Title matches weigh more than body matches.
+func (r *NoteRepository) Search(userID int64, matchExpr string, input model.SearchNotesInput) ([]model.NoteSearchResult, error) {
+	query := `
+		SELECT notes.id, notes.title, notes.content, notes.is_pinned, notes.is_archived,
+		       notes.folder_id, notes.updated_at, ` + noteTagsColumn + `,
+		       bm25(notes_fts, 10.0, 1.0, 0.5) AS score
+		FROM notes_fts
+		JOIN notes ON notes.id = notes_fts.rowid
+		WHERE notes_fts MATCH ?
+		  AND notes.user_id = ?
+		  AND notes.is_deleted = 0`
+	args := []interface{}{matchExpr, userID}
+
+	if input.Archived != nil {
+		query += " AND notes.is_archived = ?"
+		args = append(args, boolToInt(*input.Archived))
+	}
+
+	if input.Pinned != nil {
+		query += " AND notes.is_pinned = ?"
+		args = append(args, boolToInt(*input.Pinned))
+	}
+
+	if input.Tag != "" {
+		query += noteTagFilter
+		args = append(args, userID, input.Tag)
+	}
+
+	query += " ORDER BY score LIMIT ?"
+	args = append(args, input.Limit)
+
+	rows, err := r.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var results []model.Note
+	results := []model.NoteSearchResult{}
 
 	for rows.Next() {
-		var n model.Note
-		var pinned, archived, deleted int
+		var (
+			n                model.NoteSearchResult
+			encContent, tags string
+			pinned, archived int
+			folderID         sql.NullInt64
+		)
 
 		if err := rows.Scan(
-			&n.ID,
-			&n.Title,
-			&n.Content,
-			&pinned,
-			&archived,
-			&deleted,
-			&n.CreatedAt,
-			&n.UpdatedAt,
+			&n.ID, &n.Title, &encContent, &pinned, &archived,
+			&folderID, &n.UpdatedAt, &tags, &n.Score,
 		); err != nil {
 			return nil, err
 		}
 
+		n.Content, err = encryption.DecryptAES(r.AppConfig.Keyring, encContent)
+		if err != nil {
+			return nil, err
+		}
+
+		// bm25() is "lower is better"; flip it so clients can sort descending
+		n.Score = -n.Score
 		n.IsPinned = pinned == 1
 		n.IsArchived = archived == 1
-		n.IsDeleted = deleted == 1
+		n.FolderID = nullInt64Ptr(folderID)
+		n.Tags = splitTags(tags)
 
 		results = append(results, n)
 	}
 
-	return results, nil
+	return results, rows.Err()
+}
+
+// IndexMissing adds every note that is not yet in the full-text index.
+// Called at startup so notes written before the index existed become
+// searchable. An index built with another key is emptied first, so every
+// note is indexed again. Notes that can't be indexed are skipped and
+// returned in the error.
+func (r *NoteRepository) IndexMissing() (int, error) {
+	if err := r.resetIndexForKey(); err != nil {
+		return 0, err
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT id, title, content
+		FROM notes
+		WHERE id NOT IN (SELECT rowid FROM notes_fts)
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	type pending struct {
+		id             int64
+		title, content string
+	}
+	var notes []pending
+
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.title, &p.content); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		notes = append(notes, p)
+	}
+	rows.Close()
+
+	indexed := 0
+	var errs []error
+	for _, p := range notes {
+		plaintext, err := encryption.DecryptAES(r.AppConfig.Keyring, p.content)
+		if err == nil {
+			err = r.indexNote(r.DB, p.id, p.title, plaintext)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("note %d: %w", p.id, err))
+			continue
+		}
+		indexed++
+	}
+
+	return indexed, errors.Join(errs...)
+}
+
+// resetIndexForKey empties the full-text index unless it was built with the
+// current index key, and records the key. Indexes from before the key was
+// recorded are rebuilt too.
+func (r *NoteRepository) resetIndexForKey() error {
+	keyCheck := r.Indexer.KeyCheck()
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var stored string
+	err = tx.QueryRow(`SELECT key_check FROM search_index_state WHERE id = 1`).Scan(&stored)
+	if err == nil && stored == keyCheck {
+		return nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM notes_fts`); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO search_index_state (id, key_check) VALUES (1, ?)
+		ON CONFLICT (id) DO UPDATE SET key_check = excluded.key_check
+	`, keyCheck)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RotateKeys re-encrypts the content of up to limit notes after the given
+// ID that are not sealed with the active key yet. The search index has its
+// own key and stays as it is. A note changed in the meantime is left
+// alone; the change sealed it with the active key.
+func (r *NoteRepository) RotateKeys(after int64, limit int) (RotationBatch, error) {
+	prefix := r.AppConfig.Keyring.ActivePrefix()
+
+	notes, err := staleRows(r.DB, `
+		SELECT id, content FROM notes
+		WHERE id > ? AND substr(content, 1, ?) != ?
+		ORDER BY id LIMIT ?
+	`, after, len(prefix), prefix, limit)
+	if err != nil {
+		return RotationBatch{}, err
+	}
+
+	var batch RotationBatch
+	for _, n := range notes {
+		batch.Last = n.id
+
+		ok, err := r.rotateNote(n.id, n.value)
+		if err != nil {
+			batch.Failed = append(batch.Failed, fmt.Errorf("note %d: %w", n.id, err))
+			continue
+		}
+		if ok {
+			batch.Rotated++
+		}
+	}
+
+	return batch, nil
+}
+
+func (r *NoteRepository) rotateNote(noteID int64, oldContent string) (bool, error) {
+	plaintext, err := encryption.DecryptAES(r.AppConfig.Keyring, oldContent)
+	if err != nil {
+		return false, err
+	}
+
+	encContent, err := encryption.EncryptAES(r.AppConfig.Keyring, plaintext)
+	if err != nil {
+		return false, err
+	}
+
+	// The plaintext and version stay the same, so no revision. Only content
+	// is set, which notes_sync_update ignores, so clients don't re-pull.
+	res, err := r.DB.Exec(`
+		UPDATE notes SET content = ? WHERE id = ? AND content = ?
+	`, encContent, noteID, oldContent)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// indexNote (re)writes the blinded full-text entry of a note.
+// Deletes are handled by the notes_fts_delete trigger.
+func (r *NoteRepository) indexNote(db execer, noteID int64, title, content string) error {
+	doc := r.Indexer.Document(title, content)
+
+	if _, err := db.Exec(`DELETE FROM notes_fts WHERE rowid = ?`, noteID); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO notes_fts (rowid, title, body, prefixes)
+		VALUES (?, ?, ?, ?)
+	`, noteID, doc.Title, doc.Body, doc.Prefixes)
+	return err
 }
 
 // EnsureOwnership verifies that the note belongs to the given user.
@@ -240,13 +813,20 @@ func (r *NoteRepository) EnsureOwnership(userID, noteID int64) error {
 	return nil
 }
 
+// EnsureReadable verifies that the user owns the note or that it was
+// shared with them.
+func (r *NoteRepository) EnsureReadable(userID, noteID int64) error {
+	var id int64
+	return r.DB.QueryRow(`SELECT id FROM notes WHERE id = ? AND `+noteReadable, noteID, userID, userID).Scan(&id)
+}
+
 func (r *NoteRepository) GetPublicNote(noteID int64) (*model.Note, error) {
 	var n model.Note
 	var encContent string
 	var pinned, archived, deleted int
 
 	err := r.DB.QueryRow(`
-		SELECT id, title, content, is_pinned, is_archived, is_deleted, created_at, updated_at
+		SELECT id, title, content, is_pinned, is_archived, is_deleted, version, created_at, updated_at
 		FROM notes
 		WHERE id = ?
 	`, noteID).Scan(
@@ -256,6 +836,7 @@ func (r *NoteRepository) GetPublicNote(noteID int64) (*model.Note, error) {
 		&pinned,
 		&archived,
 		&deleted,
+		&n.Version,
 		&n.CreatedAt,
 		&n.UpdatedAt,
 	)
@@ -264,7 +845,7 @@ func (r *NoteRepository) GetPublicNote(noteID int64) (*model.Note, error) {
 	}
 
 	// Decrypt content
-	plaintext, err := encryption.DecryptAES([]byte(r.AppConfig.EncryptionKey), encContent)
+	plaintext, err := encryption.DecryptAES(r.AppConfig.Keyring, encContent)
 	if err != nil {
 		return nil, err
 	}
@@ -277,6 +858,67 @@ func (r *NoteRepository) GetPublicNote(noteID int64) (*model.Note, error) {
 	return &n, nil
 }
 
+// splitTags turns the comma separated noteTagsColumn value into a list.
+func splitTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+	return strings.Split(tags, ",")
+}
+
+// nullInt64Ptr converts a nullable column into a pointer (nil for NULL).
+func nullInt64Ptr(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
+
+func nullStringPtr(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+	return &v.String
+}
+
+// missingOrStale explains why a conditional update matched no row.
+func (r *NoteRepository) missingOrStale(userID, noteID int64) error {
+	var id int64
+	err := r.DB.QueryRow(`SELECT id FROM notes WHERE id = ? AND user_id = ?`, noteID, userID).Scan(&id)
+	if err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+// versionFilter returns the WHERE condition of a conditional update,
+// or nothing when no versions are expected.
+func versionFilter(ifMatch []int64) (string, []interface{}) {
+	if len(ifMatch) == 0 {
+		return "", nil
+	}
+
+	args := make([]interface{}, len(ifMatch))
+	for i, v := range ifMatch {
+		args[i] = v
+	}
+	return " AND version IN (?" + strings.Repeat(", ?", len(ifMatch)-1) + ")", args
+}
+
+// versionMatches reports whether version is acceptable for ifMatch
+// (always true when ifMatch is empty).
+func versionMatches(version int64, ifMatch []int64) bool {
+	if len(ifMatch) == 0 {
+		return true
+	}
+	for _, v := range ifMatch {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
 func boolToInt(b bool) int {
 	if b {
 		return 1
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/shamal-iroshan/notora/internal/config"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/pkg/encryption"
+)
+
+// NoteRevisionRepository reads the snapshots written by NoteRepository.Update.
+// Snapshots are stored encrypted, exactly like the notes table.
+type NoteRevisionRepository struct {
+	DB        *sql.DB
+	AppConfig *config.Config
+}
+
+func NewNoteRevisionRepository(db *sql.DB, cfg *config.Config) *NoteRevisionRepository {
+	return &NoteRevisionRepository{DB: db, AppConfig: cfg}
+}
+
+// List returns revision metadata (without content) for a note the user can
+// read, newest first.
+func (r *NoteRevisionRepository) List(userID, noteID int64) ([]model.NoteRevision, error) {
+	rows, err := r.DB.Query(`
+		SELECT rv.id, rv.note_id, rv.revision, rv.title, rv.created_at
+		FROM note_revisions rv
+		JOIN notes ON notes.id = rv.note_id
+		WHERE rv.note_id = ? AND `+noteReadable+`
+		ORDER BY rv.revision DESC
+	`, noteID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []model.NoteRevision{}
+	for rows.Next() {
+		var rv model.NoteRevision
+		if err := rows.Scan(&rv.ID, &rv.NoteID, &rv.Revision, &rv.Title, &rv.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rv)
+	}
+
+	return revisions, rows.Err()
+}
+
+// Get returns a single decrypted revision of a note the user can read.
+func (r *NoteRevisionRepository) Get(userID, noteID, revision int64) (*model.NoteRevision, error) {
+	var rv model.NoteRevision
+	var encContent string
+
+	err := r.DB.QueryRow(`
+		SELECT rv.id, rv.note_id, rv.revision, rv.title, rv.content, rv.created_at
+		FROM note_revisions rv
+		JOIN notes ON notes.id = rv.note_id
+		WHERE rv.note_id = ? AND rv.revision = ? AND `+noteReadable+`
+	`, noteID, revision, userID, userID).Scan(
+		&rv.ID, &rv.NoteID, &rv.Revision, &rv.Title, &encContent, &rv.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := encryption.DecryptAES(r.AppConfig.Keyring, encContent)
+	if err != nil {
+		return nil, err
+	}
+	rv.Content = plaintext
+
+	return &rv, nil
+}
+
+// RotateKeys re-encrypts the content of up to limit revisions after the
+// given ID that are not sealed with the active key yet.
+func (r *NoteRevisionRepository) RotateKeys(after int64, limit int) (RotationBatch, error) {
+	prefix := r.AppConfig.Keyring.ActivePrefix()
+
+	revisions, err := staleRows(r.DB, `
+		SELECT id, content FROM note_revisions
+		WHERE id > ? AND substr(content, 1, ?) != ?
+		ORDER BY id LIMIT ?
+	`, after, len(prefix), prefix, limit)
+	if err != nil {
+		return RotationBatch{}, err
+	}
+
+	var batch RotationBatch
+	for _, rv := range revisions {
+		batch.Last = rv.id
+
+		if err := r.rotateRevision(rv.id, rv.value); err != nil {
+			batch.Failed = append(batch.Failed, fmt.Errorf("note revision %d: %w", rv.id, err))
+			continue
+		}
+		batch.Rotated++
+	}
+
+	return batch, nil
+}
+
+func (r *NoteRevisionRepository) rotateRevision(id int64, oldContent string) error {
+	plaintext, err := encryption.DecryptAES(r.AppConfig.Keyring, oldContent)
+	if err != nil {
+		return err
+	}
+
+	encContent, err := encryption.EncryptAES(r.AppConfig.Keyring, plaintext)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.Exec(`UPDATE note_revisions SET content = ? WHERE id = ?`, encContent, id)
+	return err
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+// RecoveryRepository stores the wrapped master key kept for recovery and
+// the one-time tokens that release it.
+type RecoveryRepository struct {
+	DB *sql.DB
+}
+
+func NewRecoveryRepository(db *sql.DB) *RecoveryRepository {
+	return &RecoveryRepository{DB: db}
+}
+
+// Get returns the user's wrapped master key, or sql.ErrNoRows if no
+// recovery key is set.
+func (r *RecoveryRepository) Get(userID int64) (*model.RecoveryKey, error) {
+	var k model.RecoveryKey
+
+	err := r.DB.QueryRow(`
+		SELECT recovery_key_ciphertext, recovery_key_nonce, recovery_key_updated_at
+		FROM users
+		WHERE id = ? AND recovery_key_ciphertext IS NOT NULL
+	`, userID).Scan(&k.WrappedMasterKey, &k.Nonce, &k.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+// Put sets or replaces the user's wrapped master key.
+func (r *RecoveryRepository) Put(userID int64, wrappedMasterKey, nonce string) error {
+	_, err := r.DB.Exec(`
+		UPDATE users
+		SET recovery_key_ciphertext = ?, recovery_key_nonce = ?, recovery_key_updated_at = ?
+		WHERE id = ?
+	`, wrappedMasterKey, nonce, time.Now().UTC().Format(time.RFC3339), userID)
+	return err
+}
+
+// Delete removes the user's recovery key and any unused tokens.
+func (r *RecoveryRepository) Delete(userID int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE users
+		SET recovery_key_ciphertext = NULL, recovery_key_nonce = NULL, recovery_key_updated_at = NULL
+		WHERE id = ? AND recovery_key_ciphertext IS NOT NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`DELETE FROM recovery_tokens WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InsertToken stores the hash of a recovery token.
+func (r *RecoveryRepository) InsertToken(userID int64, tokenHash string, expires time.Time) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO recovery_tokens (user_id, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?)
+	`, userID, tokenHash, expires.UTC().Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// CountTokensSince returns how many tokens the user was sent since the
+// given time, used or not.
+func (r *RecoveryRepository) CountTokensSince(userID int64, since time.Time) (int, error) {
+	var count int
+	err := r.DB.QueryRow(`
+		SELECT COUNT(*) FROM recovery_tokens
+		WHERE user_id = ? AND created_at > ?
+	`, userID, since.UTC().Format(time.RFC3339)).Scan(&count)
+	return count, err
+}
+
+// PurgeTokens deletes the tokens of all users created before the given
+// time and returns how many there were.
+func (r *RecoveryRepository) PurgeTokens(before time.Time) (int64, error) {
+	res, err := r.DB.Exec(`
+		DELETE FROM recovery_tokens WHERE created_at < ?
+	`, before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// UseToken marks an unused, unexpired token of the user as used. It
+// returns sql.ErrNoRows if there is no such token.
+func (r *RecoveryRepository) UseToken(userID int64, tokenHash string) error {
+	res, err := r.DB.Exec(`
+		UPDATE recovery_tokens SET used = 1
+		WHERE user_id = ? AND token_hash = ? AND used = 0 AND expires_at > ?
+	`, userID, tokenHash, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
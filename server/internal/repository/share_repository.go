@@ -3,6 +3,8 @@ package repository
 import (
 	"database/sql"
 	"time"
+
+	"github.com/shamal-iroshan/notora/internal/model"
 )
 
 type ShareRepository struct {
@@ -13,34 +15,139 @@ func NewShareRepository(db *sql.DB) *ShareRepository {
 	return &ShareRepository{DB: db}
 }
 
-func (r *ShareRepository) Create(noteID int64, token string) error {
-	_, err := r.DB.Exec(`
-		INSERT INTO shared_notes (note_id, token, created_at)
-		VALUES (?, ?, ?)
-	`, noteID, token, time.Now().UTC().Format(time.RFC3339))
-	return err
+// shareColumns are scanned by scanShare.
+const shareColumns = `id, note_id, label, token, expires_at, COALESCE(password_hash, ''), max_views, view_count, created_at, last_accessed_at`
+
+// Create stores a share link. expiresAt, passwordHash and maxViews are
+// optional: nil means no limit.
+func (r *ShareRepository) Create(noteID int64, label, token string, expiresAt, passwordHash *string, maxViews *int64) (int64, error) {
+	res, err := r.DB.Exec(`
+		INSERT INTO shared_notes (note_id, label, token, expires_at, password_hash, max_views, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, noteID, label, token, expiresAt, passwordHash, maxViews, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
 }
 
-func (r *ShareRepository) FindByToken(token string) (int64, error) {
-	var noteID int64
-	var disabled int
+// FindByToken returns an enabled share link. Expiry and view limits are
+// checked by the caller.
+func (r *ShareRepository) FindByToken(token string) (*model.Share, error) {
+	return scanShare(r.DB.QueryRow(`
+		SELECT `+shareColumns+`
+		FROM shared_notes
+		WHERE token = ? AND disabled = 0
+	`, token))
+}
 
-	err := r.DB.QueryRow(`
-		SELECT note_id, disabled
+// GetByID returns an enabled share link of a note.
+func (r *ShareRepository) GetByID(noteID, shareID int64) (*model.Share, error) {
+	return scanShare(r.DB.QueryRow(`
+		SELECT `+shareColumns+`
 		FROM shared_notes
-		WHERE token = ?
-	`, token).Scan(&noteID, &disabled)
+		WHERE id = ? AND note_id = ? AND disabled = 0
+	`, shareID, noteID))
+}
 
+// ListForNote returns the enabled share links of a note, newest first.
+func (r *ShareRepository) ListForNote(noteID int64) ([]model.Share, error) {
+	rows, err := r.DB.Query(`
+		SELECT `+shareColumns+`
+		FROM shared_notes
+		WHERE note_id = ? AND disabled = 0
+		ORDER BY id DESC
+	`, noteID)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []model.Share{}
+	for rows.Next() {
+		s, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *s)
+	}
+
+	return shares, rows.Err()
+}
+
+// CountView records one view of a share link and its time. It returns
+// false, without counting, when the link has used up its views.
+func (r *ShareRepository) CountView(shareID int64) (bool, error) {
+	res, err := r.DB.Exec(`
+		UPDATE shared_notes SET view_count = view_count + 1, last_accessed_at = ?
+		WHERE id = ? AND (max_views IS NULL OR view_count < max_views)
+	`, time.Now().UTC().Format(time.RFC3339), shareID)
+	if err != nil {
+		return false, err
+	}
+
+	counted, err := res.RowsAffected()
+	return counted > 0, err
+}
+
+// UpdateOptions changes the label and limits of a share link. A nil
+// argument keeps the current value; a pointer to an empty string or 0
+// removes the limit.
+func (r *ShareRepository) UpdateOptions(noteID, shareID int64, label, expiresAt, passwordHash *string, maxViews *int64) error {
+	res, err := r.DB.Exec(`
+		UPDATE shared_notes
+		SET label         = COALESCE(?, label),
+		    expires_at    = CASE WHEN ? THEN NULLIF(?, '') ELSE expires_at END,
+		    password_hash = CASE WHEN ? THEN NULLIF(?, '') ELSE password_hash END,
+		    max_views     = CASE WHEN ? THEN NULLIF(?, 0) ELSE max_views END
+		WHERE id = ? AND note_id = ? AND disabled = 0
+	`, label, expiresAt != nil, expiresAt, passwordHash != nil, passwordHash, maxViews != nil, maxViews, shareID, noteID)
+	if err != nil {
+		return err
 	}
-	if disabled != 0 {
-		return 0, sql.ErrNoRows
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
 	}
 
-	return noteID, nil
+	return nil
 }
 
+// RotateToken gives a share link a new token. The old URL stops working.
+func (r *ShareRepository) RotateToken(noteID, shareID int64, token string) error {
+	res, err := r.DB.Exec(`
+		UPDATE shared_notes SET token = ?
+		WHERE id = ? AND note_id = ? AND disabled = 0
+	`, token, shareID, noteID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Revoke disables one share link of a note.
+func (r *ShareRepository) Revoke(noteID, shareID int64) error {
+	res, err := r.DB.Exec(`
+		UPDATE shared_notes SET disabled = 1
+		WHERE id = ? AND note_id = ? AND disabled = 0
+	`, shareID, noteID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Disable disables every share link of a note.
 func (r *ShareRepository) Disable(noteID int64) error {
 	_, err := r.DB.Exec(`
 		UPDATE shared_notes
@@ -49,3 +156,27 @@ func (r *ShareRepository) Disable(noteID int64) error {
 	`, noteID)
 	return err
 }
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanShare(row rowScanner) (*model.Share, error) {
+	var s model.Share
+	var expiresAt, lastAccessedAt sql.NullString
+	var maxViews sql.NullInt64
+
+	err := row.Scan(&s.ID, &s.NoteID, &s.Label, &s.Token, &expiresAt, &s.PasswordHash, &maxViews,
+		&s.ViewCount, &s.CreatedAt, &lastAccessedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ExpiresAt = nullStringPtr(expiresAt)
+	s.LastAccessedAt = nullStringPtr(lastAccessedAt)
+	s.MaxViews = nullInt64Ptr(maxViews)
+	s.PasswordProtected = s.PasswordHash != ""
+
+	return &s, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+// SyncRepository reads the sync_changes log. The log itself is written by
+// triggers on notes and encrypted_notes (see db.Migrate).
+type SyncRepository struct {
+	DB *sql.DB
+}
+
+func NewSyncRepository(db *sql.DB) *SyncRepository {
+	return &SyncRepository{DB: db}
+}
+
+// Changes returns up to limit changes of the user with seq > since, oldest first.
+func (r *SyncRepository) Changes(userID, since int64, limit int) ([]model.SyncChange, error) {
+	rows, err := r.DB.Query(`
+		SELECT seq, entity, entity_id, op, COALESCE(created_seq, seq)
+		FROM sync_changes
+		WHERE user_id = ? AND seq > ?
+		ORDER BY seq
+		LIMIT ?
+	`, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []model.SyncChange{}
+	for rows.Next() {
+		var c model.SyncChange
+		if err := rows.Scan(&c.Seq, &c.Entity, &c.EntityID, &c.Op, &c.CreatedSeq); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+// Latest returns the last change of one entity.
+// sql.ErrNoRows means the entity never existed for this user.
+func (r *SyncRepository) Latest(userID int64, entity string, entityID int64) (*model.SyncChange, error) {
+	var c model.SyncChange
+
+	err := r.DB.QueryRow(`
+		SELECT seq, entity, entity_id, op, COALESCE(created_seq, seq)
+		FROM sync_changes
+		WHERE user_id = ? AND entity = ? AND entity_id = ?
+	`, userID, entity, entityID).Scan(&c.Seq, &c.Entity, &c.EntityID, &c.Op, &c.CreatedSeq)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+// TagRepository provides DB operations for user tags and the note_tags join table.
+// Every query is scoped by user_id so a user can never touch another user's tags.
+type TagRepository struct {
+	DB *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) *TagRepository {
+	return &TagRepository{DB: db}
+}
+
+// List returns all tags of a user with the number of notes using each tag.
+func (r *TagRepository) List(userID int64) ([]model.Tag, error) {
+	rows, err := r.DB.Query(`
+		SELECT t.id, t.name, t.created_at, COUNT(nt.note_id)
+		FROM tags t
+		LEFT JOIN note_tags nt ON nt.tag_id = t.id
+		WHERE t.user_id = ?
+		GROUP BY t.id
+		ORDER BY t.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []model.Tag{}
+	for rows.Next() {
+		var t model.Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt, &t.NoteCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+func (r *TagRepository) GetByID(userID, tagID int64) (*model.Tag, error) {
+	var t model.Tag
+
+	err := r.DB.QueryRow(`
+		SELECT t.id, t.name, t.created_at,
+		       (SELECT COUNT(1) FROM note_tags nt WHERE nt.tag_id = t.id)
+		FROM tags t
+		WHERE t.id = ? AND t.user_id = ?
+	`, tagID, userID).Scan(&t.ID, &t.Name, &t.CreatedAt, &t.NoteCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Create inserts a new tag. Fails if the user already has a tag with that name.
+func (r *TagRepository) Create(userID int64, name string) (int64, error) {
+	res, err := r.DB.Exec(`
+		INSERT INTO tags (user_id, name, created_at)
+		VALUES (?, ?, ?)
+	`, userID, name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// FindIDByName returns the ID of the user's tag with the given name
+// (case-insensitive), or sql.ErrNoRows.
+func (r *TagRepository) FindIDByName(userID int64, name string) (int64, error) {
+	var id int64
+	err := r.DB.QueryRow(`SELECT id FROM tags WHERE user_id = ? AND name = ?`, userID, name).Scan(&id)
+	return id, err
+}
+
+// Rename changes a tag's name. If the user already has another tag with the
+// new name, the tag is merged into it instead so every note ends up with the
+// existing tag. Returns the ID of the surviving tag.
+func (r *TagRepository) Rename(userID, tagID int64, name string) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := ensureTagOwner(tx, userID, tagID); err != nil {
+		return 0, err
+	}
+
+	var existingID int64
+	err = tx.QueryRow(`SELECT id FROM tags WHERE user_id = ? AND name = ? AND id != ?`, userID, name, tagID).Scan(&existingID)
+
+	switch {
+	case err == sql.ErrNoRows:
+		if _, err := tx.Exec(`UPDATE tags SET name = ? WHERE id = ? AND user_id = ?`, name, tagID, userID); err != nil {
+			return 0, err
+		}
+		return tagID, tx.Commit()
+	case err != nil:
+		return 0, err
+	}
+
+	if err := mergeTags(tx, []int64{tagID}, existingID); err != nil {
+		return 0, err
+	}
+
+	return existingID, tx.Commit()
+}
+
+// Merge moves every note from the source tags onto the target tag and deletes
+// the source tags, all inside a single transaction.
+func (r *TagRepository) Merge(userID int64, sourceIDs []int64, targetID int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := ensureTagOwner(tx, userID, targetID); err != nil {
+		return err
+	}
+	for _, id := range sourceIDs {
+		if err := ensureTagOwner(tx, userID, id); err != nil {
+			return err
+		}
+	}
+
+	if err := mergeTags(tx, sourceIDs, targetID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a tag. Join rows are removed by ON DELETE CASCADE,
+// the notes themselves are untouched.
+func (r *TagRepository) Delete(userID, tagID int64) error {
+	res, err := r.DB.Exec(`DELETE FROM tags WHERE id = ? AND user_id = ?`, tagID, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// SetForNote replaces the tags of a note with the given names.
+// Missing tags are created on the fly.
+func (r *TagRepository) SetForNote(userID, noteID int64, names []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setNoteTags(tx, userID, noteID, names); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setNoteTags is SetForNote inside the caller's transaction.
+func setNoteTags(tx *sql.Tx, userID, noteID int64, names []string) error {
+	if _, err := tx.Exec(`DELETE FROM note_tags WHERE note_id = ?`, noteID); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	for _, name := range names {
+		_, err := tx.Exec(`
+			INSERT INTO tags (user_id, name, created_at)
+			VALUES (?, ?, ?)
+			ON CONFLICT (user_id, name) DO NOTHING
+		`, userID, name, now)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT OR IGNORE INTO note_tags (note_id, tag_id)
+			SELECT ?, id FROM tags WHERE user_id = ? AND name = ?
+		`, noteID, userID, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForNote returns the tag names attached to a note, sorted by name.
+func (r *TagRepository) ForNote(noteID int64) ([]string, error) {
+	rows, err := r.DB.Query(`
+		SELECT t.name
+		FROM note_tags nt
+		JOIN tags t ON t.id = nt.tag_id
+		WHERE nt.note_id = ?
+		ORDER BY t.name
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+func ensureTagOwner(tx *sql.Tx, userID, tagID int64) error {
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(1) FROM tags WHERE id = ? AND user_id = ?`, tagID, userID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func mergeTags(tx *sql.Tx, sourceIDs []int64, targetID int64) error {
+	for _, sourceID := range sourceIDs {
+		if sourceID == targetID {
+			continue
+		}
+
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO note_tags (note_id, tag_id)
+			SELECT note_id, ? FROM note_tags WHERE tag_id = ?
+		`, targetID, sourceID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM tags WHERE id = ?`, sourceID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+// UserKeyRepository stores the key material of encrypted notes kept on
+// users: the key pairs notes are shared with, and the key check.
+type UserKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewUserKeyRepository(db *sql.DB) *UserKeyRepository {
+	return &UserKeyRepository{DB: db}
+}
+
+// Get returns the key pair of a user, or sql.ErrNoRows if none is set.
+func (r *UserKeyRepository) Get(userID int64) (*model.UserKeys, error) {
+	var k model.UserKeys
+
+	err := r.DB.QueryRow(`
+		SELECT public_key, private_key_ciphertext, private_key_nonce, keys_updated_at
+		FROM users
+		WHERE id = ? AND public_key IS NOT NULL
+	`, userID).Scan(&k.PublicKey, &k.PrivateKeyCiphertext, &k.PrivateKeyNonce, &k.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+// Put sets the key pair of a user. Note keys wrapped for a previous public
+// key can't be opened with the new private key, so they are removed; the
+// owners have to share those notes again. Re-encrypting the same private
+// key, e.g. after a master password change, keeps them.
+func (r *UserKeyRepository) Put(userID int64, keys model.UserKeys) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current sql.NullString
+	if err := tx.QueryRow(`SELECT public_key FROM users WHERE id = ?`, userID).Scan(&current); err != nil {
+		return err
+	}
+
+	if current.Valid && current.String != keys.PublicKey {
+		if _, err := tx.Exec(`DELETE FROM encrypted_note_keys WHERE recipient_id = ?`, userID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users
+		SET public_key = ?, private_key_ciphertext = ?, private_key_nonce = ?, keys_updated_at = ?
+		WHERE id = ?
+	`, keys.PublicKey, keys.PrivateKeyCiphertext, keys.PrivateKeyNonce, time.Now().UTC().Format(time.RFC3339), userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PutKeyCheck sets the key check of a user. With replaces set, it only
+// happens if the current key check ciphertext equals *replaces ("" = none
+// is set yet); otherwise sql.ErrNoRows is returned.
+func (r *UserKeyRepository) PutKeyCheck(userID int64, kc model.KeyCheck, replaces *string) error {
+	res, err := r.DB.Exec(`
+		UPDATE users
+		SET key_check_ciphertext = ?, key_check_nonce = ?, key_check_updated_at = ?
+		WHERE id = ? AND (? = 0 OR COALESCE(key_check_ciphertext, '') = ?)
+	`, kc.Ciphertext, kc.Nonce, time.Now().UTC().Format(time.RFC3339),
+		userID, replaces != nil, replaces)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// FindPublicKey returns the public key of the user with the given email,
+// or sql.ErrNoRows if there is no such user or they have no key pair.
+func (r *UserKeyRepository) FindPublicKey(email string) (*model.PublicKey, error) {
+	var k model.PublicKey
+
+	err := r.DB.QueryRow(`
+		SELECT id, email, COALESCE(name, ''), public_key
+		FROM users
+		WHERE email = ? AND public_key IS NOT NULL
+	`, email).Scan(&k.UserID, &k.Email, &k.Name, &k.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
@@ -60,19 +60,37 @@ func (r *UserRepository) FindByEmail(email string) (*model.User, error) {
 	return &u, nil
 }
 
-// FindByID retrieves user fields by id.
+// FindByID retrieves user fields by id, including the KDF parameters and
+// the key check.
 //
 // Returns: id, email, passwordHash, name, err
 func (r *UserRepository) FindByID(userID int64) (*model.User, error) {
 	var u model.User
+	var kc model.KeyCheck
+	var kcCiphertext, kdfAlgorithm sql.NullString
 
 	err := r.DB.QueryRow(`
-		SELECT id, email, password_hash, name, user_salt, status, is_admin, created_at
+		SELECT id, email, password_hash, name, user_salt, status, is_admin, created_at,
+		       kdf_algorithm, COALESCE(kdf_iterations, 0), COALESCE(kdf_memory_kib, 0), COALESCE(kdf_parallelism, 0),
+		       key_check_ciphertext, COALESCE(key_check_nonce, ''), COALESCE(key_check_updated_at, '')
 		FROM users WHERE id=?
-	`, userID).Scan(&u.ID, &u.Email, &u.Password, &u.Name, &u.UserSalt, &u.Status, &u.IsAdmin, &u.CreatedAt)
+	`, userID).Scan(&u.ID, &u.Email, &u.Password, &u.Name, &u.UserSalt, &u.Status, &u.IsAdmin, &u.CreatedAt,
+		&kdfAlgorithm, &u.KDF.Iterations, &u.KDF.MemoryKiB, &u.KDF.Parallelism,
+		&kcCiphertext, &kc.Nonce, &kc.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
+
+	if kdfAlgorithm.Valid {
+		u.KDF.Algorithm = kdfAlgorithm.String
+	} else {
+		u.KDF = model.LegacyKDF
+	}
+
+	if kcCiphertext.Valid {
+		kc.Ciphertext = kcCiphertext.String
+		u.KeyCheck = &kc
+	}
 	return &u, nil
 }
 
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/shamal-iroshan/notora/internal/config"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/repository"
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	ErrAttachmentEmpty    = errors.New("attachment is empty")
+
+	// ErrQuotaExceeded is the repository's, the quota is checked as the
+	// attachment is stored.
+	ErrQuotaExceeded = repository.ErrQuotaExceeded
+)
+
+const maxAttachmentFilename = 255
+
+type AttachmentService struct {
+	Repo      *repository.AttachmentRepository
+	Notes     *repository.NoteRepository
+	AppConfig *config.Config
+}
+
+func NewAttachmentService(repo *repository.AttachmentRepository, notes *repository.NoteRepository, cfg *config.Config) *AttachmentService {
+	return &AttachmentService{Repo: repo, Notes: notes, AppConfig: cfg}
+}
+
+// MaxSize is the largest accepted upload in bytes.
+func (s *AttachmentService) MaxSize() int64 {
+	return int64(s.AppConfig.AttachmentMaxSizeMB) << 20
+}
+
+// Upload attaches a file to a note of the user. The stored MIME type is
+// sniffed from the content; the type sent by the client is not trusted.
+func (s *AttachmentService) Upload(userID, noteID int64, filename string, data []byte) (*model.Attachment, error) {
+	if err := s.Notes.EnsureOwnership(userID, noteID); err != nil {
+		return nil, ErrNoteNotFound
+	}
+
+	if len(data) == 0 {
+		return nil, ErrAttachmentEmpty
+	}
+	if int64(len(data)) > s.MaxSize() {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	quota := int64(s.AppConfig.AttachmentQuotaMB) << 20
+	a, err := s.Repo.Create(userID, noteID, cleanFilename(filename), http.DetectContentType(data), data, quota)
+	if err != nil {
+		return nil, err
+	}
+
+	a.URL = attachmentURL(a.ID)
+	return a, nil
+}
+
+// List returns the attachments of a note of the user.
+func (s *AttachmentService) List(userID, noteID int64) ([]model.Attachment, error) {
+	if err := s.Notes.EnsureReadable(userID, noteID); err != nil {
+		return nil, ErrNoteNotFound
+	}
+
+	attachments, err := s.Repo.ListForNote(noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range attachments {
+		attachments[i].URL = attachmentURL(attachments[i].ID)
+	}
+	return attachments, nil
+}
+
+// Download returns an attachment of a note the user owns or that was shared
+// with them, with its decrypted content.
+func (s *AttachmentService) Download(userID, attachmentID int64) (*model.Attachment, []byte, error) {
+	a, hash, err := s.Repo.GetByID(userID, attachmentID)
+	if err != nil {
+		return nil, nil, notFoundOr(err, ErrAttachmentNotFound)
+	}
+
+	data, err := s.Repo.Read(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return a, data, nil
+}
+
+// Delete removes an attachment of the user and its blob, unless the same
+// file is still attached elsewhere.
+func (s *AttachmentService) Delete(userID, attachmentID int64) error {
+	if err := s.Repo.Delete(userID, attachmentID); err != nil {
+		return notFoundOr(err, ErrAttachmentNotFound)
+	}
+
+	collectAttachments(s.Repo)
+	return nil
+}
+
+// Usage returns the attachment storage used by the user and their quota.
+func (s *AttachmentService) Usage(userID int64) (*model.AttachmentUsage, error) {
+	used, err := s.Repo.Usage(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AttachmentUsage{Used: used, Quota: int64(s.AppConfig.AttachmentQuotaMB) << 20}, nil
+}
+
+// collectAttachments removes blobs left without attachments. A failure only
+// leaves files behind until the next run, so it doesn't fail the request.
+func collectAttachments(repo *repository.AttachmentRepository) {
+	if err := repo.CollectGarbage(); err != nil {
+		log.Println("attachment cleanup failed:", err)
+	}
+}
+
+func attachmentURL(id int64) string {
+	return fmt.Sprintf("/api/attachments/%d", id)
+}
+
+// cleanFilename keeps the base name of an uploaded file without control
+// characters, so it is safe to send back in Content-Disposition.
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if runes := []rune(name); len(runes) > maxAttachmentFilename {
+		name = string(runes[:maxAttachmentFilename])
+	}
+	return name
+}
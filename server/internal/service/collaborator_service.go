@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/repository"
+)
+
+var (
+	ErrCollaboratorNotFound = errors.New("collaborator not found")
+	ErrInvalidRole          = errors.New("role must be viewer or editor")
+	ErrUserNotFound         = errors.New("no user with that email")
+	ErrShareWithSelf        = errors.New("you can't share a note with yourself")
+)
+
+// CollaboratorService shares notes with other users. Only the owner of a
+// note manages its collaborators; a collaborator can remove themselves.
+type CollaboratorService struct {
+	Collaborators *repository.CollaboratorRepository
+	Notes         *repository.NoteRepository
+	Users         *repository.UserRepository
+}
+
+func NewCollaboratorService(c *repository.CollaboratorRepository, n *repository.NoteRepository, u *repository.UserRepository) *CollaboratorService {
+	return &CollaboratorService{Collaborators: c, Notes: n, Users: u}
+}
+
+// Add shares a note of the owner with the user with the given email, or
+// changes their role if it is already shared with them.
+func (s *CollaboratorService) Add(ownerID, noteID int64, email, role string) (*model.Collaborator, error) {
+	if role != model.CollaboratorViewer && role != model.CollaboratorEditor {
+		return nil, ErrInvalidRole
+	}
+
+	if err := s.Notes.EnsureOwnership(ownerID, noteID); err != nil {
+		return nil, ErrNoteNotFound
+	}
+
+	user, err := s.Users.FindByEmail(strings.TrimSpace(email))
+	if err != nil {
+		return nil, notFoundOr(err, ErrUserNotFound)
+	}
+	if user.ID == ownerID {
+		return nil, ErrShareWithSelf
+	}
+
+	if err := s.Collaborators.Put(noteID, user.ID, role, ownerID); err != nil {
+		return nil, err
+	}
+
+	return s.Collaborators.Get(noteID, user.ID)
+}
+
+// List returns the collaborators of a note of the owner.
+func (s *CollaboratorService) List(ownerID, noteID int64) ([]model.Collaborator, error) {
+	if err := s.Notes.EnsureOwnership(ownerID, noteID); err != nil {
+		return nil, ErrNoteNotFound
+	}
+
+	return s.Collaborators.List(noteID)
+}
+
+// Remove revokes a collaborator's access to a note. The owner can remove
+// anyone; a collaborator can only remove themselves.
+func (s *CollaboratorService) Remove(userID, noteID, collaboratorID int64) error {
+	if userID != collaboratorID {
+		if err := s.Notes.EnsureOwnership(userID, noteID); err != nil {
+			return ErrNoteNotFound
+		}
+	}
+
+	return notFoundOr(s.Collaborators.Delete(noteID, collaboratorID), ErrCollaboratorNotFound)
+}
+
+// SharedWithMe returns the notes other users shared with the user.
+func (s *CollaboratorService) SharedWithMe(userID int64) ([]model.SharedWithMeNote, error) {
+	return s.Collaborators.SharedWith(userID)
+}
+
+// SharedByMe returns the notes of the user that are shared with others.
+func (s *CollaboratorService) SharedByMe(userID int64) ([]model.SharedByMeNote, error) {
+	return s.Collaborators.SharedBy(userID)
+}
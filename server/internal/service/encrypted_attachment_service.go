@@ -0,0 +1,121 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/shamal-iroshan/notora/internal/config"
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/repository"
+)
+
+const (
+	// MaxEncryptedChunkSize is the largest accepted chunk: 8 MB of
+	// plaintext plus the 16 byte AES-GCM tag.
+	MaxEncryptedChunkSize = 8<<20 + 16
+
+	maxEncryptedChunks      = 100000
+	maxEncryptedMetadataLen = 4096 // decoded bytes
+)
+
+var (
+	ErrInvalidEncryptedAttachment = errors.New("invalid encrypted attachment")
+	ErrInvalidChunkNonce          = errors.New("chunk nonce must be 12 bytes, hex encoded")
+
+	// Upload state is checked by the repository as chunks are stored.
+	ErrChunkOutOfRange  = repository.ErrChunkOutOfRange
+	ErrUploadComplete   = repository.ErrUploadComplete
+	ErrUploadIncomplete = repository.ErrUploadIncomplete
+	ErrNonceReused      = repository.ErrNonceReused
+)
+
+// EncryptedAttachmentService manages files of encrypted notes. Everything
+// it stores is ciphertext; it only checks the shape of what it is given.
+type EncryptedAttachmentService struct {
+	Repo      *repository.EncryptedAttachmentRepository
+	AppConfig *config.Config
+}
+
+func NewEncryptedAttachmentService(repo *repository.EncryptedAttachmentRepository, cfg *config.Config) *EncryptedAttachmentService {
+	return &EncryptedAttachmentService{Repo: repo, AppConfig: cfg}
+}
+
+// Create starts a chunked upload. The chunks are sent with PutChunk and
+// the upload is finished with Complete.
+func (s *EncryptedAttachmentService) Create(userID, noteID int64, input model.CreateEncryptedAttachmentInput) (*model.EncryptedAttachment, error) {
+	metadata, err := base64.StdEncoding.DecodeString(input.MetadataCiphertext)
+	switch {
+	case err != nil, len(metadata) == 0, len(metadata) > maxEncryptedMetadataLen:
+		return nil, ErrInvalidEncryptedAttachment
+	case !isHexOfSize(input.MetadataNonce, gcmNonceSize), !isHexOfSize(input.FileSalt, noteSaltSize):
+		return nil, ErrInvalidEncryptedAttachment
+	case input.ChunkCount < 1 || input.ChunkCount > maxEncryptedChunks:
+		return nil, ErrInvalidEncryptedAttachment
+	}
+	if input.WrappedFileKey != "" || input.FileKeyNonce != "" {
+		if !isBase64OfSize(input.WrappedFileKey, 1, maxWrappedKeyBytes) || !isHexOfSize(input.FileKeyNonce, gcmNonceSize) {
+			return nil, ErrInvalidEncryptedAttachment
+		}
+	}
+
+	id, err := s.Repo.Create(userID, noteID, input)
+	if err != nil {
+		return nil, notFoundOr(err, ErrNoteNotFound)
+	}
+
+	return s.Get(userID, noteID, id)
+}
+
+func (s *EncryptedAttachmentService) Get(userID, noteID, attachmentID int64) (*model.EncryptedAttachment, error) {
+	a, err := s.Repo.Get(userID, noteID, attachmentID)
+	if err != nil {
+		return nil, notFoundOr(err, ErrAttachmentNotFound)
+	}
+	return a, nil
+}
+
+func (s *EncryptedAttachmentService) List(userID, noteID int64) ([]model.EncryptedAttachment, error) {
+	return s.Repo.List(userID, noteID)
+}
+
+// PutChunk stores one chunk of a pending upload.
+func (s *EncryptedAttachmentService) PutChunk(userID, noteID, attachmentID int64, index int, nonce string, data []byte) error {
+	if !isHexOfSize(nonce, gcmNonceSize) {
+		return ErrInvalidChunkNonce
+	}
+	if len(data) == 0 {
+		return ErrAttachmentEmpty
+	}
+	if len(data) > MaxEncryptedChunkSize {
+		return ErrAttachmentTooLarge
+	}
+
+	quota := int64(s.AppConfig.AttachmentQuotaMB) << 20
+	err := s.Repo.PutChunk(userID, noteID, attachmentID, index, nonce, data, quota)
+	if err != nil {
+		return notFoundOr(err, ErrAttachmentNotFound)
+	}
+	return nil
+}
+
+// Complete finishes an upload once all chunks are stored.
+func (s *EncryptedAttachmentService) Complete(userID, noteID, attachmentID int64) (*model.EncryptedAttachment, error) {
+	if err := s.Repo.Complete(userID, noteID, attachmentID); err != nil {
+		return nil, notFoundOr(err, ErrAttachmentNotFound)
+	}
+
+	return s.Get(userID, noteID, attachmentID)
+}
+
+// Chunk returns one chunk of an attachment with its nonce.
+func (s *EncryptedAttachmentService) Chunk(userID, noteID, attachmentID int64, index int) (*model.EncryptedChunk, []byte, error) {
+	c, data, err := s.Repo.Chunk(userID, noteID, attachmentID, index)
+	if err != nil {
+		return nil, nil, notFoundOr(err, ErrAttachmentNotFound)
+	}
+	return c, data, nil
+}
+
+func (s *EncryptedAttachmentService) Delete(userID, noteID, attachmentID int64) error {
+	return notFoundOr(s.Repo.Delete(userID, noteID, attachmentID), ErrAttachmentNotFound)
+}
@@ -1,47 +1,237 @@
 package service
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/pkg/pagination"
 	"github.com/shamal-iroshan/notora/internal/repository"
 )
 
+var (
+	ErrRevisionNotFound    = errors.New("revision not found")
+	ErrInvalidUserSalt     = errors.New("invalid user_salt")
+	ErrDuplicateNote       = errors.New("a note is listed more than once")
+	ErrDuplicateAttachment = errors.New("an attachment is listed more than once")
+	ErrRekeyIncomplete     = errors.New("notes and attachments must list every encrypted note and attachment you own exactly once")
+	ErrNoteSaltReused      = errors.New("every note needs a new note_salt")
+)
+
 type EncryptedNotesService struct {
-	Repo *repository.EncryptedNotesRepository
+	Repo      *repository.EncryptedNotesRepository
+	Keys      *repository.EncryptedNoteKeyRepository
+	Revisions *repository.EncryptedNoteRevisionRepository
 }
 
-func NewEncryptedNotesService(r *repository.EncryptedNotesRepository) *EncryptedNotesService {
-	return &EncryptedNotesService{Repo: r}
+func NewEncryptedNotesService(
+	r *repository.EncryptedNotesRepository,
+	keys *repository.EncryptedNoteKeyRepository,
+	revisions *repository.EncryptedNoteRevisionRepository,
+) *EncryptedNotesService {
+	return &EncryptedNotesService{Repo: r, Keys: keys, Revisions: revisions}
 }
 
+// Create stores a new encrypted note. A malformed payload is a
+// *ValidationError.
 func (s *EncryptedNotesService) Create(userID int64, dto model.CreateEncryptedNoteInput) (int64, error) {
-	return s.Repo.Create(
-		userID,
-		dto.TitleCiphertext,
-		dto.ContentCiphertext,
-		dto.TitleNonce,
-		dto.ContentNonce,
-		dto.NoteSalt,
-	)
+	dto.TitleNonce, dto.ContentNonce = strings.ToLower(dto.TitleNonce), strings.ToLower(dto.ContentNonce)
+
+	errs := validateEncryptedNote(s.Repo.AppConfig, "", encryptedNoteTuple{
+		dto.TitleCiphertext, dto.ContentCiphertext, dto.TitleNonce, dto.ContentNonce, dto.NoteSalt, dto.FormatVersion,
+	})
+	if len(errs) > 0 {
+		return 0, &ValidationError{Fields: errs}
+	}
+
+	return s.Repo.Create(userID, dto)
 }
 
-func (s *EncryptedNotesService) List(userID int64) ([]model.EncryptedNoteMetadata, error) {
-	return s.Repo.List(userID)
+// List returns one page of encrypted note metadata and the cursor of the next page.
+func (s *EncryptedNotesService) List(userID int64, sort pagination.Sort, after *pagination.Cursor, limit int) ([]model.EncryptedNoteMetadata, *string, error) {
+	limit = pagination.ClampLimit(limit)
+
+	notes, err := s.Repo.List(userID, sort, after, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notes, next := pagination.Trim(notes, limit, sort, func(n model.EncryptedNoteMetadata) pagination.Row {
+		return pagination.Row{ID: n.ID, CreatedAt: n.CreatedAt, UpdatedAt: n.UpdatedAt}
+	})
+	return notes, next, nil
 }
 
+// Get returns an encrypted note the user owns or that was shared with them.
+// For shared notes the key wrapped for the user is included.
 func (s *EncryptedNotesService) Get(userID, noteID int64) (*model.EncryptedNoteResponse, error) {
-	return s.Repo.GetByID(userID, noteID)
-}
-
-func (s *EncryptedNotesService) Update(userID, noteID int64, dto model.UpdateEncryptedNoteInput) error {
-	return s.Repo.Update(
-		userID,
-		noteID,
-		dto.TitleCiphertext,
-		dto.ContentCiphertext,
-		dto.TitleNonce,
-		dto.ContentNonce,
-		dto.NoteSalt,
-	)
+	note, err := s.Repo.GetByID(userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.Keys.Get(noteID, userID)
+	switch {
+	case err == nil:
+		note.Key = key
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	return note, nil
+}
+
+// Update replaces the ciphertexts and returns the note's new version.
+// With dto.IfMatch set, a note whose version is not listed is left alone
+// and ErrVersionConflict is returned. A malformed payload, or a nonce the
+// note used before, is a *ValidationError.
+func (s *EncryptedNotesService) Update(userID, noteID int64, dto model.UpdateEncryptedNoteInput) (int64, error) {
+	dto.TitleNonce, dto.ContentNonce = strings.ToLower(dto.TitleNonce), strings.ToLower(dto.ContentNonce)
+
+	errs := validateEncryptedNote(s.Repo.AppConfig, "", encryptedNoteTuple{
+		dto.TitleCiphertext, dto.ContentCiphertext, dto.TitleNonce, dto.ContentNonce, dto.NoteSalt, dto.FormatVersion,
+	})
+	if len(errs) > 0 {
+		return 0, &ValidationError{Fields: errs}
+	}
+
+	version, err := s.update(userID, noteID, dto, s.Repo.Update)
+	if errors.Is(err, repository.ErrNoteNonceReused) {
+		used, usedErr := s.Repo.UsedNonces(noteID, dto.TitleNonce, dto.ContentNonce)
+		if usedErr != nil {
+			return 0, usedErr
+		}
+		return 0, nonceReusedError(used, dto.TitleNonce, dto.ContentNonce)
+	}
+	return version, err
+}
+
+// update writes an update with write and turns the repository errors
+// into the service's.
+func (s *EncryptedNotesService) update(userID, noteID int64, dto model.UpdateEncryptedNoteInput,
+	write func(userID, noteID int64, input model.UpdateEncryptedNoteInput) (int64, error),
+) (int64, error) {
+	version, err := write(userID, noteID, dto)
+	if errors.Is(err, sql.ErrNoRows) {
+		if owner, ownerErr := s.Repo.IsOwner(userID, noteID); ownerErr == nil && !owner {
+			return 0, s.sharedUpdateError(userID, noteID, dto.NoteSalt)
+		}
+	}
+	if err != nil {
+		return 0, versionError(err)
+	}
+	return version, nil
+}
+
+// Rekey replaces the user_salt, every encrypted note the user owns and the
+// wrapping of every attachment's file key in a single transaction, for a
+// master password change or a move to stronger KDF parameters. Either
+// everything is re-encrypted or nothing is.
+func (s *EncryptedNotesService) Rekey(userID int64, input model.RekeyEncryptedNotesInput) error {
+	if !isHexOfSize(input.UserSalt, s.Repo.AppConfig.UserSaltLength) {
+		return ErrInvalidUserSalt
+	}
+	if input.KDF != nil {
+		if err := validateKDF(s.Repo.AppConfig, *input.KDF); err != nil {
+			return err
+		}
+	}
+	if input.KeyCheck != nil {
+		if err := validateKeyCheck(*input.KeyCheck); err != nil {
+			return err
+		}
+	}
+	if input.PrivateKeyCiphertext != "" || input.PrivateKeyNonce != "" {
+		if !isBase64OfSize(input.PrivateKeyCiphertext, 1, maxWrappedKeyBytes) || !isHexOfSize(input.PrivateKeyNonce, gcmNonceSize) {
+			return ErrInvalidPrivateKey
+		}
+	}
+	if rk := input.RecoveryKey; rk != nil {
+		if !isBase64OfSize(rk.WrappedMasterKey, 1, maxWrappedKeyBytes) || !isHexOfSize(rk.Nonce, gcmNonceSize) {
+			return ErrInvalidRecoveryKey
+		}
+	}
+
+	seen := make(map[int64]bool, len(input.Notes))
+	verr := &ValidationError{}
+	for i := range input.Notes {
+		n := &input.Notes[i]
+		if seen[n.ID] {
+			return ErrDuplicateNote
+		}
+		seen[n.ID] = true
+
+		n.TitleNonce, n.ContentNonce = strings.ToLower(n.TitleNonce), strings.ToLower(n.ContentNonce)
+		verr.Fields = append(verr.Fields, validateEncryptedNote(s.Repo.AppConfig, fmt.Sprintf("notes[%d].", i), encryptedNoteTuple{
+			n.TitleCiphertext, n.ContentCiphertext, n.TitleNonce, n.ContentNonce, n.NoteSalt, n.FormatVersion,
+		})...)
+	}
+
+	seenAttachments := make(map[int64]bool, len(input.Attachments))
+	for i, a := range input.Attachments {
+		if seenAttachments[a.ID] {
+			return ErrDuplicateAttachment
+		}
+		seenAttachments[a.ID] = true
+
+		verr.Fields = append(verr.Fields, validateRekeyAttachment(fmt.Sprintf("attachments[%d].", i), a)...)
+	}
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+
+	err := s.Repo.Rekey(userID, input)
+	switch {
+	case errors.Is(err, repository.ErrRekeyIncomplete):
+		return ErrRekeyIncomplete
+	case errors.Is(err, repository.ErrNoteSaltReused):
+		return ErrNoteSaltReused
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return ErrVersionConflict
+	}
+	return err
+}
+
+// -----------------------------------------------------------------------------
+// REVISIONS
+// -----------------------------------------------------------------------------
+
+func (s *EncryptedNotesService) ListRevisions(userID, noteID int64) ([]model.EncryptedNoteRevision, error) {
+	if _, err := s.Repo.GetByID(userID, noteID); err != nil {
+		return nil, notFoundOr(err, ErrNoteNotFound)
+	}
+	return s.Revisions.List(userID, noteID)
+}
+
+func (s *EncryptedNotesService) GetRevision(userID, noteID, revision int64) (*model.EncryptedNoteRevision, error) {
+	rv, err := s.Revisions.Get(userID, noteID, revision)
+	if err != nil {
+		return nil, notFoundOr(err, ErrRevisionNotFound)
+	}
+	return rv, nil
+}
+
+// RestoreRevision copies the ciphertext tuple of an old revision back into
+// the note. Like an update, the tuple being replaced becomes a revision
+// itself and the restore can be undone. The revision is stored as it is:
+// it was accepted once and its nonces belong to it.
+func (s *EncryptedNotesService) RestoreRevision(userID, noteID, revision int64, ifMatch []int64) (int64, error) {
+	rv, err := s.GetRevision(userID, noteID, revision)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.update(userID, noteID, model.UpdateEncryptedNoteInput{
+		TitleCiphertext:   rv.TitleCiphertext,
+		ContentCiphertext: rv.ContentCiphertext,
+		TitleNonce:        rv.TitleNonce,
+		ContentNonce:      rv.ContentNonce,
+		NoteSalt:          rv.NoteSalt,
+		FormatVersion:     rv.FormatVersion,
+		IfMatch:           ifMatch,
+	}, s.Repo.Restore)
 }
 
 func (s *EncryptedNotesService) Delete(userID, noteID int64) error {
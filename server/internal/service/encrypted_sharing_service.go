@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+var (
+	ErrInvalidWrappedKey  = errors.New("wrapped_key and ephemeral_public_key must be base64, wrap_nonce 12 bytes hex")
+	ErrRecipientHasNoKeys = errors.New("recipient has not registered a key pair")
+	ErrNoteSaltChanged    = errors.New("shared notes must keep their note_salt when edited by a recipient")
+)
+
+// Share gives a user access to an encrypted note with the note key wrapped
+// for their public key. Sharing again replaces the wrapped key and role;
+// the owner does this after changing the note's salt.
+func (s *EncryptedNotesService) Share(ownerID, noteID int64, input model.ShareEncryptedNoteInput) error {
+	if input.Role != model.CollaboratorViewer && input.Role != model.CollaboratorEditor {
+		return ErrInvalidRole
+	}
+	if !isBase64OfSize(input.WrappedKey, 1, maxWrappedKeyBytes) ||
+		!isBase64OfSize(input.EphemeralPublicKey, x25519KeySize, x25519KeySize) ||
+		!isHexOfSize(input.WrapNonce, gcmNonceSize) {
+		return ErrInvalidWrappedKey
+	}
+	if input.RecipientID == ownerID {
+		return ErrShareWithSelf
+	}
+
+	if err := s.ensureOwner(ownerID, noteID); err != nil {
+		return err
+	}
+
+	return notFoundOr(s.Keys.Put(noteID, input), ErrRecipientHasNoKeys)
+}
+
+// Recipients lists the users the owner shared an encrypted note with.
+func (s *EncryptedNotesService) Recipients(ownerID, noteID int64) ([]model.EncryptedNoteRecipient, error) {
+	if err := s.ensureOwner(ownerID, noteID); err != nil {
+		return nil, err
+	}
+	return s.Keys.List(noteID)
+}
+
+// Revoke removes a recipient's wrapped key. The owner can remove anyone;
+// recipients can remove themselves. The recipient may still hold the note
+// key, so the owner should re-encrypt the note with a new salt afterwards.
+func (s *EncryptedNotesService) Revoke(userID, noteID, recipientID int64) error {
+	if userID != recipientID {
+		if err := s.ensureOwner(userID, noteID); err != nil {
+			return err
+		}
+	}
+	return notFoundOr(s.Keys.Delete(noteID, recipientID), ErrCollaboratorNotFound)
+}
+
+// SharedWithMe lists the encrypted notes other users shared with the user.
+func (s *EncryptedNotesService) SharedWithMe(userID int64) ([]model.SharedEncryptedNote, error) {
+	return s.Keys.SharedWith(userID)
+}
+
+func (s *EncryptedNotesService) ensureOwner(userID, noteID int64) error {
+	owner, err := s.Repo.IsOwner(userID, noteID)
+	if err != nil {
+		return err
+	}
+	if !owner {
+		return ErrNoteNotFound
+	}
+	return nil
+}
+
+// sharedUpdateError explains why a recipient's update of a shared note
+// found nothing to update.
+func (s *EncryptedNotesService) sharedUpdateError(userID, noteID int64, salt string) error {
+	key, err := s.Keys.Get(noteID, userID)
+	switch {
+	case err != nil:
+		return ErrNoteNotFound
+	case key.Role != model.CollaboratorEditor:
+		return ErrNoteReadOnly
+	case key.NoteSalt != salt:
+		return ErrNoteSaltChanged
+	default:
+		return ErrNoteNotFound
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/shamal-iroshan/notora/internal/config"
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+const (
+	gcmNonceSize            = 12       // bytes, as in the encrypted notes guide
+	gcmTagSize              = 16       // bytes, the smallest AES-GCM ciphertext
+	noteSaltSize            = 16       // bytes
+	maxTitleCiphertextBytes = 4 * 1024 // titles are short, content is limited by ENCRYPTED_NOTE_MAX_SIZE_KB
+)
+
+// FieldError describes one invalid field of an encrypted payload.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of an encrypted note, so a
+// client can fix them all at once instead of storing a note nobody can
+// decrypt.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return "invalid encrypted note: " + strings.Join(msgs, "; ")
+}
+
+// encryptedNoteTuple is the part of a note the client encrypts.
+type encryptedNoteTuple struct {
+	Title, Content, TitleNonce, ContentNonce, NoteSalt string
+	FormatVersion                                      int
+}
+
+// validateEncryptedNote checks the tuple is something a client can
+// decrypt: a known format version, base64 ciphertexts at least a GCM tag
+// long and within the size limits, distinct 12-byte hex nonces and a
+// 16-byte hex salt. Field names are prefixed with prefix.
+func validateEncryptedNote(cfg *config.Config, prefix string, n encryptedNoteTuple) []FieldError {
+	var errs []FieldError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: prefix + field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch n.FormatVersion {
+	case 0:
+		add("format_version", "is required")
+	case model.EncryptedNoteFormatVersion:
+	default:
+		add("format_version", "%d is not supported, expected %d", n.FormatVersion, model.EncryptedNoteFormatVersion)
+	}
+
+	if !isBase64OfSize(n.Title, gcmTagSize, maxTitleCiphertextBytes) {
+		add("title", "must be base64 of %d to %d bytes", gcmTagSize, maxTitleCiphertextBytes)
+	}
+	if maxContent := cfg.EncryptedNoteMaxSizeKB * 1024; !isBase64OfSize(n.Content, gcmTagSize, maxContent) {
+		add("content", "must be base64 of %d to %d bytes", gcmTagSize, maxContent)
+	}
+
+	if !isHexOfSize(n.TitleNonce, gcmNonceSize) {
+		add("title_nonce", "must be %d bytes, hex encoded", gcmNonceSize)
+	}
+	if !isHexOfSize(n.ContentNonce, gcmNonceSize) {
+		add("content_nonce", "must be %d bytes, hex encoded", gcmNonceSize)
+	} else if n.ContentNonce == n.TitleNonce {
+		add("content_nonce", "must differ from title_nonce")
+	}
+
+	if !isHexOfSize(n.NoteSalt, noteSaltSize) {
+		add("note_salt", "must be %d bytes, hex encoded", noteSaltSize)
+	}
+
+	return errs
+}
+
+// validateRekeyAttachment checks the new wrapping of an attachment's file
+// key: a 16-byte hex salt, the wrapped key in base64 and a 12-byte hex
+// nonce. Field names are prefixed with prefix.
+func validateRekeyAttachment(prefix string, a model.RekeyEncryptedAttachment) []FieldError {
+	var errs []FieldError
+	if !isHexOfSize(a.FileSalt, noteSaltSize) {
+		errs = append(errs, FieldError{Field: prefix + "file_salt", Message: fmt.Sprintf("must be %d bytes, hex encoded", noteSaltSize)})
+	}
+	if !isBase64OfSize(a.WrappedFileKey, gcmTagSize+1, maxWrappedKeyBytes) {
+		errs = append(errs, FieldError{Field: prefix + "wrapped_file_key", Message: fmt.Sprintf("must be base64 of %d to %d bytes", gcmTagSize+1, maxWrappedKeyBytes)})
+	}
+	if !isHexOfSize(a.FileKeyNonce, gcmNonceSize) {
+		errs = append(errs, FieldError{Field: prefix + "file_key_nonce", Message: fmt.Sprintf("must be %d bytes, hex encoded", gcmNonceSize)})
+	}
+	return errs
+}
+
+// nonceReusedError reports which of the nonces were used by the note
+// before. GCM leaks the plaintext when a nonce is used twice with a key.
+func nonceReusedError(used []string, titleNonce, contentNonce string) error {
+	verr := &ValidationError{}
+	for _, n := range used {
+		switch n {
+		case titleNonce:
+			verr.Fields = append(verr.Fields, FieldError{Field: "title_nonce", Message: "was already used for this note"})
+		case contentNonce:
+			verr.Fields = append(verr.Fields, FieldError{Field: "content_nonce", Message: "was already used for this note"})
+		}
+	}
+	if len(verr.Fields) == 0 {
+		verr.Fields = []FieldError{{Field: "title_nonce", Message: "or content_nonce was already used for this note"}}
+	}
+	return verr
+}
+
+// isHexOfSize reports whether s is the hex encoding of exactly size bytes.
+func isHexOfSize(s string, size int) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == size
+}
+
+// isBase64OfSize reports whether s is standard base64 of min to max bytes.
+func isBase64OfSize(s string, min, max int) bool {
+	b, err := base64.StdEncoding.DecodeString(s)
+	return err == nil && len(b) >= min && len(b) <= max
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shamal-iroshan/notora/internal/config"
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+func b64(size int) string {
+	return base64.StdEncoding.EncodeToString(make([]byte, size))
+}
+
+func validTuple() encryptedNoteTuple {
+	return encryptedNoteTuple{
+		Title:         b64(32),
+		Content:       b64(64),
+		TitleNonce:    strings.Repeat("01", gcmNonceSize),
+		ContentNonce:  strings.Repeat("02", gcmNonceSize),
+		NoteSalt:      strings.Repeat("03", noteSaltSize),
+		FormatVersion: model.EncryptedNoteFormatVersion,
+	}
+}
+
+func TestValidateEncryptedNote(t *testing.T) {
+	cfg := &config.Config{EncryptedNoteMaxSizeKB: 1}
+
+	tests := []struct {
+		name   string
+		change func(n *encryptedNoteTuple)
+		fields []string
+	}{
+		{"valid", func(n *encryptedNoteTuple) {}, nil},
+		{"smallest ciphertexts", func(n *encryptedNoteTuple) { n.Title, n.Content = b64(gcmTagSize), b64(gcmTagSize) }, nil},
+		{"largest ciphertexts", func(n *encryptedNoteTuple) { n.Title, n.Content = b64(maxTitleCiphertextBytes), b64(1024) }, nil},
+		{"format version 0", func(n *encryptedNoteTuple) { n.FormatVersion = 0 }, []string{"format_version"}},
+		{"format version 2", func(n *encryptedNoteTuple) { n.FormatVersion = 2 }, []string{"format_version"}},
+		{"short title", func(n *encryptedNoteTuple) { n.Title = b64(gcmTagSize - 1) }, []string{"title"}},
+		{"long title", func(n *encryptedNoteTuple) { n.Title = b64(maxTitleCiphertextBytes + 1) }, []string{"title"}},
+		{"short content", func(n *encryptedNoteTuple) { n.Content = b64(gcmTagSize - 1) }, []string{"content"}},
+		{"content over the limit", func(n *encryptedNoteTuple) { n.Content = b64(1025) }, []string{"content"}},
+		{"content not base64", func(n *encryptedNoteTuple) { n.Content = "not base64!" }, []string{"content"}},
+		{"empty content", func(n *encryptedNoteTuple) { n.Content = "" }, []string{"content"}},
+		{"nonce not hex", func(n *encryptedNoteTuple) { n.TitleNonce = strings.Repeat("zz", gcmNonceSize) }, []string{"title_nonce"}},
+		{"nonce too short", func(n *encryptedNoteTuple) { n.ContentNonce = strings.Repeat("02", gcmNonceSize-1) }, []string{"content_nonce"}},
+		{"nonce too long", func(n *encryptedNoteTuple) { n.ContentNonce = strings.Repeat("02", gcmNonceSize+1) }, []string{"content_nonce"}},
+		{"same nonces", func(n *encryptedNoteTuple) { n.ContentNonce = n.TitleNonce }, []string{"content_nonce"}},
+		{"salt too short", func(n *encryptedNoteTuple) { n.NoteSalt = strings.Repeat("03", noteSaltSize-1) }, []string{"note_salt"}},
+		{"salt not hex", func(n *encryptedNoteTuple) { n.NoteSalt = strings.Repeat("g", 2*noteSaltSize) }, []string{"note_salt"}},
+		{
+			"everything wrong",
+			func(n *encryptedNoteTuple) { *n = encryptedNoteTuple{} },
+			[]string{"format_version", "title", "content", "title_nonce", "content_nonce", "note_salt"},
+		},
+	}
+
+	for _, tt := range tests {
+		n := validTuple()
+		tt.change(&n)
+
+		var fields []string
+		for _, f := range validateEncryptedNote(cfg, "", n) {
+			fields = append(fields, f.Field)
+		}
+		if !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("%s: invalid fields %v, want %v", tt.name, fields, tt.fields)
+		}
+	}
+}
+
+func TestValidateEncryptedNotePrefix(t *testing.T) {
+	n := validTuple()
+	n.NoteSalt = ""
+
+	errs := validateEncryptedNote(&config.Config{EncryptedNoteMaxSizeKB: 1}, "notes[3].", n)
+	if len(errs) != 1 || errs[0].Field != "notes[3].note_salt" {
+		t.Errorf("got %v, want one error for notes[3].note_salt", errs)
+	}
+}
+
+func TestNonceReusedError(t *testing.T) {
+	const title, content = "aa", "bb"
+
+	tests := []struct {
+		used   []string
+		fields []string
+	}{
+		{[]string{title}, []string{"title_nonce"}},
+		{[]string{content}, []string{"content_nonce"}},
+		{[]string{title, content}, []string{"title_nonce", "content_nonce"}},
+		// The repository found a reuse the lookup no longer sees
+		{nil, []string{"title_nonce"}},
+		{[]string{"cc"}, []string{"title_nonce"}},
+	}
+
+	for _, tt := range tests {
+		err := nonceReusedError(tt.used, title, content)
+
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("nonceReusedError(%v) = %T, want *ValidationError", tt.used, err)
+		}
+
+		var fields []string
+		for _, f := range verr.Fields {
+			fields = append(fields, f.Field)
+		}
+		if !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("nonceReusedError(%v): fields %v, want %v", tt.used, fields, tt.fields)
+		}
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	err := &ValidationError{Fields: []FieldError{
+		{Field: "title", Message: "is bad"},
+		{Field: "note_salt", Message: "is worse"},
+	}}
+
+	want := "invalid encrypted note: title is bad; note_salt is worse"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestIsHexAndBase64OfSize(t *testing.T) {
+	if !isHexOfSize("00ff", 2) || isHexOfSize("00ff", 3) || isHexOfSize("0g", 1) || isHexOfSize("0", 1) {
+		t.Error("isHexOfSize")
+	}
+	if !isBase64OfSize(b64(4), 1, 4) || isBase64OfSize(b64(5), 1, 4) || isBase64OfSize("", 1, 4) || isBase64OfSize("!!!!", 0, 4) {
+		t.Error("isBase64OfSize")
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/pkg/pagination"
+	"github.com/shamal-iroshan/notora/internal/repository"
+)
+
+const (
+	// Notes are read in pages so an export never holds all notes in memory
+	// (or keeps a read transaction open for the whole download).
+	exportPageSize = 100
+
+	maxExportNameLength = 60
+)
+
+// ExportService writes a user's notes as a ZIP archive:
+//
+//	notes/<title>-<id>.md       plaintext notes with YAML front matter
+//	encrypted/<id>.json         encrypted notes as stored (ciphertext only)
+type ExportService struct {
+	Notes          *repository.NoteRepository
+	EncryptedNotes *repository.EncryptedNotesRepository
+}
+
+func NewExportService(notes *repository.NoteRepository, encrypted *repository.EncryptedNotesRepository) *ExportService {
+	return &ExportService{Notes: notes, EncryptedNotes: encrypted}
+}
+
+// Write streams the export archive of a user to w.
+func (s *ExportService) Write(userID int64, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	if err := s.writeNotes(zw, userID); err != nil {
+		return err
+	}
+
+	if err := s.writeEncryptedNotes(zw, userID); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func (s *ExportService) writeNotes(zw *zip.Writer, userID int64) error {
+	input := model.ListNotesInput{Sort: pagination.SortCreated, Limit: exportPageSize}
+
+	for {
+		notes, err := s.Notes.GetAll(userID, input)
+		if err != nil {
+			return err
+		}
+
+		for _, n := range notes {
+			f, err := createEntry(zw, "notes/"+exportFileName(n.Title, n.ID)+".md", n.UpdatedAt)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(f, noteMarkdown(n)); err != nil {
+				return err
+			}
+		}
+
+		if len(notes) < exportPageSize {
+			return nil
+		}
+
+		last := notes[len(notes)-1]
+		input.After = &pagination.Cursor{Sort: pagination.SortCreated, Value: last.CreatedAt, ID: last.ID}
+	}
+}
+
+func (s *ExportService) writeEncryptedNotes(zw *zip.Writer, userID int64) error {
+	var after *pagination.Cursor
+
+	for {
+		notes, err := s.EncryptedNotes.Page(userID, pagination.SortCreated, after, exportPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, n := range notes {
+			f, err := createEntry(zw, "encrypted/"+strconv.FormatInt(n.ID, 10)+".json", n.UpdatedAt)
+			if err != nil {
+				return err
+			}
+
+			enc := json.NewEncoder(f)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(n); err != nil {
+				return err
+			}
+		}
+
+		if len(notes) < exportPageSize {
+			return nil
+		}
+
+		last := notes[len(notes)-1]
+		after = &pagination.Cursor{Sort: pagination.SortCreated, Value: last.CreatedAt, ID: last.ID}
+	}
+}
+
+// createEntry adds a compressed file to the archive. modified is an RFC 3339
+// timestamp; unparsable values fall back to the current time.
+func createEntry(zw *zip.Writer, name, modified string) (io.Writer, error) {
+	t, err := time.Parse(time.RFC3339, modified)
+	if err != nil {
+		t = time.Now().UTC()
+	}
+
+	return zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: t,
+	})
+}
+
+// noteMarkdown renders a note as Markdown with YAML front matter.
+// Strings are written double-quoted; Go escapes are valid YAML escapes.
+func noteMarkdown(n model.Note) string {
+	var b strings.Builder
+
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(n.Title))
+	fmt.Fprintf(&b, "created_at: %s\n", n.CreatedAt)
+	fmt.Fprintf(&b, "updated_at: %s\n", n.UpdatedAt)
+	fmt.Fprintf(&b, "pinned: %t\n", n.IsPinned)
+	fmt.Fprintf(&b, "archived: %t\n", n.IsArchived)
+	fmt.Fprintf(&b, "trashed: %t\n", n.IsDeleted)
+	if n.DeletedAt != nil {
+		fmt.Fprintf(&b, "deleted_at: %s\n", *n.DeletedAt)
+	}
+
+	tags := make([]string, len(n.Tags))
+	for i, tag := range n.Tags {
+		tags[i] = strconv.Quote(tag)
+	}
+	fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(tags, ", "))
+	b.WriteString("---\n\n")
+
+	b.WriteString(n.Content)
+	if !strings.HasSuffix(n.Content, "\n") {
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// exportFileName builds a file name from a note title that is safe on all
+// common file systems. The note ID keeps names unique.
+func exportFileName(title string, id int64) string {
+	var b strings.Builder
+	n := 0
+
+	for _, r := range strings.TrimSpace(title) {
+		if n == maxExportNameLength {
+			break
+		}
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			r = '-'
+		}
+		b.WriteRune(r)
+		n++
+	}
+
+	name := strings.Trim(strings.TrimSpace(b.String()), ".")
+	if name == "" {
+		name = "untitled"
+	}
+
+	return name + "-" + strconv.FormatInt(id, 10)
+}
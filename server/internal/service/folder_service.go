@@ -0,0 +1,181 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/repository"
+)
+
+const maxFolderNameLength = 128
+
+// Folder delete modes
+const (
+	FolderDeleteCascade      = "cascade"
+	FolderDeleteMoveToParent = "move_to_parent"
+)
+
+var (
+	ErrFolderNotFound    = errors.New("folder not found")
+	ErrInvalidFolderName = errors.New("invalid folder name")
+	ErrFolderCycle       = errors.New("folder cannot be moved into itself")
+	ErrInvalidDeleteMode = errors.New("invalid delete mode")
+)
+
+type FolderService struct {
+	Repo  *repository.FolderRepository
+	Notes *repository.NoteRepository
+}
+
+func NewFolderService(repo *repository.FolderRepository, notes *repository.NoteRepository) *FolderService {
+	return &FolderService{Repo: repo, Notes: notes}
+}
+
+// FolderContents is one page of a folder listing.
+type FolderContents struct {
+	Folder     *model.Folder        `json:"folder"`
+	Subfolders []model.Folder       `json:"subfolders"`
+	Notes      []model.NoteMetadata `json:"notes"`
+	Page       int                  `json:"page"`
+	Limit      int                  `json:"limit"`
+	Total      int                  `json:"total"`
+}
+
+func (s *FolderService) Create(userID int64, parentID *int64, name string) (int64, error) {
+	name, err := normalizeFolderName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.ensureFolder(userID, parentID); err != nil {
+		return 0, err
+	}
+
+	return s.Repo.Create(userID, parentID, name)
+}
+
+func (s *FolderService) List(userID int64) ([]model.Folder, error) {
+	return s.Repo.List(userID)
+}
+
+func (s *FolderService) Rename(userID, folderID int64, name string) error {
+	name, err := normalizeFolderName(name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Repo.Rename(userID, folderID, name); err != nil {
+		return notFoundOr(err, ErrFolderNotFound)
+	}
+	return nil
+}
+
+// Move re-parents a folder. Moving a folder below itself or one of its own
+// descendants is rejected.
+func (s *FolderService) Move(userID, folderID int64, parentID *int64) error {
+	if _, err := s.Repo.GetByID(userID, folderID); err != nil {
+		return notFoundOr(err, ErrFolderNotFound)
+	}
+
+	if err := s.ensureFolder(userID, parentID); err != nil {
+		return err
+	}
+
+	if parentID != nil {
+		cycle, err := s.Repo.IsInSubtree(userID, folderID, *parentID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrFolderCycle
+		}
+	}
+
+	return s.Repo.Move(userID, folderID, parentID)
+}
+
+// Delete removes a folder. mode is either "cascade" (subfolders are deleted and
+// their notes trashed) or "move_to_parent" (contents move one level up).
+func (s *FolderService) Delete(userID, folderID int64, mode string) error {
+	var err error
+
+	switch mode {
+	case FolderDeleteCascade:
+		err = s.Repo.DeleteCascade(userID, folderID)
+	case FolderDeleteMoveToParent:
+		err = s.Repo.DeleteMoveToParent(userID, folderID)
+	default:
+		return ErrInvalidDeleteMode
+	}
+
+	return notFoundOr(err, ErrFolderNotFound)
+}
+
+// Contents returns a folder, its direct subfolders and one page of its notes.
+func (s *FolderService) Contents(userID, folderID int64, recursive bool, page, limit int) (*FolderContents, error) {
+	folder, err := s.Repo.GetByID(userID, folderID)
+	if err != nil {
+		return nil, notFoundOr(err, ErrFolderNotFound)
+	}
+
+	subfolders, err := s.Repo.Children(userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, total, err := s.Repo.ListNotes(userID, folderID, recursive, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FolderContents{
+		Folder:     folder,
+		Subfolders: subfolders,
+		Notes:      notes,
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+	}, nil
+}
+
+// MoveNote puts a note into a folder (nil = top level).
+func (s *FolderService) MoveNote(userID, noteID int64, folderID *int64) error {
+	if err := s.ensureFolder(userID, folderID); err != nil {
+		return err
+	}
+
+	if err := s.Notes.MoveToFolder(userID, noteID, folderID); err != nil {
+		return notFoundOr(err, ErrNoteNotFound)
+	}
+	return nil
+}
+
+// ensureFolder checks that an optional folder ID belongs to the user.
+func (s *FolderService) ensureFolder(userID int64, folderID *int64) error {
+	if folderID == nil {
+		return nil
+	}
+	if _, err := s.Repo.GetByID(userID, *folderID); err != nil {
+		return notFoundOr(err, ErrFolderNotFound)
+	}
+	return nil
+}
+
+func normalizeFolderName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxFolderNameLength {
+		return "", ErrInvalidFolderName
+	}
+	return name, nil
+}
+
+// notFoundOr maps sql.ErrNoRows to the given domain error and passes
+// everything else through.
+func notFoundOr(err, notFound error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound
+	}
+	return err
+}
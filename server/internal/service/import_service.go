@@ -0,0 +1,238 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/pkg/importer"
+)
+
+const (
+	maxImportZipEntries = 10000
+	maxImportEntrySize  = 16 << 20  // uncompressed size of one file inside a ZIP
+	maxImportZipSize    = 256 << 20 // uncompressed size of all files inside a ZIP
+)
+
+var (
+	ErrImportFileTooLarge = errors.New("file too large")
+	ErrImportZipTooLarge  = errors.New("ZIP archive too large when unpacked")
+)
+
+// ImportFile is one uploaded file.
+type ImportFile struct {
+	Name string
+	Data []byte
+}
+
+// ImportService turns uploaded files from other note apps into notes.
+// Parsing is done by the importer package, notes are created through
+// NoteService so tags are normalized like everywhere else.
+type ImportService struct {
+	Notes *NoteService
+}
+
+func NewImportService(notes *NoteService) *ImportService {
+	return &ImportService{Notes: notes}
+}
+
+// Import creates notes from the uploaded files and reports the outcome per
+// file. ZIP archives are unpacked and every entry is reported on its own.
+// A file holding the same note as another file of the import (e.g. the HTML
+// and JSON copies in a Google Keep Takeout) is skipped.
+func (s *ImportService) Import(userID int64, files []ImportFile) []model.ImportFileResult {
+	results := []model.ImportFileResult{}
+
+	names := map[string]bool{}
+	for _, f := range files {
+		names[f.Name] = true
+	}
+
+	for _, f := range files {
+		if importer.Ext(f.Name) == ".zip" {
+			results = append(results, s.importZip(userID, f)...)
+			continue
+		}
+
+		if other, ok := importer.Superseded(f.Name, names); ok {
+			results = append(results, skipped(f.Name, other))
+			continue
+		}
+
+		results = append(results, s.importFile(userID, f.Name, f.Data))
+	}
+
+	return results
+}
+
+func (s *ImportService) importZip(userID int64, f ImportFile) []model.ImportFileResult {
+	zr, err := openZip(f.Data)
+	if err != nil {
+		return []model.ImportFileResult{failed(f.Name, err)}
+	}
+
+	names := map[string]bool{}
+	for _, entry := range zr.File {
+		names[entry.Name] = true
+	}
+
+	results := []model.ImportFileResult{}
+	budget := int64(maxImportZipSize)
+	for _, entry := range zr.File {
+		if entry.FileInfo().IsDir() || importer.Ignored(entry.Name) {
+			continue
+		}
+
+		name := f.Name + "/" + entry.Name
+
+		if other, ok := importer.Superseded(entry.Name, names); ok {
+			results = append(results, skipped(name, f.Name+"/"+other))
+			continue
+		}
+
+		data, err := readZipEntry(entry, &budget)
+		if errors.Is(err, ErrImportZipTooLarge) {
+			// Notes created so far stay, the rest of the archive is refused
+			results = append(results, failed(f.Name, err))
+			break
+		}
+		if err != nil {
+			results = append(results, failed(name, err))
+			continue
+		}
+
+		result := s.importFile(userID, name, data)
+		if result.Error == importer.ErrUnsupported.Error() {
+			// Archives often contain images and other attachments
+			result.Status = model.ImportStatusSkipped
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// importFile parses one file and creates its notes.
+func (s *ImportService) importFile(userID int64, name string, data []byte) model.ImportFileResult {
+	notes, err := importer.Parse(name, data)
+	if err != nil {
+		return failed(name, err)
+	}
+
+	result := model.ImportFileResult{File: name, Notes: []model.ImportedNote{}}
+	for _, n := range notes {
+		id, err := s.create(userID, n)
+		if err != nil {
+			result.Error = "could not create note " + n.Title
+			continue
+		}
+		result.Notes = append(result.Notes, model.ImportedNote{ID: id, Title: n.Title})
+	}
+
+	switch {
+	case result.Error == "":
+		result.Status = model.ImportStatusImported
+	case len(result.Notes) > 0:
+		result.Status = model.ImportStatusPartial
+	default:
+		result.Status = model.ImportStatusFailed
+	}
+
+	return result
+}
+
+// create stores one imported note with its tags, flags and original
+// timestamps. It either creates the whole note or nothing.
+func (s *ImportService) create(userID int64, n importer.Note) (int64, error) {
+	created := n.CreatedAt
+	if created == "" {
+		created = n.UpdatedAt
+	}
+
+	return s.Notes.Create(userID, model.CreateNoteInput{
+		Title:      n.Title,
+		Content:    n.Content,
+		Tags:       importTags(n.Tags),
+		IsPinned:   n.Pinned,
+		IsArchived: n.Archived,
+		IsDeleted:  n.Trashed,
+		CreatedAt:  created,
+		UpdatedAt:  n.UpdatedAt,
+	})
+}
+
+// importTags keeps the tag names NOTORA accepts and drops the rest
+// (e.g. names containing commas), instead of failing the whole note.
+func importTags(names []string) []string {
+	var tags []string
+	for _, name := range names {
+		if tag, err := normalizeTagName(name); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// openZip opens an uploaded ZIP archive. Archives with too many entries, or
+// whose entries claim to unpack to more than maxImportZipSize together, are
+// refused before anything is imported.
+func openZip(data []byte) (*zip.Reader, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("invalid ZIP archive")
+	}
+	if len(zr.File) > maxImportZipEntries {
+		return nil, errors.New("too many files in ZIP archive")
+	}
+
+	var total uint64
+	for _, entry := range zr.File {
+		total += entry.UncompressedSize64
+		if total > maxImportZipSize {
+			return nil, ErrImportZipTooLarge
+		}
+	}
+
+	return zr, nil
+}
+
+// readZipEntry unpacks one entry and takes its size from budget, the bytes
+// the rest of the archive may still unpack to. ErrImportFileTooLarge refuses
+// the entry, ErrImportZipTooLarge the rest of the archive.
+func readZipEntry(entry *zip.File, budget *int64) ([]byte, error) {
+	if entry.UncompressedSize64 > maxImportEntrySize {
+		return nil, ErrImportFileTooLarge
+	}
+
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// The header sizes can lie, so the read is limited as well
+	limit := min(int64(maxImportEntrySize), *budget)
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportEntrySize {
+		return nil, ErrImportFileTooLarge
+	}
+	if int64(len(data)) > *budget {
+		return nil, ErrImportZipTooLarge
+	}
+
+	*budget -= int64(len(data))
+	return data, nil
+}
+
+func failed(name string, err error) model.ImportFileResult {
+	return model.ImportFileResult{File: name, Status: model.ImportStatusFailed, Error: err.Error()}
+}
+
+func skipped(name, duplicateOf string) model.ImportFileResult {
+	return model.ImportFileResult{File: name, Status: model.ImportStatusSkipped, Error: "same note as " + duplicateOf}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// testZip builds an archive of stored (uncompressed) entries. An entry
+// whose declared size is set claims that size in its header instead of
+// the real one.
+func testZip(t *testing.T, entries []testZipEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		size := uint64(len(e.data))
+		if e.declared != 0 {
+			size = e.declared
+		}
+
+		w, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               e.name,
+			Method:             zip.Store,
+			CRC32:              crc32.ChecksumIEEE(e.data),
+			CompressedSize64:   uint64(len(e.data)),
+			UncompressedSize64: size,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(e.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+type testZipEntry struct {
+	name     string
+	data     []byte
+	declared uint64
+}
+
+func TestOpenZip(t *testing.T) {
+	zr, err := openZip(testZip(t, []testZipEntry{{name: "a.md", data: []byte("# A")}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 {
+		t.Errorf("got %d entries, want 1", len(zr.File))
+	}
+
+	if _, err := openZip([]byte("not a zip")); err == nil {
+		t.Error("invalid archive: expected an error")
+	}
+
+	bomb := testZip(t, []testZipEntry{
+		{name: "a.md", data: []byte("a"), declared: maxImportZipSize / 2},
+		{name: "b.md", data: []byte("b"), declared: maxImportZipSize/2 + 1},
+	})
+	if _, err := openZip(bomb); !errors.Is(err, ErrImportZipTooLarge) {
+		t.Errorf("declared total over the cap: error = %v, want ErrImportZipTooLarge", err)
+	}
+}
+
+func TestReadZipEntry(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 100)
+	zr, err := openZip(testZip(t, []testZipEntry{
+		{name: "a.md", data: data},
+		{name: "huge.md", data: []byte("x"), declared: maxImportEntrySize + 1},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	budget := int64(1000)
+	got, err := readZipEntry(zr.File[0], &budget)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("readZipEntry() = %d bytes, %v", len(got), err)
+	}
+	if budget != 900 {
+		t.Errorf("budget = %d, want 900", budget)
+	}
+
+	if _, err := readZipEntry(zr.File[1], &budget); !errors.Is(err, ErrImportFileTooLarge) {
+		t.Errorf("declared entry size over the cap: error = %v, want ErrImportFileTooLarge", err)
+	}
+
+	budget = 50
+	if _, err := readZipEntry(zr.File[0], &budget); !errors.Is(err, ErrImportZipTooLarge) {
+		t.Errorf("unpacked size over the budget: error = %v, want ErrImportZipTooLarge", err)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/shamal-iroshan/notora/internal/config"
+	"github.com/shamal-iroshan/notora/internal/model"
+)
+
+var (
+	ErrUnsupportedKDF = errors.New("kdf must be PBKDF2-SHA256 with iterations, or Argon2id with iterations, memory_kib and parallelism")
+	ErrWeakKDF        = errors.New("kdf parameters are below the server minimum")
+	ErrCostlyKDF      = errors.New("kdf parameters are above the server maximum")
+)
+
+// validateKDF checks that a client may derive its master key with p.
+func validateKDF(cfg *config.Config, p model.KDFParams) error {
+	switch p.Algorithm {
+	case model.KDFPBKDF2SHA256:
+		if p.MemoryKiB != 0 || p.Parallelism != 0 {
+			return ErrUnsupportedKDF
+		}
+	case model.KDFArgon2id:
+	default:
+		return ErrUnsupportedKDF
+	}
+
+	if !KDFMeetsMinimum(cfg, p) {
+		return ErrWeakKDF
+	}
+	if !kdfWithinMaximum(cfg, p) {
+		return ErrCostlyKDF
+	}
+	return nil
+}
+
+// KDFMeetsMinimum reports whether p is at least as strong as the server
+// minimum for its algorithm. Users below it should upgrade.
+func KDFMeetsMinimum(cfg *config.Config, p model.KDFParams) bool {
+	switch p.Algorithm {
+	case model.KDFPBKDF2SHA256:
+		return p.Iterations >= max(cfg.KDFMinPBKDF2Iterations, 1)
+	case model.KDFArgon2id:
+		return p.Iterations >= max(cfg.KDFMinArgon2Iterations, 1) &&
+			p.MemoryKiB >= max(cfg.KDFMinArgon2MemoryKiB, 8*p.Parallelism) &&
+			p.Parallelism >= max(cfg.KDFMinArgon2Threads, 1)
+	default:
+		return false
+	}
+}
+
+// kdfWithinMaximum reports whether p is at most as costly as the server
+// maximum for its algorithm. A limit of 0 means none.
+func kdfWithinMaximum(cfg *config.Config, p model.KDFParams) bool {
+	within := func(v, limit int) bool { return limit <= 0 || v <= limit }
+
+	switch p.Algorithm {
+	case model.KDFPBKDF2SHA256:
+		return within(p.Iterations, cfg.KDFMaxPBKDF2Iterations)
+	case model.KDFArgon2id:
+		return within(p.Iterations, cfg.KDFMaxArgon2Iterations) &&
+			within(p.MemoryKiB, cfg.KDFMaxArgon2MemoryKiB) &&
+			within(p.Parallelism, cfg.KDFMaxArgon2Threads)
+	default:
+		return false
+	}
+}
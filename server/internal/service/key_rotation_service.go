@@ -0,0 +1,69 @@
+package service
+
+import "github.com/shamal-iroshan/notora/internal/repository"
+
+// KeyRotationService moves data sealed with older server encryption keys
+// to the active key: note contents, note revisions and attachment blobs.
+// End-to-end encrypted notes are not involved, the server can't read them.
+type KeyRotationService struct {
+	Notes       *repository.NoteRepository
+	Revisions   *repository.NoteRevisionRepository
+	Attachments *repository.AttachmentRepository
+}
+
+func NewKeyRotationService(
+	notes *repository.NoteRepository,
+	revisions *repository.NoteRevisionRepository,
+	attachments *repository.AttachmentRepository,
+) *KeyRotationService {
+	return &KeyRotationService{Notes: notes, Revisions: revisions, Attachments: attachments}
+}
+
+// ActiveKeyID returns the ID of the key data is rotated to.
+func (s *KeyRotationService) ActiveKeyID() string {
+	return s.Notes.AppConfig.Keyring.ActiveID()
+}
+
+// keyRotationStages is how many tables RotateBatch goes through: notes,
+// revisions, then attachment blobs.
+const keyRotationStages = 3
+
+// KeyRotationCursor is where key rotation left off: the table it is in and
+// the last row ID it handled. The zero value starts at the beginning.
+type KeyRotationCursor struct {
+	stage int
+	after int64
+}
+
+// Done reports whether every table has been gone through.
+func (c KeyRotationCursor) Done() bool {
+	return c.stage >= keyRotationStages
+}
+
+// RotateBatch re-encrypts up to limit rows after the cursor that are still
+// on an old key and returns the cursor to continue from. Rows that failed
+// are skipped and listed in the batch; an error means the batch couldn't
+// be read at all and may be retried with the same cursor.
+func (s *KeyRotationService) RotateBatch(c KeyRotationCursor, limit int) (KeyRotationCursor, repository.RotationBatch, error) {
+	var rotate func(after int64, limit int) (repository.RotationBatch, error)
+	switch c.stage {
+	case 0:
+		rotate = s.Notes.RotateKeys
+	case 1:
+		rotate = s.Revisions.RotateKeys
+	case 2:
+		rotate = s.Attachments.RotateKeys
+	default:
+		return c, repository.RotationBatch{}, nil
+	}
+
+	batch, err := rotate(c.after, limit)
+	if err != nil {
+		return c, batch, err
+	}
+	if batch.Last == 0 {
+		return KeyRotationCursor{stage: c.stage + 1}, batch, nil
+	}
+
+	return KeyRotationCursor{stage: c.stage, after: batch.Last}, batch, nil
+}
@@ -3,55 +3,315 @@ package service
 import (
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/pkg/diff"
+	"github.com/shamal-iroshan/notora/internal/pkg/pagination"
+	"github.com/shamal-iroshan/notora/internal/pkg/search"
 	"github.com/shamal-iroshan/notora/internal/repository"
 )
 
+var (
+	ErrNoteNotFound    = errors.New("note not found")
+	ErrVersionConflict = errors.New("version conflict")
+	ErrNoteReadOnly    = errors.New("note is shared with you read-only")
+)
+
 type NoteService struct {
-	Repo *repository.NoteRepository
+	Repo        *repository.NoteRepository
+	Revisions   *repository.NoteRevisionRepository
+	Tags        *repository.TagRepository
+	Folders     *repository.FolderRepository
+	Attachments *repository.AttachmentRepository
 }
 
-func NewNoteService(repo *repository.NoteRepository) *NoteService {
-	return &NoteService{Repo: repo}
+func NewNoteService(
+	repo *repository.NoteRepository,
+	revisions *repository.NoteRevisionRepository,
+	tags *repository.TagRepository,
+	folders *repository.FolderRepository,
+	attachments *repository.AttachmentRepository,
+) *NoteService {
+	return &NoteService{Repo: repo, Revisions: revisions, Tags: tags, Folders: folders, Attachments: attachments}
 }
 
-func (s *NoteService) Create(userID int64, title, content string) (int64, error) {
-	return s.Repo.Create(userID, title, content)
+// Create creates a note, attaches the given tags (created if missing)
+// and optionally places it in a folder, all at once.
+func (s *NoteService) Create(userID int64, input model.CreateNoteInput) (int64, error) {
+	tags, err := normalizeTagNames(input.Tags)
+	if err != nil {
+		return 0, err
+	}
+	input.Tags = tags
+
+	if input.FolderID != nil {
+		if _, err := s.Folders.GetByID(userID, *input.FolderID); err != nil {
+			return 0, notFoundOr(err, ErrFolderNotFound)
+		}
+	}
+
+	return s.Repo.Create(userID, input)
 }
 
-func (s *NoteService) Get(userID, noteID int64) (interface{}, error) {
+func (s *NoteService) Get(userID, noteID int64) (*model.Note, error) {
 	note, err := s.Repo.GetByID(userID, noteID)
 	if err != nil {
-		return nil, errors.New("note not found")
+		return nil, ErrNoteNotFound
+	}
+
+	note.Tags, err = s.Tags.ForNote(noteID)
+	if err != nil {
+		return nil, err
 	}
+
 	return note, nil
 }
 
-func (s *NoteService) GetAll(userID int64) (*sql.Rows, error) {
-	return s.Repo.GetAll(userID)
+// GetAll returns one page of the user's notes and the cursor of the next page
+// (nil on the last page).
+func (s *NoteService) GetAll(userID int64, input model.ListNotesInput) ([]model.Note, *string, error) {
+	limit := pagination.ClampLimit(input.Limit)
+	input.Limit = limit + 1
+
+	notes, err := s.Repo.GetAll(userID, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notes, next := pagination.Trim(notes, limit, input.Sort, func(n model.Note) pagination.Row {
+		return pagination.Row{ID: n.ID, Title: n.Title, Pinned: n.IsPinned, CreatedAt: n.CreatedAt, UpdatedAt: n.UpdatedAt}
+	})
+	return notes, next, nil
 }
 
-func (s *NoteService) Update(userID, noteID int64, title, content string) error {
-	return s.Repo.Update(noteID, userID, title, content)
+// Update replaces the title and content of a note and returns its new
+// version. When tags is nil the note's tags are left untouched, an empty
+// slice removes all tags. Tags are the owner's, so editors of a shared
+// note can't change them and their tags are ignored. With input.IfMatch
+// set, a note whose version is not listed is left alone and
+// ErrVersionConflict is returned.
+func (s *NoteService) Update(userID, noteID int64, input model.UpdateNoteInput) (int64, error) {
+	tags := input.Tags
+	if tags != nil && s.Repo.EnsureOwnership(userID, noteID) != nil {
+		tags = nil
+	}
+	if tags != nil {
+		var err error
+		if tags, err = normalizeTagNames(tags); err != nil {
+			return 0, err
+		}
+	}
+
+	version, err := s.Repo.Update(noteID, userID, input.Title, input.Content, tags, input.IfMatch...)
+	if errors.Is(err, sql.ErrNoRows) && s.Repo.EnsureReadable(userID, noteID) == nil {
+		return 0, ErrNoteReadOnly
+	}
+	if err != nil {
+		return 0, versionError(err)
+	}
+
+	return version, nil
 }
 
-func (s *NoteService) UpdateFlags(userID, noteID int64, pinned, archived, deleted *bool) error {
-	return s.Repo.UpdateFlags(noteID, userID, pinned, archived, deleted)
+// UpdateFlags changes pin/archive/trash flags and returns the new version.
+// ifMatch works like UpdateNoteInput.IfMatch.
+func (s *NoteService) UpdateFlags(userID, noteID int64, pinned, archived, deleted *bool, ifMatch ...int64) (int64, error) {
+	version, err := s.Repo.UpdateFlags(noteID, userID, pinned, archived, deleted, ifMatch...)
+	if err != nil {
+		return 0, versionError(err)
+	}
+	return version, nil
 }
 
+// DeleteForever deletes a note with its attachments.
 func (s *NoteService) DeleteForever(userID, noteID int64) error {
-	return s.Repo.DeletePermanently(noteID, userID)
+	if err := s.Repo.DeletePermanently(noteID, userID); err != nil {
+		return err
+	}
+
+	collectAttachments(s.Attachments)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// TRASH
+// -----------------------------------------------------------------------------
+
+// EmptyTrash permanently deletes every trashed note of the user and returns
+// how many were deleted.
+func (s *NoteService) EmptyTrash(userID int64) (int64, error) {
+	deleted, err := s.Repo.EmptyTrash(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if deleted > 0 {
+		collectAttachments(s.Attachments)
+	}
+	return deleted, nil
+}
+
+// RestoreFromTrash restores the given trashed notes, or the whole trash
+// when noteIDs is empty, and returns how many were restored.
+func (s *NoteService) RestoreFromTrash(userID int64, noteIDs []int64) (int64, error) {
+	return s.Repo.RestoreFromTrash(userID, noteIDs)
+}
+
+// PurgeExpiredTrash permanently deletes notes that have been in the trash
+// for longer than retention, across all users.
+func (s *NoteService) PurgeExpiredTrash(retention time.Duration) (int64, error) {
+	before := time.Now().UTC().Add(-retention).Format(time.RFC3339)
+
+	deleted, err := s.Repo.PurgeExpiredTrash(before)
+	if err != nil {
+		return 0, err
+	}
+
+	if deleted > 0 {
+		collectAttachments(s.Attachments)
+	}
+	return deleted, nil
 }
 
+// Duplicate copies a note, including its tags.
 func (s *NoteService) Duplicate(userID, noteID int64) (int64, error) {
-	return s.Repo.Duplicate(userID, noteID)
+	newID, err := s.Repo.Duplicate(userID, noteID)
+	if err != nil {
+		return 0, err
+	}
+
+	tags, err := s.Tags.ForNote(noteID)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(tags) > 0 {
+		if err := s.Tags.SetForNote(userID, newID, tags); err != nil {
+			return 0, err
+		}
+	}
+
+	return newID, nil
+}
+
+// Metadata returns one page of note metadata and the cursor of the next page.
+func (s *NoteService) Metadata(userID int64, input model.ListNotesInput) ([]model.NoteMetadata, *string, error) {
+	limit := pagination.ClampLimit(input.Limit)
+	input.Limit = limit + 1
+
+	notes, err := s.Repo.GetMetadata(userID, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notes, next := pagination.Trim(notes, limit, input.Sort, func(n model.NoteMetadata) pagination.Row {
+		return pagination.Row{ID: n.ID, Title: n.Title, Pinned: n.IsPinned, CreatedAt: n.CreatedAt, UpdatedAt: n.UpdatedAt}
+	})
+	return notes, next, nil
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	snippetRadius      = 80
+)
+
+// Search runs a full-text search over the user's notes and attaches a
+// highlighted snippet (built from the decrypted content) to every result.
+func (s *NoteService) Search(userID int64, input model.SearchNotesInput) ([]model.NoteSearchResult, error) {
+	query, err := s.Repo.Indexer.ParseQuery(input.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Limit <= 0 {
+		input.Limit = defaultSearchLimit
+	}
+	if input.Limit > maxSearchLimit {
+		input.Limit = maxSearchLimit
+	}
+
+	results, err := s.Repo.Search(userID, query.Expr, input)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Snippet = search.Snippet(results[i].Content, query, snippetRadius)
+	}
+
+	return results, nil
+}
+
+// -----------------------------------------------------------------------------
+// REVISIONS
+// -----------------------------------------------------------------------------
+
+func (s *NoteService) ListRevisions(userID, noteID int64) ([]model.NoteRevision, error) {
+	if err := s.Repo.EnsureReadable(userID, noteID); err != nil {
+		return nil, notFoundOr(err, ErrNoteNotFound)
+	}
+	return s.Revisions.List(userID, noteID)
+}
+
+func (s *NoteService) GetRevision(userID, noteID, revision int64) (*model.NoteRevision, error) {
+	rv, err := s.Revisions.Get(userID, noteID, revision)
+	if err != nil {
+		return nil, notFoundOr(err, ErrRevisionNotFound)
+	}
+	return rv, nil
+}
+
+// DiffRevisions returns a line-level diff of the note content between two
+// revisions. A revision number of 0 means the current version of the note.
+func (s *NoteService) DiffRevisions(userID, noteID, from, to int64) ([]diff.Line, error) {
+	oldContent, err := s.revisionContent(userID, noteID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	newContent, err := s.revisionContent(userID, noteID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff.Lines(oldContent, newContent), nil
+}
+
+// RestoreRevision writes an old revision back as the note's current content.
+// It goes through Update, so the version being replaced is itself snapshotted
+// and the restore can be undone. Viewers of a shared note get
+// ErrNoteReadOnly.
+func (s *NoteService) RestoreRevision(userID, noteID, revision int64) error {
+	rv, err := s.GetRevision(userID, noteID, revision)
+	if err != nil {
+		return err
+	}
+	_, err = s.Update(userID, noteID, model.UpdateNoteInput{Title: rv.Title, Content: rv.Content})
+	return err
 }
 
-func (s *NoteService) Metadata(userID int64) (*sql.Rows, error) {
-	return s.Repo.GetMetadata(userID)
+func (s *NoteService) revisionContent(userID, noteID, revision int64) (string, error) {
+	if revision == 0 {
+		note, err := s.Repo.GetByID(userID, noteID)
+		if err != nil {
+			return "", notFoundOr(err, ErrNoteNotFound)
+		}
+		return note.Content, nil
+	}
+
+	rv, err := s.GetRevision(userID, noteID, revision)
+	if err != nil {
+		return "", err
+	}
+	return rv.Content, nil
 }
 
-func (s *NoteService) Search(userID int64, query string) ([]model.Note, error) {
-	return s.Repo.Search(userID, query)
+// versionError maps the errors of a conditional update to service errors.
+func versionError(err error) error {
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		return ErrVersionConflict
+	}
+	return notFoundOr(err, ErrNoteNotFound)
 }
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/pkg/crypto"
+	"github.com/shamal-iroshan/notora/internal/pkg/mailer"
+	"github.com/shamal-iroshan/notora/internal/repository"
+)
+
+const (
+	// recoveryTokenTTL is how long an emailed recovery token can be used.
+	recoveryTokenTTL = 10 * time.Minute
+
+	// A user can be sent recoveryTokenLimit tokens per recoveryTokenWindow.
+	// Tokens are kept that long, then purged.
+	recoveryTokenLimit  = 3
+	recoveryTokenWindow = time.Hour
+)
+
+var (
+	ErrRecoveryKeyNotFound  = errors.New("no recovery key set")
+	ErrInvalidRecoveryKey   = errors.New("wrapped_master_key must be base64 and nonce 12 bytes, hex encoded")
+	ErrInvalidRecoveryToken = errors.New("invalid or expired recovery token")
+	ErrRecoveryTokenLimit   = errors.New("too many recovery tokens requested, try again later")
+)
+
+// RecoveryService keeps the user's master key wrapped under a recovery key
+// so encrypted notes survive a forgotten master password. The server can't
+// unwrap it; it only hands it out to a signed-in user who also proves
+// control of the account's email address. Email is the only proof offered:
+// there is no TOTP enrollment to check a code against.
+type RecoveryService struct {
+	Repo   *repository.RecoveryRepository
+	Users  *repository.UserRepository
+	Mailer mailer.Mailer
+}
+
+func NewRecoveryService(repo *repository.RecoveryRepository, users *repository.UserRepository, mail mailer.Mailer) *RecoveryService {
+	return &RecoveryService{Repo: repo, Users: users, Mailer: mail}
+}
+
+// Status tells whether the user has a recovery key.
+func (s *RecoveryService) Status(userID int64) (*model.RecoveryKeyStatus, error) {
+	key, err := s.Repo.Get(userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return &model.RecoveryKeyStatus{}, nil
+	case err != nil:
+		return nil, err
+	}
+	return &model.RecoveryKeyStatus{Enabled: true, UpdatedAt: &key.UpdatedAt}, nil
+}
+
+// Put sets the recovery key, or rotates it: the client wraps the master
+// key under a new recovery key and the old one stops working.
+func (s *RecoveryService) Put(userID int64, wrappedMasterKey, nonce string) (*model.RecoveryKeyStatus, error) {
+	if !isBase64OfSize(wrappedMasterKey, 1, maxWrappedKeyBytes) || !isHexOfSize(nonce, gcmNonceSize) {
+		return nil, ErrInvalidRecoveryKey
+	}
+
+	if err := s.Repo.Put(userID, wrappedMasterKey, nonce); err != nil {
+		return nil, err
+	}
+
+	return s.Status(userID)
+}
+
+// Delete removes the recovery key.
+func (s *RecoveryService) Delete(userID int64) error {
+	return notFoundOr(s.Repo.Delete(userID), ErrRecoveryKeyNotFound)
+}
+
+// RequestToken emails the user a one-time token for Recover. At most
+// recoveryTokenLimit tokens are sent per recoveryTokenWindow.
+func (s *RecoveryService) RequestToken(userID int64) error {
+	if _, err := s.Repo.Get(userID); err != nil {
+		return notFoundOr(err, ErrRecoveryKeyNotFound)
+	}
+
+	sent, err := s.Repo.CountTokensSince(userID, time.Now().Add(-recoveryTokenWindow))
+	if err != nil {
+		return err
+	}
+	if sent >= recoveryTokenLimit {
+		return ErrRecoveryTokenLimit
+	}
+
+	user, err := s.Users.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	token, err := crypto.RandomHex(32)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Repo.InsertToken(userID, crypto.SHA256Hex(token), time.Now().Add(recoveryTokenTTL)); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Your NOTORA recovery token is:\n\n%s\n\n"+
+		"It is valid for %d minutes. If you did not ask for it, someone signed in "+
+		"to your account is trying to recover your encrypted notes: change your password.\n",
+		token, int(recoveryTokenTTL/time.Minute))
+
+	return s.Mailer.Send(user.Email, "NOTORA recovery token", body)
+}
+
+// PurgeTokens deletes tokens older than recoveryTokenWindow. They are
+// expired and no longer count towards the limit.
+func (s *RecoveryService) PurgeTokens() (int64, error) {
+	return s.Repo.PurgeTokens(time.Now().Add(-recoveryTokenWindow))
+}
+
+// Recover returns the wrapped master key in exchange for an emailed token.
+// Each token works once.
+func (s *RecoveryService) Recover(userID int64, token string) (*model.RecoveryKey, error) {
+	key, err := s.Repo.Get(userID)
+	if err != nil {
+		return nil, notFoundOr(err, ErrRecoveryKeyNotFound)
+	}
+
+	if err := s.Repo.UseToken(userID, crypto.SHA256Hex(token)); err != nil {
+		return nil, notFoundOr(err, ErrInvalidRecoveryToken)
+	}
+
+	return key, nil
+}
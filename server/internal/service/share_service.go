@@ -1,51 +1,410 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/shamal-iroshan/notora/internal/config"
 	"github.com/shamal-iroshan/notora/internal/model"
 	"github.com/shamal-iroshan/notora/internal/pkg/crypto"
 	"github.com/shamal-iroshan/notora/internal/repository"
+	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrShareNotAllowed       = errors.New("not allowed")
+	ErrShareNotFound         = errors.New("share not found")
+	ErrShareExpired          = errors.New("share link has expired")
+	ErrShareViewLimit        = errors.New("share link has reached its view limit")
+	ErrSharePasswordRequired = errors.New("password required")
+	ErrShareWrongPassword    = errors.New("wrong password")
+	ErrShareAccessDenied     = errors.New("access expired, open the share link again")
+	ErrInvalidShareOptions   = errors.New("invalid share options")
+)
+
+const (
+	// shareAccessTTL is how long an unlocked password link, and the
+	// attachment URLs of a protected link, stay usable.
+	shareAccessTTL = time.Hour
+
+	maxSharePasswordLen = 72 // bcrypt ignores anything longer
+	maxShareLabelLen    = 100
+
+	// sharedNoteCacheTTL is how long caches may keep a note shared through
+	// an unrestricted link.
+	sharedNoteCacheTTL = 5 * time.Minute
+)
+
+// attachmentLink matches the owner-only attachment URLs in note content.
+var attachmentLink = regexp.MustCompile(`/api/attachments/(\d+)\b`)
+
+// SharedNote is a note opened through a share link.
+type SharedNote struct {
+	Note        *model.Note
+	Attachments []model.Attachment
+
+	// CacheFor is how long the note may be cached publicly. It is 0 for
+	// links with a password or view limit, which must not be cached.
+	CacheFor time.Duration
+}
+
 type ShareService struct {
-	Notes *repository.NoteRepository
-	Share *repository.ShareRepository
+	Notes       *repository.NoteRepository
+	Share       *repository.ShareRepository
+	Attachments *repository.AttachmentRepository
+	AppConfig   *config.Config
 }
 
-func NewShareService(n *repository.NoteRepository, s *repository.ShareRepository) *ShareService {
-	return &ShareService{Notes: n, Share: s}
+func NewShareService(n *repository.NoteRepository, s *repository.ShareRepository, a *repository.AttachmentRepository, cfg *config.Config) *ShareService {
+	return &ShareService{Notes: n, Share: s, Attachments: a, AppConfig: cfg}
 }
 
-func (s *ShareService) CreateShare(userID, noteID int64) (string, error) {
+// CreateShare creates a share link for a note of the user, optionally with
+// a label, an expiry, a password and a view limit. Every call adds a new
+// link; existing links keep working.
+func (s *ShareService) CreateShare(userID, noteID int64, input model.ShareOptionsInput) (*model.Share, error) {
 	// Make sure user owns note
 	if err := s.Notes.EnsureOwnership(userID, noteID); err != nil {
-		return "", fmt.Errorf("not allowed")
+		return nil, ErrShareNotAllowed
+	}
+
+	label, expiresAt, passwordHash, maxViews, err := shareOptions(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// On creation "no label" is empty and "no limit" is simply NULL
+	if label == nil {
+		label = new(string)
+	}
+	if expiresAt != nil && *expiresAt == "" {
+		expiresAt = nil
+	}
+	if passwordHash != nil && *passwordHash == "" {
+		passwordHash = nil
+	}
+	if maxViews != nil && *maxViews == 0 {
+		maxViews = nil
 	}
 
 	token, _ := crypto.RandomHex(32)
 
-	if err := s.Share.Create(noteID, token); err != nil {
-		return "", err
+	id, err := s.Share.Create(noteID, *label, token, expiresAt, passwordHash, maxViews)
+	if err != nil {
+		return nil, err
 	}
 
-	return token, nil
+	return s.getShare(noteID, id)
 }
 
+// DisableShare revokes every share link of a note.
 func (s *ShareService) DisableShare(userID, noteID int64) error {
 	if err := s.Notes.EnsureOwnership(userID, noteID); err != nil {
-		return fmt.Errorf("not allowed")
+		return ErrShareNotAllowed
 	}
 
 	return s.Share.Disable(noteID)
 }
 
-func (s *ShareService) GetSharedNote(token string) (*model.Note, error) {
-	noteID, err := s.Share.FindByToken(token)
+// ListShares returns the share links of a note of the user with their view
+// counts. Expired or used up links are included with Active set to false.
+func (s *ShareService) ListShares(userID, noteID int64) ([]model.Share, error) {
+	if err := s.Notes.EnsureOwnership(userID, noteID); err != nil {
+		return nil, ErrShareNotAllowed
+	}
+
+	shares, err := s.Share.ListForNote(noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range shares {
+		s.decorate(&shares[i])
+	}
+	return shares, nil
+}
+
+// UpdateShare changes the label and limits of a share link. Changing the
+// password locks out everyone who unlocked the link with the old one.
+func (s *ShareService) UpdateShare(userID, noteID, shareID int64, input model.ShareOptionsInput) (*model.Share, error) {
+	if err := s.Notes.EnsureOwnership(userID, noteID); err != nil {
+		return nil, ErrShareNotAllowed
+	}
+
+	label, expiresAt, passwordHash, maxViews, err := shareOptions(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Share.UpdateOptions(noteID, shareID, label, expiresAt, passwordHash, maxViews); err != nil {
+		return nil, notFoundOr(err, ErrShareNotFound)
+	}
+
+	return s.getShare(noteID, shareID)
+}
+
+// RotateShare gives a share link a new token, keeping its settings and
+// view count. The old URL and everything unlocked through it stop working.
+func (s *ShareService) RotateShare(userID, noteID, shareID int64) (*model.Share, error) {
+	if err := s.Notes.EnsureOwnership(userID, noteID); err != nil {
+		return nil, ErrShareNotAllowed
+	}
+
+	token, _ := crypto.RandomHex(32)
+
+	if err := s.Share.RotateToken(noteID, shareID, token); err != nil {
+		return nil, notFoundOr(err, ErrShareNotFound)
+	}
+
+	return s.getShare(noteID, shareID)
+}
+
+// RevokeShare disables one share link of a note.
+func (s *ShareService) RevokeShare(userID, noteID, shareID int64) error {
+	if err := s.Notes.EnsureOwnership(userID, noteID); err != nil {
+		return ErrShareNotAllowed
+	}
+
+	return notFoundOr(s.Share.Revoke(noteID, shareID), ErrShareNotFound)
+}
+
+func (s *ShareService) getShare(noteID, shareID int64) (*model.Share, error) {
+	share, err := s.Share.GetByID(noteID, shareID)
+	if err != nil {
+		return nil, notFoundOr(err, ErrShareNotFound)
+	}
+	return s.decorate(share), nil
+}
+
+// GetSharedNote returns the note behind a share link and its attachments,
+// and counts the view. A password protected link needs the access grant
+// returned by Unlock.
+func (s *ShareService) GetSharedNote(token, access string) (*SharedNote, error) {
+	share, err := s.open(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if share.PasswordProtected && !s.validAccess(share, access) {
+		return nil, ErrSharePasswordRequired
+	}
+
+	counted, err := s.Share.CountView(share.ID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid token")
+		return nil, err
+	}
+	if !counted {
+		return nil, ErrShareViewLimit
 	}
 
 	// Get note (service decrypts via repository)
-	return s.Notes.GetPublicNote(noteID)
+	note, err := s.Notes.GetPublicNote(share.NoteID)
+	if err != nil {
+		return nil, ErrShareNotFound
+	}
+
+	attachments, err := s.Attachments.ListForNote(share.NoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attachments of a protected link are only served with a fresh grant,
+	// so its URLs carry one
+	query := ""
+	cacheFor := sharedNoteCacheTTL
+	if share.PasswordProtected || share.MaxViews != nil {
+		grant, _ := s.grantAccess(share)
+		query = "?access=" + grant
+		cacheFor = 0
+	}
+
+	// Don't let caches serve the note after the link expires
+	if share.ExpiresAt != nil {
+		if expires, err := time.Parse(time.RFC3339, *share.ExpiresAt); err == nil {
+			cacheFor = min(cacheFor, time.Until(expires).Truncate(time.Second))
+		}
+	}
+
+	shared := map[string]bool{}
+	for i := range attachments {
+		shared[strconv.FormatInt(attachments[i].ID, 10)] = true
+		attachments[i].URL = sharedAttachmentURL(token, attachments[i].ID) + query
+	}
+
+	// Point links to the note's attachments at their public URLs
+	note.Content = attachmentLink.ReplaceAllStringFunc(note.Content, func(link string) string {
+		id := attachmentLink.FindStringSubmatch(link)[1]
+		if !shared[id] {
+			return link
+		}
+		return "/api/share/" + token + "/attachments/" + id + query
+	})
+
+	return &SharedNote{Note: note, Attachments: attachments, CacheFor: cacheFor}, nil
+}
+
+// Unlock checks the password of a share link and returns an access grant
+// for GetSharedNote with its expiry time.
+func (s *ShareService) Unlock(token, password string) (string, string, error) {
+	share, err := s.open(token)
+	if err != nil {
+		return "", "", err
+	}
+
+	if share.MaxViews != nil && share.ViewCount >= *share.MaxViews {
+		return "", "", ErrShareViewLimit
+	}
+
+	if share.PasswordProtected {
+		if bcrypt.CompareHashAndPassword([]byte(share.PasswordHash), []byte(password)) != nil {
+			return "", "", ErrShareWrongPassword
+		}
+	}
+
+	grant, expires := s.grantAccess(share)
+	return grant, expires.UTC().Format(time.RFC3339), nil
+}
+
+// GetSharedAttachment returns an attachment of a shared note with its
+// content. Viewing attachments doesn't count as a view; links with a
+// password or view limit need the grant from the note's attachment URLs.
+func (s *ShareService) GetSharedAttachment(token, access string, attachmentID int64) (*model.Attachment, []byte, error) {
+	share, err := s.open(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if (share.PasswordProtected || share.MaxViews != nil) && !s.validAccess(share, access) {
+		return nil, nil, ErrShareAccessDenied
+	}
+
+	a, hash, err := s.Attachments.GetForNote(share.NoteID, attachmentID)
+	if err != nil {
+		return nil, nil, ErrAttachmentNotFound
+	}
+
+	data, err := s.Attachments.Read(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return a, data, nil
+}
+
+// open looks up an enabled, unexpired share link.
+func (s *ShareService) open(token string) (*model.Share, error) {
+	share, err := s.Share.FindByToken(token)
+	if err != nil {
+		return nil, notFoundOr(err, ErrShareNotFound)
+	}
+
+	if shareExpired(share) {
+		return nil, ErrShareExpired
+	}
+	return share, nil
+}
+
+// grantAccess issues a stateless access grant "<unix expiry>.<mac>". The MAC
+// covers the token and the password hash, so rotating the token or setting
+// a new password revokes earlier grants.
+func (s *ShareService) grantAccess(share *model.Share) (string, time.Time) {
+	expires := time.Now().Add(shareAccessTTL)
+	mac := crypto.HMACHex([]byte(s.AppConfig.JWTSecret), accessMessage(share, expires.Unix()))
+	return fmt.Sprintf("%d.%s", expires.Unix(), mac), expires
+}
+
+func (s *ShareService) validAccess(share *model.Share, grant string) bool {
+	expiresPart, mac, ok := strings.Cut(grant, ".")
+	if !ok {
+		return false
+	}
+
+	expires, err := strconv.ParseInt(expiresPart, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+
+	return crypto.VerifyHMACHex([]byte(s.AppConfig.JWTSecret), accessMessage(share, expires), mac)
+}
+
+func accessMessage(share *model.Share, expires int64) string {
+	return fmt.Sprintf("share-access:%d:%s:%d:%s", share.ID, share.Token, expires, share.PasswordHash)
+}
+
+// decorate fills in the fields derived from a share's state.
+func (s *ShareService) decorate(share *model.Share) *model.Share {
+	share.URL = s.PageURL(share.Token)
+	share.Active = !shareExpired(share) && (share.MaxViews == nil || share.ViewCount < *share.MaxViews)
+	return share
+}
+
+// PageURL returns the address of the public page of a share link.
+func (s *ShareService) PageURL(token string) string {
+	return fmt.Sprintf("%s/s/%s", s.AppConfig.AppBaseURL, token)
+}
+
+func shareExpired(share *model.Share) bool {
+	if share.ExpiresAt == nil {
+		return false
+	}
+	expires, err := time.Parse(time.RFC3339, *share.ExpiresAt)
+	return err == nil && !time.Now().Before(expires)
+}
+
+// shareOptions validates share options and returns them in storage form:
+// the label trimmed, the expiry in UTC RFC 3339 and the password as a
+// bcrypt hash. Values that remove a limit ("" or 0) are passed through.
+func shareOptions(input model.ShareOptionsInput) (label, expiresAt, passwordHash *string, maxViews *int64, err error) {
+	if input.Label != nil {
+		value := strings.TrimSpace(*input.Label)
+		if utf8.RuneCountInString(value) > maxShareLabelLen {
+			return nil, nil, nil, nil, fmt.Errorf("%w: label is too long", ErrInvalidShareOptions)
+		}
+		label = &value
+	}
+
+	if input.ExpiresAt != nil {
+		value := ""
+		if *input.ExpiresAt != "" {
+			t, err := time.Parse(time.RFC3339, *input.ExpiresAt)
+			if err != nil || !t.After(time.Now()) {
+				return nil, nil, nil, nil, fmt.Errorf("%w: expires_at must be a future RFC 3339 time", ErrInvalidShareOptions)
+			}
+			value = t.UTC().Format(time.RFC3339)
+		}
+		expiresAt = &value
+	}
+
+	if input.Password != nil {
+		value := ""
+		if *input.Password != "" {
+			if len(*input.Password) > maxSharePasswordLen {
+				return nil, nil, nil, nil, fmt.Errorf("%w: password is too long", ErrInvalidShareOptions)
+			}
+			hash, err := bcrypt.GenerateFromPassword([]byte(*input.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			value = string(hash)
+		}
+		passwordHash = &value
+	}
+
+	if input.MaxViews != nil {
+		if *input.MaxViews < 0 {
+			return nil, nil, nil, nil, fmt.Errorf("%w: max_views must not be negative", ErrInvalidShareOptions)
+		}
+		maxViews = input.MaxViews
+	}
+
+	return label, expiresAt, passwordHash, maxViews, nil
+}
+
+func sharedAttachmentURL(token string, attachmentID int64) string {
+	return fmt.Sprintf("/api/share/%s/attachments/%d", token, attachmentID)
 }
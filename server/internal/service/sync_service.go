@@ -0,0 +1,347 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/repository"
+)
+
+const (
+	defaultSyncLimit = 200
+	maxSyncLimit     = 1000
+	maxSyncPushItems = 500
+)
+
+var (
+	ErrInvalidSyncCursor = errors.New("invalid sync cursor")
+	ErrSyncBatchTooLarge = errors.New("too many changes in one push")
+	ErrInvalidSyncChange = errors.New("invalid change")
+)
+
+// SyncService implements delta sync for offline-capable clients.
+//
+// Clients pull everything that changed after the cursor they got last time,
+// and push their local edits in batches. A pushed update or delete conflicts
+// when the server copy changed after the client's cursor.
+type SyncService struct {
+	Repo           *repository.SyncRepository
+	Notes          *NoteService
+	EncryptedNotes *EncryptedNotesService
+}
+
+func NewSyncService(repo *repository.SyncRepository, notes *NoteService, encrypted *EncryptedNotesService) *SyncService {
+	return &SyncService{Repo: repo, Notes: notes, EncryptedNotes: encrypted}
+}
+
+// ParseSyncCursor decodes a cursor returned by Pull. Empty means "from the start".
+func ParseSyncCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	seq, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidSyncCursor
+	}
+	return seq, nil
+}
+
+// Pull returns the changes made after the cursor. When HasMore is set the
+// client should call again with the returned cursor.
+func (s *SyncService) Pull(userID int64, cursor string, limit int) (*model.SyncDelta, error) {
+	since, err := ParseSyncCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultSyncLimit
+	}
+	if limit > maxSyncLimit {
+		limit = maxSyncLimit
+	}
+
+	changes, err := s.Repo.Changes(userID, since, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := &model.SyncDelta{
+		Notes: model.SyncNotesDelta{
+			Created: []model.Note{},
+			Updated: []model.Note{},
+			Deleted: []int64{},
+		},
+		EncryptedNotes: model.SyncEncryptedNotesDelta{
+			Created: []model.EncryptedNoteResponse{},
+			Updated: []model.EncryptedNoteResponse{},
+			Deleted: []int64{},
+		},
+		Cursor: strconv.FormatInt(since, 10),
+	}
+
+	if len(changes) > limit {
+		changes = changes[:limit]
+		delta.HasMore = true
+	}
+
+	for _, c := range changes {
+		delta.Cursor = strconv.FormatInt(c.Seq, 10)
+		created := c.CreatedSeq > since
+
+		if c.Op == model.SyncOpDelete {
+			// Created and deleted since the cursor: the client never saw it
+			if created {
+				continue
+			}
+			switch c.Entity {
+			case model.SyncEntityNote:
+				delta.Notes.Deleted = append(delta.Notes.Deleted, c.EntityID)
+			case model.SyncEntityEncryptedNote:
+				delta.EncryptedNotes.Deleted = append(delta.EncryptedNotes.Deleted, c.EntityID)
+			}
+			continue
+		}
+
+		switch c.Entity {
+		case model.SyncEntityNote:
+			note, err := s.note(userID, c.EntityID)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue // deleted after the log was read; the next pull reports it
+			}
+			if err != nil {
+				return nil, err
+			}
+			if created {
+				delta.Notes.Created = append(delta.Notes.Created, *note)
+			} else {
+				delta.Notes.Updated = append(delta.Notes.Updated, *note)
+			}
+
+		case model.SyncEntityEncryptedNote:
+			note, err := s.EncryptedNotes.Get(userID, c.EntityID)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if created {
+				delta.EncryptedNotes.Created = append(delta.EncryptedNotes.Created, *note)
+			} else {
+				delta.EncryptedNotes.Updated = append(delta.EncryptedNotes.Updated, *note)
+			}
+		}
+	}
+
+	return delta, nil
+}
+
+// Push applies a batch of client changes one by one and reports the outcome
+// of each item. Items are independent: a conflict or error in one item does
+// not stop the others. With force set, conflicts are ignored (last write wins).
+func (s *SyncService) Push(userID int64, cursor string, force bool, items []model.SyncPushItem) ([]model.SyncPushResult, error) {
+	since, err := ParseSyncCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) > maxSyncPushItems {
+		return nil, ErrSyncBatchTooLarge
+	}
+
+	// Entities written earlier in this batch must not conflict with themselves
+	touched := map[string]bool{}
+
+	results := make([]model.SyncPushResult, len(items))
+	for i, item := range items {
+		result := model.SyncPushResult{
+			Index:    i,
+			Type:     item.Type,
+			Op:       item.Op,
+			ID:       item.ID,
+			ClientID: item.ClientID,
+			Status:   model.SyncStatusApplied,
+		}
+
+		key := item.Type + ":" + strconv.FormatInt(item.ID, 10)
+
+		if item.Op != "create" {
+			conflict, server, err := s.checkConflict(userID, since, item)
+			if err != nil {
+				result.Status = model.SyncStatusError
+				result.Error = pushError(err)
+				results[i] = result
+				continue
+			}
+			if conflict && !force && !touched[key] {
+				result.Status = model.SyncStatusConflict
+				result.Server = server
+				results[i] = result
+				continue
+			}
+		}
+
+		id, err := s.apply(userID, item)
+		if err != nil {
+			result.Status = model.SyncStatusError
+			result.Error = pushError(err)
+		} else {
+			result.ID = id
+			touched[item.Type+":"+strconv.FormatInt(id, 10)] = true
+		}
+
+		results[i] = result
+	}
+
+	return results, nil
+}
+
+// checkConflict reports whether the server copy of the item's entity changed
+// after since, and returns the current server version if it did.
+func (s *SyncService) checkConflict(userID, since int64, item model.SyncPushItem) (bool, interface{}, error) {
+	latest, err := s.Repo.Latest(userID, item.Type, item.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	if latest.Op == model.SyncOpDelete {
+		// Deleting something that is already gone is fine
+		return item.Op != "delete", nil, nil
+	}
+
+	if latest.Seq <= since {
+		return false, nil, nil
+	}
+
+	switch item.Type {
+	case model.SyncEntityNote:
+		note, err := s.note(userID, item.ID)
+		return true, note, err
+	default:
+		note, err := s.EncryptedNotes.Get(userID, item.ID)
+		return true, note, err
+	}
+}
+
+// apply performs one pushed change and returns the entity ID.
+func (s *SyncService) apply(userID int64, item model.SyncPushItem) (int64, error) {
+	switch item.Type {
+	case model.SyncEntityNote:
+		return s.applyNote(userID, item)
+	case model.SyncEntityEncryptedNote:
+		return s.applyEncryptedNote(userID, item)
+	default:
+		return 0, ErrInvalidSyncChange
+	}
+}
+
+func (s *SyncService) applyNote(userID int64, item model.SyncPushItem) (int64, error) {
+	if item.Op == "delete" {
+		return item.ID, s.Notes.DeleteForever(userID, item.ID)
+	}
+
+	fields := item.Note
+	if fields == nil {
+		return 0, ErrInvalidSyncChange
+	}
+
+	id := item.ID
+	switch item.Op {
+	case "create":
+		var err error
+		id, err = s.Notes.Create(userID, model.CreateNoteInput{
+			Title:   fields.Title,
+			Content: fields.Content,
+			Tags:    fields.Tags,
+		})
+		if err != nil {
+			return 0, err
+		}
+	case "update":
+		_, err := s.Notes.Update(userID, id, model.UpdateNoteInput{
+			Title:   fields.Title,
+			Content: fields.Content,
+			Tags:    fields.Tags,
+		})
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, ErrInvalidSyncChange
+	}
+
+	if fields.IsPinned != nil || fields.IsArchived != nil || fields.IsDeleted != nil {
+		if _, err := s.Notes.UpdateFlags(userID, id, fields.IsPinned, fields.IsArchived, fields.IsDeleted); err != nil {
+			return id, err
+		}
+	}
+
+	return id, nil
+}
+
+func (s *SyncService) applyEncryptedNote(userID int64, item model.SyncPushItem) (int64, error) {
+	if item.Op == "delete" {
+		return item.ID, s.EncryptedNotes.Delete(userID, item.ID)
+	}
+
+	fields := item.EncryptedNote
+	if fields == nil {
+		return 0, ErrInvalidSyncChange
+	}
+
+	switch item.Op {
+	case "create":
+		return s.EncryptedNotes.Create(userID, *fields)
+	case "update":
+		_, err := s.EncryptedNotes.Update(userID, item.ID, model.UpdateEncryptedNoteInput{
+			TitleCiphertext:   fields.TitleCiphertext,
+			ContentCiphertext: fields.ContentCiphertext,
+			TitleNonce:        fields.TitleNonce,
+			ContentNonce:      fields.ContentNonce,
+			NoteSalt:          fields.NoteSalt,
+			FormatVersion:     fields.FormatVersion,
+		})
+		return item.ID, err
+	default:
+		return 0, ErrInvalidSyncChange
+	}
+}
+
+// note loads a plaintext note with its tags.
+func (s *SyncService) note(userID, noteID int64) (*model.Note, error) {
+	note, err := s.Notes.Repo.GetByID(userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	note.Tags, err = s.Notes.Tags.ForNote(noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return note, nil
+}
+
+// pushError turns an item error into the message reported to the client.
+func pushError(err error) string {
+	var verr *ValidationError
+	switch {
+	case errors.As(err, &verr):
+		return verr.Error()
+	case errors.Is(err, ErrNoteNotFound), errors.Is(err, sql.ErrNoRows):
+		return "not found"
+	case errors.Is(err, ErrInvalidTagName):
+		return "invalid tag name"
+	case errors.Is(err, ErrInvalidSyncChange):
+		return "invalid change"
+	default:
+		return "failed"
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/repository"
+)
+
+// maxTagNameLength limits how long a single tag name may be.
+const maxTagNameLength = 64
+
+var (
+	ErrTagNotFound    = errors.New("tag not found")
+	ErrTagExists      = errors.New("tag already exists")
+	ErrInvalidTagName = errors.New("invalid tag name")
+)
+
+type TagService struct {
+	Repo *repository.TagRepository
+}
+
+func NewTagService(repo *repository.TagRepository) *TagService {
+	return &TagService{Repo: repo}
+}
+
+func (s *TagService) List(userID int64) ([]model.Tag, error) {
+	return s.Repo.List(userID)
+}
+
+func (s *TagService) Get(userID, tagID int64) (*model.Tag, error) {
+	tag, err := s.Repo.GetByID(userID, tagID)
+	if err != nil {
+		return nil, ErrTagNotFound
+	}
+	return tag, nil
+}
+
+func (s *TagService) Create(userID int64, name string) (int64, error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := s.Repo.FindIDByName(userID, name); err == nil {
+		return 0, ErrTagExists
+	}
+
+	return s.Repo.Create(userID, name)
+}
+
+// Rename renames a tag. Renaming onto an existing tag name merges the two
+// tags. Returns the ID of the tag that now carries the name.
+func (s *TagService) Rename(userID, tagID int64, name string) (int64, error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := s.Repo.Rename(userID, tagID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrTagNotFound
+	}
+	return id, err
+}
+
+// Merge moves all notes from the source tags to the target tag and removes
+// the source tags.
+func (s *TagService) Merge(userID int64, sourceIDs []int64, targetID int64) error {
+	err := s.Repo.Merge(userID, sourceIDs, targetID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTagNotFound
+	}
+	return err
+}
+
+func (s *TagService) Delete(userID, tagID int64) error {
+	err := s.Repo.Delete(userID, tagID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTagNotFound
+	}
+	return err
+}
+
+// normalizeTagName trims a tag name and validates it.
+// Commas are rejected because tag lists are returned comma separated.
+func normalizeTagName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+
+	if name == "" || len(name) > maxTagNameLength || strings.Contains(name, ",") {
+		return "", ErrInvalidTagName
+	}
+
+	return name, nil
+}
+
+// normalizeTagNames normalizes a list of tag names and drops
+// case-insensitive duplicates, keeping the first spelling.
+func normalizeTagNames(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	result := []string{}
+
+	for _, name := range names {
+		normalized, err := normalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		key := strings.ToLower(normalized)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, normalized)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/shamal-iroshan/notora/internal/model"
+	"github.com/shamal-iroshan/notora/internal/repository"
+)
+
+const (
+	x25519KeySize      = 32
+	maxWrappedKeyBytes = 1024 // decoded; generous for any key format plus the GCM tag
+)
+
+var (
+	ErrKeysNotFound      = errors.New("no key pair registered")
+	ErrInvalidPublicKey  = errors.New("public_key must be a 32 byte X25519 key, base64 encoded")
+	ErrInvalidPrivateKey = errors.New("private_key_ciphertext must be base64 and private_key_nonce 12 bytes, hex encoded")
+	ErrInvalidKeyCheck   = errors.New("key check ciphertext must be base64 and nonce 12 bytes, hex encoded")
+	ErrKeyCheckChanged   = errors.New("key check was changed by another client")
+	ErrKDFChanged        = errors.New("kdf differs from your current parameters, change them with POST /api/encrypted-notes/rekey")
+)
+
+// UserKeyService manages the key material users keep on the server for
+// encrypted notes: key pairs for sharing and the master key check. The
+// server only stores them; everything secret is encrypted by the client.
+type UserKeyService struct {
+	Repo  *repository.UserKeyRepository
+	Users *repository.UserRepository
+}
+
+func NewUserKeyService(repo *repository.UserKeyRepository, users *repository.UserRepository) *UserKeyService {
+	return &UserKeyService{Repo: repo, Users: users}
+}
+
+// Get returns the user's own key pair, with the private key still encrypted.
+func (s *UserKeyService) Get(userID int64) (*model.UserKeys, error) {
+	keys, err := s.Repo.Get(userID)
+	if err != nil {
+		return nil, notFoundOr(err, ErrKeysNotFound)
+	}
+	return keys, nil
+}
+
+// Put registers or replaces the user's key pair. Replacing the public key
+// drops the notes shared with the old one (see UserKeyRepository.Put).
+func (s *UserKeyService) Put(userID int64, keys model.UserKeys) (*model.UserKeys, error) {
+	if !isBase64OfSize(keys.PublicKey, x25519KeySize, x25519KeySize) {
+		return nil, ErrInvalidPublicKey
+	}
+	if !isBase64OfSize(keys.PrivateKeyCiphertext, 1, maxWrappedKeyBytes) || !isHexOfSize(keys.PrivateKeyNonce, gcmNonceSize) {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	if err := s.Repo.Put(userID, keys); err != nil {
+		return nil, err
+	}
+
+	return s.Get(userID)
+}
+
+// SetKeyCheck sets or replaces the user's key check, encrypted with the
+// master key derived with the user's current KDF parameters. A client that
+// says which parameters it used (kdf) is refused with ErrKDFChanged if they
+// aren't the current ones. See UserKeyRepository.PutKeyCheck for replaces.
+func (s *UserKeyService) SetKeyCheck(userID int64, kc model.KeyCheck, kdf *model.KDFParams, replaces *string) (*model.KeyCheck, error) {
+	if err := validateKeyCheck(kc); err != nil {
+		return nil, err
+	}
+
+	if kdf != nil {
+		user, err := s.Users.FindByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if *kdf != user.KDF {
+			return nil, ErrKDFChanged
+		}
+	}
+
+	if err := s.Repo.PutKeyCheck(userID, kc, replaces); err != nil {
+		return nil, notFoundOr(err, ErrKeyCheckChanged)
+	}
+
+	user, err := s.Users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.KeyCheck, nil
+}
+
+func validateKeyCheck(kc model.KeyCheck) error {
+	if !isBase64OfSize(kc.Ciphertext, 1, maxWrappedKeyBytes) || !isHexOfSize(kc.Nonce, gcmNonceSize) {
+		return ErrInvalidKeyCheck
+	}
+	return nil
+}
+
+// FindPublicKey returns the public key of another user by email.
+func (s *UserKeyService) FindPublicKey(email string) (*model.PublicKey, error) {
+	key, err := s.Repo.FindPublicKey(strings.TrimSpace(email))
+	if err != nil {
+		return nil, notFoundOr(err, ErrKeysNotFound)
+	}
+	return key, nil
+}
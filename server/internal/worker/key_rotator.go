@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// KeyRotator re-encrypts data sealed with old server keys in the
+// background, a batch at a time, while the server keeps serving. Once it
+// reports that everything was rotated, the old keys can be removed from
+// ENCRYPTION_KEYS.
+type KeyRotator struct {
+	Rotation *service.KeyRotationService
+	Batch    int
+	Pause    time.Duration
+}
+
+func NewKeyRotator(rotation *service.KeyRotationService, batch int, pause time.Duration) *KeyRotator {
+	return &KeyRotator{Rotation: rotation, Batch: batch, Pause: pause}
+}
+
+// A batch that can't be read at all is retried, waiting twice as long each
+// time up to maxKeyRotationRetryDelay.
+const (
+	keyRotationRetryDelay    = time.Second
+	maxKeyRotationRetryDelay = 5 * time.Minute
+)
+
+// Run rotates batches until every table has been gone through or ctx is
+// done. Rows that fail are logged and skipped, so one bad row doesn't keep
+// the rest on the old key. It is meant to be started in its own goroutine.
+func (k *KeyRotator) Run(ctx context.Context) {
+	var cursor service.KeyRotationCursor
+	rotated, failed := 0, 0
+	retryDelay := keyRotationRetryDelay
+
+	for !cursor.Done() {
+		next, batch, err := k.Rotation.RotateBatch(cursor, k.Batch)
+		if err != nil {
+			log.Println("key rotation failed, retrying in", retryDelay, "-", err)
+			if !sleep(ctx, retryDelay) {
+				return
+			}
+			retryDelay = min(2*retryDelay, maxKeyRotationRetryDelay)
+			continue
+		}
+		retryDelay = keyRotationRetryDelay
+
+		cursor = next
+		rotated += batch.Rotated
+		for _, err := range batch.Failed {
+			log.Println("key rotation: skipped", err)
+		}
+		failed += len(batch.Failed)
+
+		if batch.Last != 0 && !sleep(ctx, k.Pause) {
+			return
+		}
+	}
+
+	switch {
+	case failed > 0:
+		log.Println("key rotation: re-encrypted", rotated, "items with key", k.Rotation.ActiveKeyID(),
+			"but", failed, "failed; keep the old keys until they are rotated")
+	case rotated > 0:
+		log.Println("key rotation: re-encrypted", rotated, "items with key", k.Rotation.ActiveKeyID())
+	}
+}
+
+// sleep waits for d and reports false if ctx was done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
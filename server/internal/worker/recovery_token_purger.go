@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// RecoveryTokenPurger periodically deletes recovery tokens that expired
+// and no longer count towards the request limit.
+type RecoveryTokenPurger struct {
+	Recovery *service.RecoveryService
+	Interval time.Duration
+}
+
+func NewRecoveryTokenPurger(recovery *service.RecoveryService, interval time.Duration) *RecoveryTokenPurger {
+	return &RecoveryTokenPurger{Recovery: recovery, Interval: interval}
+}
+
+// Run purges once immediately and then on every interval until ctx is done.
+// It is meant to be started in its own goroutine.
+func (p *RecoveryTokenPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *RecoveryTokenPurger) purge() {
+	purged, err := p.Recovery.PurgeTokens()
+	if err != nil {
+		log.Println("recovery token purge failed:", err)
+		return
+	}
+	if purged > 0 {
+		log.Println("recovery token purge: deleted", purged, "tokens")
+	}
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shamal-iroshan/notora/internal/service"
+)
+
+// TrashPurger periodically deletes notes that have been in the trash for
+// longer than the retention period. Share links of purged notes go with them.
+type TrashPurger struct {
+	Notes     *service.NoteService
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+func NewTrashPurger(notes *service.NoteService, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{Notes: notes, Retention: retention, Interval: interval}
+}
+
+// Run purges once immediately and then on every interval until ctx is done.
+// It is meant to be started in its own goroutine.
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *TrashPurger) purge() {
+	purged, err := p.Notes.PurgeExpiredTrash(p.Retention)
+	if err != nil {
+		log.Println("trash purge failed:", err)
+		return
+	}
+	if purged > 0 {
+		log.Println("trash purge: deleted", purged, "notes")
+	}
+}